@@ -13,8 +13,11 @@ type TextCell struct {
 	font       core.Font
 	width      float64 // 宽度, 必须
 	height     float64
-	contents   []string // 内容
-	lastheight float64  // 最近一次操作前的height
+	contents   []string             // 内容
+	directions []core.TextDirection // contents 中每一行所在段落的方向
+	lastLines  []bool               // contents 中每一行是否为段落的最后一行
+	richLines  []richLine           // 富文本内容的每一行(与 contents 对应), 纯文本内容时为 nil
+	lastheight float64              // 最近一次操作前的height
 
 	lineHeight float64 // 行高
 	lineSpace  float64 // 行间距
@@ -24,10 +27,25 @@ type TextCell struct {
 	// 颜色控制
 	fontColor string
 	backColor string
+	highlight string     // 文本的背景高亮颜色
+	render    textRender // 文本的渲染方式与描边
 
 	verticalCentered   bool // 垂直居中
 	horizontalCentered bool // 水平居中
 	rightAlign         bool // 水平居左
+	leftAlign          bool // 水平居左, 默认 RTL 段落居右
+	justify            bool // 两端对齐, 段落的最后一行除外
+
+	direction      core.TextDirection // 段落方向, 默认由每个段落的首个强方向字符决定
+	hyphenation    core.Hyphenation   // 断词设置, 默认不断词
+	hyphenationErr error              // 断词的语言没有断词规则的错误, 由 GenerateAtomicCell 返回
+	tabStops       []TabStop          // 制表位, 按位置排序
+	rotation       int                // 逆时针旋转的角度: 0, 90, 180 或 270
+	vertical       bool               // 竖排, contents 中每一项为一列
+	inTable        bool               // 表格的单元格(由 TableCell.SetElement 设置), 旋转后的内容排在整个行高中
+
+	overflow Overflow     // 内容超出时的处理方式, 默认写入全部内容
+	original *textMetrics // 缩小字号之前的字号, 行高与行间距, 未缩小时为 nil
 }
 
 func NewTextCell(width, lineHeight, lineSpace float64, pdf *core.Report) *TextCell {
@@ -49,17 +67,30 @@ func NewTextCell(width, lineHeight, lineSpace float64, pdf *core.Report) *TextCe
 }
 
 func (cell *TextCell) Copy(content string) *TextCell {
+	font, lineHeight, lineSpace := cell.font, cell.lineHeight, cell.lineSpace
+	if cell.original != nil {
+		font.Size, lineHeight, lineSpace = cell.original.size, cell.original.lineHeight, cell.original.lineSpace
+	}
 	text := &TextCell{
-		pdf:        cell.pdf,
-		width:      cell.width,
-		height:     0,
-		lineHeight: cell.lineHeight,
-		lineSpace:  cell.lineSpace,
-		border:     cell.border,
-		fontColor:  cell.fontColor,
+		pdf:         cell.pdf,
+		width:       cell.width,
+		height:      0,
+		lineHeight:  lineHeight,
+		lineSpace:   lineSpace,
+		border:      cell.border,
+		fontColor:   cell.fontColor,
+		highlight:   cell.highlight,
+		render:      cell.render,
+		direction:   cell.direction,
+		hyphenation: cell.hyphenation,
+		tabStops:    cell.tabStops,
+		rotation:    cell.rotation,
+		vertical:    cell.vertical,
+		inTable:     cell.inTable,
+		overflow:    cell.overflow,
 	}
 
-	text.SetFont(cell.font)
+	text.SetFont(font)
 
 	text.SetContent(content)
 
@@ -72,14 +103,99 @@ func (cell *TextCell) VerticalCentered() *TextCell {
 }
 func (cell *TextCell) HorizontalCentered() *TextCell {
 	cell.rightAlign = false
+	cell.leftAlign = false
+	cell.justify = false
 	cell.horizontalCentered = true
 	return cell
 }
 func (cell *TextCell) RightAlign() *TextCell {
 	cell.horizontalCentered = false
+	cell.leftAlign = false
+	cell.justify = false
 	cell.rightAlign = true
 	return cell
 }
+func (cell *TextCell) LeftAlign() *TextCell {
+	cell.horizontalCentered = false
+	cell.rightAlign = false
+	cell.justify = false
+	cell.leftAlign = true
+	return cell
+}
+
+// 两端对齐: 除段落的最后一行外, 每一行的剩余宽度分摊到词间或字间(中日文)
+func (cell *TextCell) Justify() *TextCell {
+	cell.horizontalCentered = false
+	cell.rightAlign = false
+	cell.leftAlign = false
+	cell.justify = true
+	return cell
+}
+
+// 设置段落方向, 须在 SetContent 之前调用. 未设置对齐方式时, RTL 段落居右显示
+func (cell *TextCell) SetDirection(dir core.TextDirection) *TextCell {
+	cell.direction = dir
+	return cell
+}
+
+// 设置断词的语言及断词后两侧的最少字符数, 须在 SetContent 之前调用. 放不下的单词在断词点折行, 并在行尾添加连字符
+// 语言没有断词规则时不断词, 错误由 GenerateAtomicCell 返回
+func (cell *TextCell) SetHyphenation(hyphenation core.Hyphenation) *TextCell {
+	cell.hyphenationErr = nil
+	if hyphenation.Language != "" {
+		if _, err := core.GetHyphenator(hyphenation.Language); err != nil {
+			cell.hyphenationErr = err
+			hyphenation = core.Hyphenation{}
+		}
+	}
+	cell.hyphenation = hyphenation
+	return cell
+}
+
+// 设置内容逆时针旋转的角度, 须为 90 的倍数, 须在 SetContent 之前调用. 旋转 90/270 度时段落不折行,
+// 行沿 cell 的高度方向书写, cell 的高度为最长的行的宽度(如表格中竖排的列标题); 旋转后的内容不跨页拆分
+func (cell *TextCell) SetRotation(degrees int) *TextCell {
+	if degrees%90 != 0 {
+		panic("rotation must be a multiple of 90 degrees")
+	}
+	cell.rotation = (degrees%360 + 360) % 360
+	return cell
+}
+
+// 竖排: 段落从上向下折成列, 列从右向左排列, 列高为使全部列排在 cell 宽度内的最小高度, cell 的高度随之确定.
+// 须在 SetContent 之前调用. 竖排时 HorizontalCentered 使各列在 cell 内水平居中, 不支持旋转,
+// 竖排的内容不跨页拆分. 富文本(SetRuns)不支持竖排, 按横排排版
+func (cell *TextCell) SetVertical() *TextCell {
+	cell.vertical = true
+	return cell
+}
+
+// sideways 内容是否旋转了 90/270 度
+func (cell *TextCell) sideways() bool {
+	return cell.rotation == 90 || cell.rotation == 270
+}
+
+// contentWidth 返回折行的宽度, 旋转 90/270 度时不折行
+func (cell *TextCell) contentWidth() float64 {
+	if cell.sideways() {
+		return math.MaxFloat64
+	}
+	return cell.width - math.Abs(cell.border.Left) - math.Abs(cell.border.Right)
+}
+
+// 设置制表位, 须在 SetContent 之前调用. 内容中的制表符将其后的文字移到下一个制表位, 未设置或超出时使用间隔为
+// 4 个空格宽度的默认制表位
+func (cell *TextCell) SetTabStops(stops ...TabStop) *TextCell {
+	cell.tabStops = sortTabStops(stops)
+	return cell
+}
+
+// 设置内容超出固定的高度或最多的行数时的处理方式: 截断, 省略号, 缩小字号, 须在 SetContent 之前调用.
+// 设置了固定的高度时, cell 的高度为该高度(如表格中高度固定的行). 竖排时不起作用
+func (cell *TextCell) SetOverflow(overflow Overflow) *TextCell {
+	cell.overflow = overflow
+	return cell
+}
 
 func (cell *TextCell) SetFontColor(color string) *TextCell {
 	util.CheckColor(color)
@@ -92,8 +208,32 @@ func (cell *TextCell) SetBackColor(color string) *TextCell {
 	return cell
 }
 
+// SetHighlight 设置文本的背景高亮颜色. 下划线/删除线/上划线由字体样式 "U"/"S"/"O" 设置
+func (cell *TextCell) SetHighlight(color string) *TextCell {
+	util.CheckColor(color)
+	cell.highlight = color
+	return cell
+}
+
+// SetRenderMode 设置文本的渲染方式: 描边, 填充并描边, 不可见, 裁剪等, 参考 core.TextRenderMode.
+// 裁剪方式下各行合成一个裁剪区域, 在组件写完文本后恢复
+func (cell *TextCell) SetRenderMode(mode core.TextRenderMode) *TextCell {
+	cell.render.mode = mode
+	return cell
+}
+
+// SetTextStroke 设置描边的宽度与颜色, 与字体颜色无关. width 为 0 时为字号的 3%, color 为空时为字体颜色
+func (cell *TextCell) SetTextStroke(width float64, color string) *TextCell {
+	if color != "" {
+		util.CheckColor(color)
+	}
+	cell.render.strokeWidth, cell.render.strokeColor = width, color
+	return cell
+}
+
 func (cell *TextCell) SetFont(font core.Font) *TextCell {
 	cell.font = font
+	cell.original = nil
 	// 注册, 启动
 	cell.pdf.Font(font.Family, font.Size, font.Style)
 	cell.pdf.SetFontWithStyle(font.Family, font.Style, font.Size)
@@ -112,10 +252,41 @@ func (cell *TextCell) SetBorder(border core.Scope) *TextCell {
 }
 
 func (cell *TextCell) SetContent(s string) *TextCell {
-	convertStr := strings.Replace(s, "\t", "    ", -1)
+	if cell.overflow.Mode == OverflowVisible || cell.vertical {
+		return cell.setContent(s)
+	}
+
+	if cell.original == nil {
+		cell.original = &textMetrics{size: cell.font.Size, lineHeight: cell.lineHeight, lineSpace: cell.lineSpace}
+	}
+	limit := cell.overflow.fit(*cell.original, cell.border.Top+math.Abs(cell.border.Bottom), func(m textMetrics) int {
+		cell.font.Size, cell.lineHeight, cell.lineSpace = m.size, m.lineHeight, m.lineSpace
+		cell.contents, cell.directions, cell.lastLines = nil, nil, nil
+		cell.setContent(s)
+		return len(cell.contents)
+	})
+	if limit >= 0 && len(cell.contents) > limit {
+		cell.contents = cell.overflow.truncate(cell.contents, limit, cell.contentWidth(), tabMeasure(cell.pdf, cell.tabStops))
+		cell.directions = cell.directions[:limit]
+		cell.lastLines = cell.lastLines[:limit]
+		if limit > 0 {
+			cell.lastLines[limit-1] = true
+		}
+	}
+	cell.resetHeight()
+	// 固定的高度放不下一行时, 没有可以写入的行, 高度仍为固定的高度
+	if height, ok := cell.overflow.fixedHeight(); ok {
+		cell.height = height
+	}
+	cell.lastheight = cell.height
+	return cell
+}
+
+func (cell *TextCell) setContent(s string) *TextCell {
 	var (
-		blocks       = strings.Split(convertStr, "\n") // 分行
-		contentWidth = cell.width - math.Abs(cell.border.Left) - math.Abs(cell.border.Right)
+		blocks       = strings.Split(s, "\n") // 分行
+		contentWidth = cell.contentWidth()
+		measure      = tabMeasure(cell.pdf, cell.tabStops)
 	)
 
 	// 必须检查字体
@@ -126,91 +297,189 @@ func (cell *TextCell) SetContent(s string) *TextCell {
 	// 必须先进行注册, 才能设置
 	cell.pdf.Font(cell.font.Family, cell.font.Size, cell.font.Style)
 	cell.pdf.SetFontWithStyle(cell.font.Family, cell.font.Style, cell.font.Size)
+	cell.richLines = nil
+	if cell.vertical {
+		blocks = strings.Split(strings.Replace(s, "\t", "    ", -1), "\n")
+		var height float64
+		cell.contents, height = fitColumns(cell.pdf, blocks, columnCount(contentWidth, cell.lineHeight, cell.lineSpace))
+		cell.directions = make([]core.TextDirection, len(cell.contents))
+		cell.lastLines = make([]bool, len(cell.contents))
+		cell.height = cell.border.Top + math.Abs(cell.border.Bottom) + height
+		cell.lastheight = cell.height
+		return cell
+	}
 
 	if len(blocks) == 1 {
-		if cell.pdf.MeasureTextWidth(convertStr) < contentWidth {
-			cell.contents = []string{convertStr}
-			cell.height = math.Abs(cell.border.Top) + math.Abs(cell.border.Bottom) + cell.lineHeight
+		if measure(s) < contentWidth {
+			cell.contents = []string{s}
+			cell.directions = []core.TextDirection{core.ResolveDirection(cell.direction, s)}
+			cell.lastLines = []bool{true}
+			cell.resetHeight()
 			cell.lastheight = cell.height
 			return cell
 		}
 	}
 
+	// 每个段落按断行机会(UAX #14, 避头尾)折行, 设置了断词时在单词内断开
 	for i := range blocks {
-		// 不需要拆分
-		if cell.pdf.MeasureTextWidth(convertStr) < contentWidth {
-			cell.contents = append(cell.contents, blocks[i])
-			continue
+		dir := core.ResolveDirection(cell.direction, blocks[i])
+		lines := core.WrapTextWithHyphenation(blocks[i], contentWidth, measure, cell.hyphenation)
+		for j, line := range lines {
+			cell.contents = append(cell.contents, line)
+			cell.directions = append(cell.directions, dir)
+			cell.lastLines = append(cell.lastLines, j == len(lines)-1)
 		}
+	}
+	cell.resetHeight()
+	cell.lastheight = cell.height
+	return cell
+}
 
-		// 需要拆分
-		var line []rune
-		for _, r := range []rune(blocks[i]) {
-			line = append(line, r)
-			lineLength := cell.pdf.MeasureTextWidth(string(line))
-			if lineLength >= contentWidth {
-				if lineLength-contentWidth > 2 {
-					cell.contents = append(cell.contents, string(line[0:len(line)-1]))
-					line = line[len(line)-1:]
-				} else {
-					cell.contents = append(cell.contents, string(line))
-					line = []rune{}
-				}
-			}
+// 设置富文本内容: 字体, 颜色, 下划线, 删除线, 链接, 高亮各不相同的片段, 片段中未设置的字体属性与颜色使用 TextCell 的设置.
+// 折行跨越片段, 行高随行内最大的字体增加
+func (cell *TextCell) SetRuns(runs []TextRun) *TextCell {
+	// 必须检查字体
+	if util.IsEmpty(cell.font) {
+		panic("there no avliable font")
+	}
+	// 富文本不支持竖排, 按横排排版
+	cell.vertical = false
+	checkRuns(runs)
+
+	runs = append([]TextRun(nil), runs...)
+	cell.richLines, cell.directions, cell.lastLines = layoutRuns(cell.pdf, runs, cell.font, cell.contentWidth(), cell.direction, cell.hyphenation)
+	cell.contents = make([]string, len(cell.richLines))
+	for i, line := range cell.richLines {
+		for _, segment := range line.segments {
+			cell.contents[i] += segment.text
 		}
+	}
+	cell.resetHeight()
+	cell.lastheight = cell.height
+	return cell
+}
 
-		if len(line) > 0 {
-			cell.contents = append(cell.contents, string(line))
+// resetHeight 按剩余的内容计算 height. 旋转 90/270 度时 height 为最长的行的宽度, 竖排时为最长的列的高度,
+// 内容超出的设置固定了高度时为该高度
+func (cell *TextCell) resetHeight() {
+	if len(cell.contents) == 0 {
+		cell.height = 0
+		return
+	}
+	if height, ok := cell.overflow.fixedHeight(); ok && !cell.vertical {
+		cell.height = height
+		return
+	}
+	if cell.vertical {
+		longest := 0.0
+		for i := range cell.contents {
+			longest = math.Max(longest, cell.pdf.MeasureTextHeight(cell.contents[i]))
+		}
+		cell.height = cell.border.Top + math.Abs(cell.border.Bottom) + longest
+		return
+	}
+	if cell.sideways() {
+		longest := 0.0
+		for i := range cell.contents {
+			longest = math.Max(longest, cell.lineWidth(i))
 		}
+		cell.height = math.Abs(cell.border.Left) + math.Abs(cell.border.Right) + longest
+		return
 	}
 	length := float64(len(cell.contents))
 	cell.height = cell.border.Top + math.Abs(cell.border.Bottom) + cell.lineHeight*length + cell.lineSpace*(length-1)
-	cell.lastheight = cell.height
-	return cell
+	for i := range cell.richLines {
+		cell.height += cell.richLines[i].height()
+	}
+}
+
+// fitLines 返回在 maxheight 内可以写入的行数
+func (cell *TextCell) fitLines(maxheight float64) int {
+	if maxheight > cell.height || math.Abs(maxheight-cell.height) < 0.01 {
+		return len(cell.contents)
+	}
+	if cell.rotation != 0 || cell.vertical {
+		return 0
+	}
+	if cell.richLines == nil {
+		// 固定的高度可能高于内容
+		lines := int((maxheight + cell.lineSpace) / (cell.lineHeight + cell.lineSpace))
+		if lines > len(cell.contents) {
+			lines = len(cell.contents)
+		}
+		return lines
+	}
+
+	height := -cell.lineSpace
+	for i := range cell.richLines {
+		height += cell.lineHeight + cell.lineSpace + cell.richLines[i].height()
+		if height > maxheight {
+			return i
+		}
+	}
+	return len(cell.richLines)
+}
+
+// lineWidth 返回第 index 行内容的宽度
+func (cell *TextCell) lineWidth(index int) float64 {
+	if cell.richLines != nil {
+		return cell.richLines[index].width
+	}
+	return tabMeasure(cell.pdf, cell.tabStops)(cell.contents[index])
 }
 
 // 先涂背景颜色, 然后在背景颜色的基础上写入内容
 func (cell *TextCell) GenerateAtomicCell(maxheight float64) (int, int, error) {
+	if cell.hyphenationErr != nil {
+		return 0, 0, cell.hyphenationErr
+	}
+	if cell.rotation != 0 {
+		return cell.generateRotated(maxheight)
+	}
+	if cell.vertical {
+		return cell.generateColumns(maxheight)
+	}
+
 	var (
 		sx, sy = cell.pdf.GetXY() // 基准坐标
 		lines  int                // 可以写入的行数
 		x, y   float64            // 实际开始的坐标
+		dirs   = directionWriter{pdf: cell.pdf}
 	)
 
 	cell.pdf.Font(cell.font.Family, cell.font.Size, cell.font.Style)
 	cell.pdf.SetFontWithStyle(cell.font.Family, cell.font.Style, cell.font.Size)
 
 	// Cell() uses baseline Y; sy is cell top. Vertically center glyph em-box in each line slot.
-	asc, desc := cell.pdf.GetFontMetrics(cell.font.Family, float64(cell.font.Size))
+	asc, desc := cell.pdf.GetFontMetricsWithStyle(cell.font.Family, cell.font.Style, float64(cell.font.Size))
 	em := asc - desc
 	if em < 1 {
 		em = float64(cell.font.Size) * 1.15
 	}
 
 	// 计算需要打印的行数
-	if maxheight > cell.height || math.Abs(maxheight-cell.height) < 0.01 {
-		lines = len(cell.contents)
-
-		// 垂直居中
-		if maxheight > cell.height && cell.verticalCentered {
-			sy += (maxheight - cell.height) / 2
-		}
-	} else {
-		lines = int((maxheight + cell.lineSpace) / (cell.lineHeight + cell.lineSpace))
+	lines = cell.fitLines(maxheight)
+	// 垂直居中
+	if maxheight > cell.height && cell.verticalCentered {
+		sy += (maxheight - cell.height) / 2
 	}
 
 	// 背景颜色
 	if !util.IsEmpty(cell.backColor) {
 		cell.pdf.BackgroundColor(sx, sy, cell.width, maxheight, cell.backColor, "0000")
 	}
+	cell.render.begin(cell.pdf)
+	defer cell.render.end(cell.pdf)
 
 	// 写入cell数据
+	extra := 0.0 // 富文本中前面的行增加的行高
 	for i := 0; i < lines; i++ {
-		width := cell.pdf.MeasureTextWidth(cell.contents[i])
+		width := cell.lineWidth(i)
 		// 水平居左
 		x = sx + cell.border.Left
-		// 水平居右
-		if cell.rightAlign {
+		// 水平居右, RTL 段落默认居右
+		rtl := cell.directions[i] == core.DirectionRTL
+		if cell.rightAlign || rtl && !cell.leftAlign && !cell.horizontalCentered {
 			x = sx + (cell.width - width - cell.border.Right)
 		}
 		// 水平居中
@@ -218,24 +487,52 @@ func (cell *TextCell) GenerateAtomicCell(maxheight float64) (int, int, error) {
 			x = sx + (cell.width-width)/2
 		}
 
-		lineBoxTop := sy + float64(i)*(cell.lineHeight+cell.lineSpace) + cell.border.Top
+		lineBoxTop := sy + float64(i)*(cell.lineHeight+cell.lineSpace) + cell.border.Top + extra
 		gap := (cell.lineHeight - em) / 2
 		if gap < 0 {
 			gap = 0
 		}
 		y = lineBoxTop + gap + asc
 
+		// 富文本: 各片段写在下移后的同一基线上
+		if cell.richLines != nil {
+			line := cell.richLines[i]
+			justify := 0.0
+			if cell.justify && !cell.lastLines[i] {
+				x = sx + cell.border.Left
+				justify = cell.width - math.Abs(cell.border.Left) - math.Abs(cell.border.Right)
+			}
+			line.draw(cell.pdf, x, y+line.above, justify, cell.fontColor, cell.highlight)
+			extra += line.height()
+			continue
+		}
+
 		// 字体颜色控制
 		if !util.IsEmpty(cell.fontColor) {
 			cell.pdf.TextColor(util.RGB(cell.fontColor))
 		}
+		if cell.highlight != "" {
+			cell.pdf.TextHighlight(util.RGB(cell.highlight))
+		}
 
-		cell.pdf.Cell(x, y, cell.contents[i])
+		dirs.line(cell.directions[i], cell.contents[i])
+		if strings.Contains(cell.contents[i], "\t") {
+			tabCell(cell.pdf, x, y, cell.contents[i], cell.tabStops)
+		} else if cell.justify && !cell.lastLines[i] {
+			x = sx + cell.border.Left
+			justifyCell(cell.pdf, x, y, cell.width-math.Abs(cell.border.Left)-math.Abs(cell.border.Right), cell.contents[i])
+		} else {
+			cell.pdf.Cell(x, y, cell.contents[i])
+		}
 
 		if !util.IsEmpty(cell.fontColor) {
 			cell.pdf.TextDefaultColor()
 		}
+		if cell.highlight != "" {
+			cell.pdf.TextDefaultHighlight()
+		}
 	}
+	dirs.reset()
 
 	// 重置lastheight
 	cell.lastheight = cell.height
@@ -243,18 +540,105 @@ func (cell *TextCell) GenerateAtomicCell(maxheight float64) (int, int, error) {
 	// cell的height和contents重置
 	if lines >= len(cell.contents) {
 		cell.contents = nil
+		cell.directions = nil
+		cell.lastLines = nil
+		if cell.richLines != nil {
+			cell.richLines = cell.richLines[:0]
+		}
 	} else {
 		cell.contents = cell.contents[lines:]
+		cell.directions = cell.directions[lines:]
+		cell.lastLines = cell.lastLines[lines:]
+		if cell.richLines != nil {
+			cell.richLines = cell.richLines[lines:]
+		}
+	}
+	if cell.richLines != nil {
+		cell.pdf.Font(cell.font.Family, cell.font.Size, cell.font.Style)
+		cell.pdf.SetFontWithStyle(cell.font.Family, cell.font.Style, cell.font.Size)
 	}
 
-	if len(cell.contents) == 0 {
-		cell.height = 0
-	} else {
+	cell.resetHeight()
+
+	return lines, len(cell.contents), nil
+}
+
+// generateRotated 在旋转后的坐标中写入全部内容: cell 绕其左上角旋转后平移回原来的位置, 内容按未旋转时的
+// 方式排列在宽高互换(90/270 度)的 cell 中. cell 的高度为其自身的高度, 表格中为行高 maxheight
+func (cell *TextCell) generateRotated(maxheight float64) (int, int, error) {
+	sx, sy := cell.pdf.GetXY()
+	if cell.fitLines(maxheight) == 0 {
+		return 0, len(cell.contents), nil
+	}
+	lastheight := cell.height
+	boxheight := cell.height
+	if cell.inTable {
+		boxheight = maxheight
+	}
+
+	width, height := cell.width, boxheight // 旋转后 cell 的宽和高
+	ox, oy := sx+cell.width, sy+boxheight  // 旋转后 cell 的左上角
+	switch cell.rotation {
+	case 90:
+		width, height = boxheight, cell.width
+		ox, oy = sx, sy+boxheight
+	case 270:
+		width, height = boxheight, cell.width
+		ox, oy = sx+cell.width, sy
+	}
+	m := core.TranslateMatrix(-sx, -sy).
+		Multiply(core.RotateMatrix(float64(cell.rotation))).
+		Multiply(core.TranslateMatrix(ox, oy))
+
+	rotation, cellWidth := cell.rotation, cell.width
+	cell.rotation, cell.width = 0, width
+	cell.resetHeight()
+
+	cell.pdf.PushTransform(m)
+	lines, remain, err := cell.GenerateAtomicCell(math.Max(height, cell.height))
+	cell.pdf.PopTransform()
+	cell.pdf.SetXY(sx, sy)
+
+	cell.rotation, cell.width = rotation, cellWidth
+	cell.resetHeight()
+	cell.lastheight = lastheight
+	return lines, remain, err
+}
+
+// generateColumns 从右向左写入全部的列
+func (cell *TextCell) generateColumns(maxheight float64) (int, int, error) {
+	sx, sy := cell.pdf.GetXY()
+	if cell.fitLines(maxheight) == 0 {
+		return 0, len(cell.contents), nil
+	}
+
+	cell.pdf.Font(cell.font.Family, cell.font.Size, cell.font.Style)
+	cell.pdf.SetFontWithStyle(cell.font.Family, cell.font.Style, cell.font.Size)
+	if !util.IsEmpty(cell.backColor) {
+		cell.pdf.BackgroundColor(sx, sy, cell.width, maxheight, cell.backColor, "0000")
+	}
+
+	top := sy + cell.border.Top
+	if maxheight > cell.height && cell.verticalCentered {
+		top += (maxheight - cell.height) / 2
+	}
+	cell.render.begin(cell.pdf)
+	defer cell.render.end(cell.pdf)
+	right := sx + cell.width - math.Abs(cell.border.Right)
+	if cell.horizontalCentered {
 		length := float64(len(cell.contents))
-		cell.height = cell.border.Top + math.Abs(cell.border.Bottom) + cell.lineHeight*length + cell.lineSpace*(length-1)
+		right -= math.Max(cell.contentWidth()-cell.lineHeight*length-cell.lineSpace*(length-1), 0) / 2
 	}
+	height := cell.height - cell.border.Top - math.Abs(cell.border.Bottom)
+	drawColumns(cell.pdf, cell.contents, right, top, height, cell.lineHeight, cell.lineSpace,
+		false, false, cell.fontColor, cell.highlight)
+	cell.pdf.SetXY(sx, sy)
 
-	return lines, len(cell.contents), nil
+	lines := len(cell.contents)
+	cell.contents, cell.directions, cell.lastLines = nil, nil, nil
+	cell.lastheight = cell.height
+	cell.resetHeight()
+	return lines, 0, nil
 }
 
 func (cell *TextCell) TryGenerateAtomicCell(maxheight float64) (int, int) {
@@ -266,11 +650,7 @@ func (cell *TextCell) TryGenerateAtomicCell(maxheight float64) (int, int) {
 	cell.pdf.SetFontWithStyle(cell.font.Family, cell.font.Style, cell.font.Size)
 
 	// 计算需要打印的行数
-	if maxheight > cell.height || math.Abs(maxheight-cell.height) < 0.01 {
-		lines = len(cell.contents)
-	} else {
-		lines = int((maxheight + cell.lineSpace) / (cell.lineHeight + cell.lineSpace))
-	}
+	lines = cell.fitLines(maxheight)
 
 	remain := 0
 	if lines < len(cell.contents) {
@@ -0,0 +1,209 @@
+package core
+
+import (
+	"fmt"
+
+	"golang.org/x/text/unicode/bidi"
+)
+
+// Bidirectional text follows the Unicode Bidirectional Algorithm (UBA). Components wrap lines in
+// logical order and set the base direction of their paragraphs with a "TD" record; the shaper
+// resolves the embedding levels of each written line and draws its runs in visual order.
+
+// TextDirection is the base direction of a paragraph.
+type TextDirection int
+
+const (
+	DirectionAuto TextDirection = iota // direction of the first strong character (UBA rules P2, P3), LTR without one
+	DirectionLTR                       // left to right
+	DirectionRTL                       // right to left
+)
+
+func (d TextDirection) String() string {
+	switch d {
+	case DirectionLTR:
+		return "ltr"
+	case DirectionRTL:
+		return "rtl"
+	default:
+		return "auto"
+	}
+}
+
+func parseTextDirection(s string) (TextDirection, error) {
+	switch s {
+	case "auto":
+		return DirectionAuto, nil
+	case "ltr":
+		return DirectionLTR, nil
+	case "rtl":
+		return DirectionRTL, nil
+	}
+	return DirectionAuto, fmt.Errorf("invalid text direction %q", s)
+}
+
+// ResolveDirection returns the direction of the paragraph text: dir itself, unless it is
+// DirectionAuto, in which case the first strong character decides.
+func ResolveDirection(dir TextDirection, text string) TextDirection {
+	if dir != DirectionAuto {
+		return dir
+	}
+	for _, r := range text {
+		props, _ := bidi.LookupRune(r)
+		switch props.Class() {
+		case bidi.L:
+			return DirectionLTR
+		case bidi.R, bidi.AL:
+			return DirectionRTL
+		}
+	}
+	return DirectionLTR
+}
+
+// BidiLevels returns the resolved embedding levels of the characters of text, one line of a
+// paragraph with base direction dir. Components that write a line in several pieces place them by
+// VisualOrder of their levels, each piece written with the direction of its level.
+func BidiLevels(dir TextDirection, text string) []int {
+	return bidiLevels([]rune(text), ResolveDirection(dir, text) == DirectionRTL)
+}
+
+// bidiLevels returns the resolved embedding level of every character of text, one line of a
+// paragraph at level 1 if rtl, else 0. golang.org/x/text/unicode/bidi resolves the weak and
+// neutral types (rules W1-W7, N1, N2) but reports only the direction of each run: characters of
+// right-to-left runs are at level 1 (rules I1, I2), characters of left-to-right runs at level 2 in
+// a right-to-left paragraph. In a left-to-right paragraph the numbers that did not become L by
+// rule W7 and the separators and terminators they absorbed (W4, W5) are raised to level 2 (I1).
+func bidiLevels(text []rune, rtl bool) []int {
+	levels := make([]int, len(text))
+	if len(text) == 0 {
+		return levels
+	}
+
+	// the paragraph level is given: without a default direction x/text detects it from the text
+	// (rule P2), a leading LRM keeps a left-to-right paragraph at level 0
+	var (
+		p    bidi.Paragraph
+		s    = string(text)
+		skip = 0
+		def  = bidi.RightToLeft
+	)
+	if !rtl {
+		s, skip, def = "\u200e"+s, 1, bidi.LeftToRight
+	}
+	base := 0
+	if rtl {
+		base = 1
+	}
+	for i := range levels {
+		levels[i] = base
+	}
+	if _, err := p.SetString(s, bidi.DefaultDirection(def)); err != nil {
+		return levels
+	}
+	order, err := p.Order()
+	if err != nil {
+		return levels
+	}
+	for i := 0; i < order.NumRuns(); i++ {
+		run := order.Run(i)
+		start, end := run.Pos()
+		for j := start - skip; j <= end-skip; j++ {
+			if j < 0 || j >= len(levels) {
+				continue
+			}
+			switch {
+			case run.Direction() == bidi.RightToLeft:
+				levels[j] = 1
+			case rtl:
+				levels[j] = 2
+			}
+		}
+	}
+	if rtl {
+		return levels
+	}
+
+	classes := make([]bidi.Class, len(text))
+	strong := bidi.L // rule W7 looks back to the start of the paragraph, at level 0 its type is L
+	for i, r := range text {
+		props, _ := bidi.LookupRune(r)
+		classes[i] = props.Class()
+		switch classes[i] {
+		case bidi.L:
+			strong = bidi.L
+		case bidi.R, bidi.AL:
+			strong = bidi.R
+		case bidi.AN:
+			if levels[i] == 0 {
+				levels[i] = 2
+			}
+		case bidi.EN:
+			if levels[i] == 0 && strong == bidi.R {
+				levels[i] = 2
+			}
+		}
+	}
+	raised := func(i int) bool { return i >= 0 && i < len(text) && levels[i] == 2 }
+	for i := range text {
+		if levels[i] != 0 {
+			continue
+		}
+		switch classes[i] {
+		case bidi.ES, bidi.CS:
+			// a single separator between two numbers (rule W4)
+			if raised(i-1) && raised(i+1) {
+				levels[i] = 2
+			}
+		case bidi.ET, bidi.NSM, bidi.BN:
+			// terminators after a number, and marks on a raised character (rules W1, W5)
+			if raised(i - 1) {
+				levels[i] = 2
+			}
+		}
+	}
+	for i := len(text) - 1; i >= 0; i-- {
+		// terminators before a number (rule W5)
+		if levels[i] == 0 && classes[i] == bidi.ET && raised(i+1) {
+			levels[i] = 2
+		}
+	}
+	return levels
+}
+
+// VisualOrder returns the indexes of runs with the given embedding levels in display order
+// (UBA rule L2): from the highest level down to the lowest odd level, every sequence of runs at
+// that level or higher is reversed.
+func VisualOrder(levels []int) []int {
+	order := make([]int, len(levels))
+	highest, lowestOdd := 0, -1
+	for i, level := range levels {
+		order[i] = i
+		if level > highest {
+			highest = level
+		}
+		if level%2 == 1 && (lowestOdd < 0 || level < lowestOdd) {
+			lowestOdd = level
+		}
+	}
+	if lowestOdd < 0 {
+		return order
+	}
+
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < len(order); {
+			if levels[order[i]] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < len(order) && levels[order[j]] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+			}
+			i = j
+		}
+	}
+	return order
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/signintech/gopdf"
+)
+
+// gopdf writes the content streams of the pages, and its API covers the graphics state stack
+// (SaveGraphicsState, RestoreGraphicsState), rectangular and polygonal clips, rotations,
+// rectangles, polygons, colors and the transparency of what it draws itself. The operators it has
+// no API for go through a single content hook, rawContent:
+//
+//   - a free transformation matrix (cm): transforms, synthetic oblique text
+//   - the text render mode (Tr) and the stroke width of stroked text (w)
+//   - the graphics state setting the opacity of text (gs), which gopdf leaves opaque
+//   - paths: curves, subpaths, the even-odd rule, caps, joins and miter limits, which gopdf's
+//     Polygon has no options for, and the shadings and form XObjects of gradients
+//   - the text objects of the clipping regions of several lines (see TextClip)
+//
+// gopdf has no public way to write content either: ops ride on the paint operator of an empty
+// rectangle, which gopdf writes unescaped. This relies on the layout gopdf (cache_content_rectangle.go)
+// writes a rectangle in,
+//
+//	q\n[/GSn gs\n]x y w h re <paint>\nQ\n
+//
+// With the paint "n\nQ\n" + ops + "\nq" the rectangle becomes an empty path in a q/Q pair of
+// its own, and ops run at the level of the surrounding content, followed by an empty q/Q pair:
+//
+//	q\n0.00 <page height> 0.00 0.00 re n\nQ\n<ops>\nq\nQ\n
+//
+// The layout is the one of gopdfVersion: rawContent refuses to write with another version of
+// gopdf, and TestContentHookVersion fails when go.mod requires another version, until the layout
+// is checked again (TestRawContentBalanced) and gopdfVersion updated.
+
+// gopdfVersion is the version of gopdf whose content layout rawContent relies on.
+const gopdfVersion = "v0.36.0"
+
+const gopdfModule = "github.com/signintech/gopdf"
+
+var (
+	gopdfCheck    sync.Once
+	gopdfCheckErr error
+)
+
+// checkGopdfVersion returns an error if the binary is built with a version of gopdf other than
+// gopdfVersion. Binaries without module information are not checked.
+func checkGopdfVersion() error {
+	gopdfCheck.Do(func() {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, dep := range info.Deps {
+			if dep.Path != gopdfModule {
+				continue
+			}
+			version := dep.Version
+			if dep.Replace != nil {
+				version = dep.Replace.Version
+			}
+			if version != "" && version != gopdfVersion {
+				gopdfCheckErr = fmt.Errorf("raw PDF content is written for gopdf %s, built with %s", gopdfVersion, version)
+			}
+		}
+	})
+	return gopdfCheckErr
+}
+
+// rawContent appends PDF content operators to the current page as they are. The graphics state
+// stack is pushed and popped with gopdf's own SaveGraphicsState and RestoreGraphicsState around
+// them, never by ops: ops leave the depth of the stack as they found it, so the pairs written by
+// gopdf stay matched, and rawContent rejects ops with an unmatched q or Q.
+func (convert *Converter) rawContent(ops string) error {
+	if err := checkGopdfVersion(); err != nil {
+		return err
+	}
+	depth := 0
+	for _, op := range strings.Fields(ops) {
+		switch op {
+		case "q":
+			depth++
+		case "Q":
+			depth--
+		}
+		if depth < 0 {
+			break
+		}
+	}
+	if depth != 0 {
+		return fmt.Errorf("raw content with an unmatched q or Q: %q", ops)
+	}
+	return convert.pdf.RectFromUpperLeftWithOpts(gopdf.DrawableRectOptions{
+		PaintStyle: gopdf.PaintStyle("n\nQ\n" + ops + "\nq"),
+	})
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -15,12 +16,31 @@ type fontMetrics struct {
 	ascenderPerEm   float64 // ascender / unitsPerEm
 	descenderPerEm  float64 // descender / unitsPerEm (negative)
 	spaceWidthPerEm float64 // space glyph width / unitsPerEm
+
+	bold   bool // face is bold by design (OS/2 fsSelection)
+	italic bool // face is slanted by design (post italicAngle)
+
+	decoration decorationMetrics // underline and strikethrough of the face (post, OS/2)
+	script     scriptMetrics     // superscript and subscript of the face (OS/2)
 }
 
+const (
+	syntheticBoldStroke  = 0.03   // stroke width of a synthetic bold face, relative to the font size
+	syntheticObliqueSkew = 0.2126 // tan(12°), slant of a synthetic oblique face
+)
+
 type FontMap struct {
 	FontName string
 	FileName string
 	Data     []byte // Bytes data of font file, will be converted to temp file if provided
+
+	// Style of this face within the FontName family: "" (regular), "B", "I" or "BI".
+	// Faces sharing a FontName are selected by core.Font.Style.
+	Style string
+
+	// Index selects the face of a TrueType/OpenType collection (.ttc/.otc); 0 for single-face files.
+	// Besides TrueType, OpenType fonts with CFF outlines (.otf) are accepted.
+	Index int
 }
 
 // Converter is a bridge to third-party gopdf.
@@ -41,7 +61,41 @@ type Converter struct {
 	lastFont  string   // last used font name
 	tempFonts []string // temporary font files created from bytes data, used for cleanup
 
-	fontMetrics map[string]*fontMetrics // key: font family name
+	fontMetrics map[string]*fontMetrics // key: fontKey(family, style)
+	shaper      *textShaper
+	lazyFaces   map[string]lazyFace        // CFF faces converted at their first use, key: fontKey(family, style)
+	pdfFonts    []string                   // fontKey of the faces added to gopdf, in the order of their font objects
+	glyphTexts  map[string]map[rune]string // characters of the written private-use glyphs, key: fontKey
+
+	pageHeight float64   // page height in pt, for raw content operators
+	font       Font      // font requested by the last "F" or "C" record
+	textColor  [3]uint8  // RGB color of the text, for its stroke and decoration lines
+	highlight  *[3]uint8 // RGB color of the text highlight, set by "HC" records; nil for none
+
+	renderMode  TextRenderMode // render mode of the written text, set by "TR" records
+	strokeColor *[3]uint8      // RGB stroke color of stroked text, set by "TS" records; nil for the text color
+	strokeWidth float64        // stroke width of stroked text in pt, set by "TW" records; 0 for the default
+	textClip    int            // depth of the clipping region of the clip mode on the transform stack, 0 for none
+	textClipID  int            // number of the "TX" region at depth textClip, 0 for a region pushed by clipping text
+	textClips   int            // number of the regions pushed by "TX" records
+	clipGlyphs  *clipGlyphs    // placement of the clipping text of a "TX" region being written, nil otherwise
+	clipRuns    []string       // text operators of the clipping text of the current "TX" region, until it ends
+	sideways    *[2]float64    // rotation center in PDF space of the sideways text being written, nil otherwise
+
+	gradients map[string]*gradient // gradients of paths by key, defined by "GD" records
+
+	direction TextDirection // base direction of the written paragraph, set by "TD" records
+
+	wordSpacing float64 // extra space after spaces between words, set by "WS" records
+	charSpacing float64 // extra space between characters, set by "CS" records
+
+	baselineShift float64 // raise of the baseline of the following text, set by "BS" records
+
+	alpha string // graphics state operator of the transparency set by "TA" records, "" for opaque
+
+	transforms int // number of transforms pushed by "TP" records and not popped yet
+
+	images map[string][]byte // in-memory images referenced by "I" records, key: registry key
 }
 
 // GetAtomicCells returns a copy of the atomic instruction lines.
@@ -103,6 +157,11 @@ func (convert *Converter) Execute() error {
 		if len(elements) == 0 {
 			continue
 		}
+		if convert.endsTextClip(elements) {
+			if err := convert.endTextClip(); err != nil {
+				return err
+			}
+		}
 		switch elements[0] {
 		case "P":
 			err = convert.Page(line, elements)
@@ -112,13 +171,37 @@ func (convert *Converter) Execute() error {
 			err = convert.Font(line, elements)
 		case "TC":
 			err = convert.TextColor(line, elements)
+		case "TD":
+			err = convert.TextDirection(line, elements)
+		case "WS", "CS":
+			err = convert.TextSpacing(line, elements)
+		case "HC":
+			err = convert.TextHighlight(line, elements)
+		case "BS":
+			err = convert.BaselineShift(line, elements)
+		case "TP", "TQ":
+			err = convert.Transform(line, elements)
+		case "TK":
+			err = convert.Clip(line, elements)
+		case "TX":
+			err = convert.TextClip(line, elements)
+		case "TR":
+			err = convert.RenderMode(line, elements)
+		case "TS", "TW":
+			err = convert.TextStroke(line, elements)
+		case "TA":
+			err = convert.Transparency(line, elements)
+		case "PA":
+			err = convert.Path(line, elements)
+		case "GD":
+			err = convert.Gradient(line, elements)
 		case "LC":
 			err = convert.LineColor(line, elements)
 		case "BC":
 			err = convert.BackgroundColor(line, elements)
 		case "GF", "GS":
 			err = convert.Grey(line, elements)
-		case "C", "CL", "CR":
+		case "C", "CL", "CR", "CV":
 			err = convert.Cell(line, elements)
 		case "L", "LV", "LH", "LT":
 			err = convert.Line(line, elements)
@@ -145,58 +228,163 @@ func (convert *Converter) Execute() error {
 			return err
 		}
 	}
-	return nil
+	return convert.closeTransforms()
+}
+
+// lazyFace is a registered face whose conversion to TrueType waits for its first use.
+type lazyFace struct {
+	font *FontMap
+	data []byte
+	err  error // error of a failed conversion, returned at every later use
 }
 
+// AddFont registers the fonts with gopdf. Faces with CFF outlines are only checked here: their
+// conversion to glyf outlines, which covers every glyph of the face, is done when the face is
+// first selected or measured (see loadFace), so faces that are never used cost nothing.
 func (convert *Converter) AddFont() error {
 	if convert.fontMetrics == nil {
 		convert.fontMetrics = make(map[string]*fontMetrics)
 	}
+	if convert.shaper == nil {
+		convert.shaper = newTextShaper()
+	}
+	convert.lazyFaces = make(map[string]lazyFace)
 	for _, font := range convert.fonts {
-		fileName := font.FileName
-		if len(font.Data) > 0 {
-			tempFile, err := ioutil.TempFile("", "gopdf_font_*.ttf")
-			if err != nil {
-				return fmt.Errorf("create temp font file: %w", err)
-			}
-			_, err = tempFile.Write(font.Data)
-			fn := tempFile.Name()
-			if cerr := tempFile.Close(); cerr != nil {
-				os.Remove(fn)
-				if err != nil {
-					return fmt.Errorf("write temp font file: %w", err)
-				}
-				return fmt.Errorf("close temp font file: %w", cerr)
-			}
+		data := font.Data
+		if len(data) == 0 {
+			buf, err := ioutil.ReadFile(font.FileName)
 			if err != nil {
-				os.Remove(fn)
-				return fmt.Errorf("write temp font file: %w", err)
+				return fmt.Errorf("read font file: %w", err)
 			}
-			fileName = fn
-			convert.tempFonts = append(convert.tempFonts, fileName)
+			data = buf
 		}
+		cff, err := isCFFFont(data, font.Index)
+		if err != nil {
+			return fmt.Errorf("font %q (style %q) from %s: %w", font.FontName, font.Style, font.FileName, err)
+		}
+		if cff {
+			convert.lazyFaces[fontKey(font.FontName, font.Style)] = lazyFace{font: font, data: data}
+			continue
+		}
+		if err := convert.addFace(font, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadFace converts and registers the lazy face of key, if any, at its first use.
+func (convert *Converter) loadFace(key string) error {
+	face, ok := convert.lazyFaces[key]
+	if !ok {
+		return nil
+	}
+	if face.err == nil {
+		face.err = convert.addFace(face.font, face.data)
+	}
+	if face.err != nil {
+		convert.lazyFaces[key] = face
+		return face.err
+	}
+	delete(convert.lazyFaces, key)
+	return nil
+}
 
-		if err := convert.pdf.AddTTFFont(font.FontName, fileName); err != nil {
-			return fmt.Errorf("add TTF font %q from %s: %w", font.FontName, fileName, err)
+// metrics returns the metrics of the face of key, loading a lazy face first. A face that fails
+// to load has no metrics, the error is returned when the face is selected.
+func (convert *Converter) metrics(key string) (*fontMetrics, bool) {
+	if err := convert.loadFace(key); err != nil {
+		return nil, false
+	}
+	m, ok := convert.fontMetrics[key]
+	return m, ok
+}
+
+// pdfFont returns the number of the font object of the face of key among the font objects of
+// gopdf, from 0, or -1 if the face is not added to gopdf.
+func (convert *Converter) pdfFont(key string) int {
+	for i, k := range convert.pdfFonts {
+		if k == key {
+			return i
 		}
+	}
+	return -1
+}
 
-		var parser fontcore.TTFParser
-		if err := parser.Parse(fileName); err == nil {
-			units := float64(parser.UnitsPerEm())
-			if units == 0 {
-				units = 1000
-			}
-			m := &fontMetrics{
-				ascenderPerEm:   float64(parser.Ascender()) / units,
-				descenderPerEm:  float64(parser.Descender()) / units,
-				spaceWidthPerEm: convert.parseSpaceWidth(&parser) / units,
-			}
-			convert.fontMetrics[font.FontName] = m
+// addFace converts the face data of font to the TrueType data gopdf embeds, and registers it
+// with gopdf and the shaper.
+func (convert *Converter) addFace(font *FontMap, data []byte) error {
+	fileName := font.FileName
+	ttf, err := trueTypeFont(data, font.Index)
+	if err != nil {
+		return fmt.Errorf("font %q (style %q) from %s: %w", font.FontName, font.Style, fileName, err)
+	}
+	fileName, err = convert.writeTempFont(ttf)
+	if err != nil {
+		return err
+	}
+	key := fontKey(font.FontName, font.Style)
+	convert.shaper.addFace(key, ttf)
+
+	option := gopdf.TtfOption{Style: gopdf.Regular}
+	switch normalizeFontStyle(font.Style) {
+	case FontStyleBold:
+		option.Style = gopdf.Bold
+	case FontStyleItalic:
+		option.Style = gopdf.Italic
+	case FontStyleBoldItalic:
+		option.Style = gopdf.Bold | gopdf.Italic
+	}
+	if err := convert.pdf.AddTTFFontWithOption(font.FontName, fileName, option); err != nil {
+		return fmt.Errorf("add TTF font %q (style %q) from %s: %w", font.FontName, font.Style, fileName, err)
+	}
+	// gopdf keeps the font object of the first face of a family and style
+	if convert.pdfFont(key) < 0 {
+		convert.pdfFonts = append(convert.pdfFonts, key)
+	}
+
+	var parser fontcore.TTFParser
+	if err := parser.Parse(fileName); err == nil {
+		units := float64(parser.UnitsPerEm())
+		if units == 0 {
+			units = 1000
+		}
+		convert.fontMetrics[key] = &fontMetrics{
+			ascenderPerEm:   float64(parser.Ascender()) / units,
+			descenderPerEm:  float64(parser.Descender()) / units,
+			spaceWidthPerEm: convert.parseSpaceWidth(&parser) / units,
+			bold:            parser.Bold,
+			italic:          parser.ItalicAngle() != 0,
+			decoration:      newDecorationMetrics(convert.shaper.faces[key]),
+			script:          newScriptMetrics(ttf),
 		}
 	}
 	return nil
 }
 
+// writeTempFont stores data in a temporary file, removed by CleanupTempFonts.
+func (convert *Converter) writeTempFont(data []byte) (string, error) {
+	tempFile, err := ioutil.TempFile("", "gopdf_font_*.ttf")
+	if err != nil {
+		return "", fmt.Errorf("create temp font file: %w", err)
+	}
+	_, err = tempFile.Write(data)
+	fn := tempFile.Name()
+	if cerr := tempFile.Close(); cerr != nil {
+		os.Remove(fn)
+		if err != nil {
+			return "", fmt.Errorf("write temp font file: %w", err)
+		}
+		return "", fmt.Errorf("close temp font file: %w", cerr)
+	}
+	if err != nil {
+		os.Remove(fn)
+		return "", fmt.Errorf("write temp font file: %w", err)
+	}
+	convert.tempFonts = append(convert.tempFonts, fn)
+	return fn, nil
+}
+
 func (convert *Converter) parseSpaceWidth(parser *fontcore.TTFParser) float64 {
 	chars := parser.Chars()
 	glyphID, ok := chars[32]
@@ -214,20 +402,70 @@ func (convert *Converter) parseSpaceWidth(parser *fontcore.TTFParser) float64 {
 	return 250
 }
 
+// GetFontMetrics returns ascender/descender of the regular face of family.
 func (convert *Converter) GetFontMetrics(family string, size float64) (ascender, descender float64) {
-	if m, ok := convert.fontMetrics[family]; ok {
+	return convert.GetFontMetricsWithStyle(family, "", size)
+}
+
+// GetFontMetricsWithStyle returns ascender/descender of the face that style resolves to.
+func (convert *Converter) GetFontMetricsWithStyle(family, style string, size float64) (ascender, descender float64) {
+	if m, ok := convert.metrics(fontKey(family, convert.ResolveFontStyle(family, style))); ok {
 		return m.ascenderPerEm * size, m.descenderPerEm * size
 	}
 	return size * 0.8, size * -0.2
 }
 
 func (convert *Converter) GetSpaceWidth(family string, size float64) float64 {
-	if m, ok := convert.fontMetrics[family]; ok {
+	return convert.GetSpaceWidthWithStyle(family, "", size)
+}
+
+func (convert *Converter) GetSpaceWidthWithStyle(family, style string, size float64) float64 {
+	if m, ok := convert.metrics(fontKey(family, convert.ResolveFontStyle(family, style))); ok {
 		return m.spaceWidthPerEm * size
 	}
 	return size * 0.25
 }
 
+// HasFontStyle reports whether family has a face registered for style ("", "B", "I" or "BI").
+func (convert *Converter) HasFontStyle(family, style string) bool {
+	family, style = resolveFontAlias(family, style)
+	style = normalizeFontStyle(style)
+	for _, font := range convert.fonts {
+		if font.FontName == family && normalizeFontStyle(font.Style) == style {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveFontStyle maps the requested style onto the faces registered for family:
+// "BI" falls back to "B", then "I", then regular; "B" and "I" fall back to regular.
+// Decoration letters ("U", "S", "O") are dropped: the Converter draws decorations itself.
+func (convert *Converter) ResolveFontStyle(family, style string) string {
+	family, style = resolveFontAlias(family, style)
+	resolved := normalizeFontStyle(style)
+	for _, candidate := range fontStyleFallbacks(style) {
+		if convert.HasFontStyle(family, candidate) {
+			resolved = candidate
+			break
+		}
+	}
+	return resolved
+}
+
+// SyntheticFontStyle reports which parts of style must be simulated for family: a requested
+// bold or italic that has no registered face and is not already provided by the face
+// ResolveFontStyle falls back to.
+func (convert *Converter) SyntheticFontStyle(family, style string) (bold, italic bool) {
+	family, style = resolveFontAlias(family, style)
+	requested := normalizeFontStyle(style)
+	resolved := normalizeFontStyle(convert.ResolveFontStyle(family, style))
+	m, _ := convert.metrics(fontKey(family, resolved))
+	bold = strings.Contains(requested, "B") && !strings.Contains(resolved, "B") && (m == nil || !m.bold)
+	italic = strings.Contains(requested, "I") && !strings.Contains(resolved, "I") && (m == nil || !m.italic)
+	return bold, italic
+}
+
 // Page
 // [P, pt, A4, P|L]
 // Only "pt" (PDF points) is accepted for elements[1]. P|L is portrait or landscape.
@@ -295,11 +533,28 @@ func (convert *Converter) setunit(unit string) error {
 }
 
 func (convert *Converter) NewPage(line string, elements []string) error {
+	if err := convert.closeTransforms(); err != nil {
+		return err
+	}
 	convert.pdf.AddPage()
+	if convert.alpha != "" {
+		return convert.rawContent(convert.alpha)
+	}
 	return nil
 }
 
 func (convert *Converter) start(w float64, h float64) {
+	convert.pageHeight = h
+	convert.font = Font{}
+	convert.textColor, convert.highlight = [3]uint8{}, nil
+	convert.renderMode = TextRenderFill
+	convert.strokeColor, convert.strokeWidth, convert.textClip, convert.textClipID = nil, 0, 0, 0
+	convert.gradients = nil
+	convert.direction = DirectionAuto
+	convert.wordSpacing, convert.charSpacing = 0, 0
+	convert.baselineShift = 0
+	convert.alpha = ""
+	convert.transforms = 0
 	convert.pdf.Start(gopdf.Config{
 		Unit:     gopdf.Unit_PT,
 		PageSize: gopdf.Rect{W: w, H: h},
@@ -315,9 +570,11 @@ func (convert *Converter) Font(line string, elements []string) error {
 	if err != nil {
 		return err
 	}
-	if err := convert.pdf.SetFont(elements[1], elements[2], size); err != nil {
+	family, style := resolveFontAlias(elements[1], elements[2])
+	if err := convert.selectFont(family, style, size); err != nil {
 		return fmt.Errorf("%w; line %s", err, line)
 	}
+	convert.font = Font{Family: family, Style: style, Size: size}
 	return nil
 }
 
@@ -331,6 +588,8 @@ func (convert *Converter) Grey(line string, elements []string) error {
 	}
 	if elements[0] == "GF" {
 		convert.pdf.SetGrayFill(g)
+		v := uint8(math.Round(math.Max(0, math.Min(1, g)) * 255))
+		convert.textColor = [3]uint8{v, v, v}
 	}
 	if elements[0] == "GS" {
 		convert.pdf.SetGrayStroke(g)
@@ -338,6 +597,59 @@ func (convert *Converter) Grey(line string, elements []string) error {
 	return nil
 }
 
+// Transparency sets ("TA|fill|stroke") or resets ("TA") the opacity of the following drawing:
+// fill for filled paths, text and images, stroke for stroked paths and lines, from 0 to 1.
+// The opacity outlives transforms and pages: its graphics state is set again after them, and
+// paths set it in their own q/Q pair. gopdf writes the fill opacity of the images and lines it
+// draws. Resetting sets an opaque graphics state, as the state gopdf writes stays in effect after
+// its content.
+func (convert *Converter) Transparency(line string, elements []string) error {
+	var fill, stroke float64 = 1, 1
+	if len(elements) > 1 && elements[1] != "" {
+		if err := checkLength(line, elements, 3); err != nil {
+			return err
+		}
+		var err error
+		if fill, err = parseFloatCell(elements[1], line); err != nil {
+			return err
+		}
+		if stroke, err = parseFloatCell(elements[2], line); err != nil {
+			return err
+		}
+		if fill < 0 || fill > 1 || stroke < 0 || stroke > 1 {
+			return fmt.Errorf("opacity out of the range 0 to 1; line %s", line)
+		}
+	}
+
+	state, err := convert.extGState(fill, stroke)
+	if err != nil {
+		return err
+	}
+	if fill == 1 && stroke == 1 {
+		convert.pdf.ClearTransparency()
+		convert.alpha = ""
+		return convert.rawContent(state)
+	}
+	err = convert.pdf.SetTransparency(gopdf.Transparency{Alpha: fill, BlendModeType: gopdf.NormalBlendMode})
+	if err != nil {
+		return err
+	}
+	convert.alpha = state
+	return convert.rawContent(state)
+}
+
+// extGState returns the operator setting the graphics state with the fill and stroke opacities.
+func (convert *Converter) extGState(fill, stroke float64) (string, error) {
+	blend := gopdf.NormalBlendMode
+	state, err := gopdf.GetCachedExtGState(gopdf.ExtGStateOptions{
+		StrokingCA: &stroke, NonStrokingCa: &fill, BlendMode: &blend,
+	}, convert.pdf)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/GS%d gs", state.Index+1), nil
+}
+
 func (convert *Converter) TextColor(line string, elements []string) error {
 	if err := checkLength(line, elements, 4); err != nil {
 		return err
@@ -355,6 +667,38 @@ func (convert *Converter) TextColor(line string, elements []string) error {
 		return err
 	}
 	convert.pdf.SetTextColor(uint8(r1), uint8(r2), uint8(r3))
+	convert.textColor = [3]uint8{uint8(r1), uint8(r2), uint8(r3)}
+	return nil
+}
+
+// TextDirection sets the base direction of the paragraph the following text lines belong to.
+func (convert *Converter) TextDirection(line string, elements []string) error {
+	if err := checkLength(line, elements, 2); err != nil {
+		return err
+	}
+	dir, err := parseTextDirection(elements[1])
+	if err != nil {
+		return fmt.Errorf("%w; line %s", err, line)
+	}
+	convert.direction = dir
+	return nil
+}
+
+// TextSpacing sets the word ("WS") or character ("CS") spacing of the following text.
+func (convert *Converter) TextSpacing(line string, elements []string) error {
+	if err := checkLength(line, elements, 2); err != nil {
+		return err
+	}
+	spacing, err := parseFloatCell(elements[1], line)
+	if err != nil {
+		return err
+	}
+	if elements[0] == "WS" {
+		convert.wordSpacing = spacing * convert.unit
+	}
+	if elements[0] == "CS" {
+		convert.charSpacing = spacing * convert.unit
+	}
 	return nil
 }
 
@@ -525,6 +869,17 @@ func (convert *Converter) Image(line string, elements []string) error {
 	r.W = x1*convert.unit - x0*convert.unit
 	r.H = y1*convert.unit - y0*convert.unit
 
+	if key, ok := registryKey(elements[1]); ok {
+		holder, err := convert.imageHolder(key)
+		if err != nil {
+			return fmt.Errorf("%w; line %s", err, line)
+		}
+		if err := convert.pdf.ImageByHolder(holder, x0*convert.unit, y0*convert.unit, r); err != nil {
+			return fmt.Errorf("%w; line %s", err, line)
+		}
+		return nil
+	}
+
 	if err := convert.pdf.Image(elements[1], x0*convert.unit, y0*convert.unit, r); err != nil {
 		return fmt.Errorf("%w; line %s", err, line)
 	}
@@ -611,13 +966,15 @@ func (convert *Converter) Cell(line string, elements []string) error {
 		if err != nil {
 			return err
 		}
-		if err := convert.pdf.SetFont(elements[1], "", size); err != nil {
+		family, style := resolveFontAlias(elements[1], "")
+		if err := convert.selectFont(family, style, size); err != nil {
 			return fmt.Errorf("%w; line %s", err, line)
 		}
+		convert.font = Font{Family: family, Style: style, Size: size}
 		if err := convert.setPosition(elements[3], elements[4], line); err != nil {
 			return err
 		}
-		if err := convert.pdf.Text(elements[5]); err != nil {
+		if err := convert.text(elements[5]); err != nil {
 			return fmt.Errorf("%w; line %s", err, line)
 		}
 	case "CL":
@@ -627,14 +984,14 @@ func (convert *Converter) Cell(line string, elements []string) error {
 		if err := convert.setPosition(elements[1], elements[2], line); err != nil {
 			return err
 		}
-		if err := convert.pdf.Text(elements[3]); err != nil {
+		if err := convert.text(elements[3]); err != nil {
 			return fmt.Errorf("%w; line %s", err, line)
 		}
 	case "CR":
 		if err := checkLength(line, elements, 5); err != nil {
 			return err
 		}
-		tw, err := convert.pdf.MeasureTextWidth(elements[4])
+		tw, err := convert.textWidth(elements[4])
 		if err != nil {
 			return fmt.Errorf("%w; line %s", err, line)
 		}
@@ -656,13 +1013,195 @@ func (convert *Converter) Cell(line string, elements []string) error {
 		finalx := x + w - tw
 		convert.pdf.SetX(finalx)
 		convert.pdf.SetY(y)
-		if err := convert.pdf.Text(elements[4]); err != nil {
+		if err := convert.text(elements[4]); err != nil {
+			return fmt.Errorf("%w; line %s", err, line)
+		}
+	case "CV":
+		if err := checkLength(line, elements, 4); err != nil {
+			return err
+		}
+		if err := convert.setPosition(elements[1], elements[2], line); err != nil {
+			return err
+		}
+		if err := convert.verticalText(elements[3]); err != nil {
 			return fmt.Errorf("%w; line %s", err, line)
 		}
 	}
 	return nil
 }
 
+// text writes s at the current position with its highlight and decoration lines, on the
+// baseline shifted by the current baseline shift.
+func (convert *Converter) text(s string) error {
+	if convert.baselineShift != 0 {
+		y := convert.pdf.GetY()
+		convert.pdf.SetY(y - convert.baselineShift)
+		defer convert.pdf.SetY(y)
+	}
+	if convert.highlight == nil && fontDecorations(convert.font.Style) == "" || !convert.renderMode.decorated() {
+		return convert.styledText(s)
+	}
+
+	x, baseline := convert.pdf.GetX(), convert.pdf.GetY()
+	width, err := convert.writtenWidth(s)
+	if err != nil {
+		return err
+	}
+	if err := convert.decorate(x, baseline, width, true); err != nil {
+		return err
+	}
+	if err := convert.styledText(s); err != nil {
+		return err
+	}
+	return convert.decorate(x, baseline, width, false)
+}
+
+// styledText writes s at the current position. Bold and italic styles without a face of their
+// own are simulated: bold by filling and stroking the glyph outlines, italic by skewing
+// the glyphs around the start of the baseline.
+func (convert *Converter) styledText(s string) error {
+	return convert.styled(func() error {
+		return convert.shapedText(s)
+	})
+}
+
+// styled runs write, which writes text at the current position, with the synthetic bold and
+// italic styles of the current font and the text render mode. In a clip mode the glyphs are
+// added to the clipping region first, then painted in the mode without clipping.
+func (convert *Converter) styled(write func() error) error {
+	bold, italic := convert.SyntheticFontStyle(convert.font.Family, convert.font.Style)
+	mode := convert.renderMode
+	if mode.clips() {
+		if err := convert.clipText(write, italic); err != nil {
+			return err
+		}
+		if mode == TextRenderClip {
+			return nil
+		}
+		mode -= TextRenderFillClip
+	}
+	if !bold && !italic && mode == TextRenderFill {
+		return write()
+	}
+
+	var ops []string
+	if italic {
+		baseline := convert.pageHeight - convert.pdf.GetY()
+		ops = append(ops, fmt.Sprintf("1 0 %.4f 1 %.4f 0 cm", syntheticObliqueSkew, -syntheticObliqueSkew*baseline))
+	}
+	var stroke [3]uint8
+	width := 0.0
+	if mode.strokes() {
+		stroke, width = convert.textStrokeStyle()
+	}
+	if bold {
+		// a synthetic bold face is stroked in the text color, thicker in a stroke mode
+		if mode == TextRenderFill {
+			mode, stroke = TextRenderFillStroke, convert.textColor
+		}
+		width += float64(convert.font.Size) * syntheticBoldStroke
+	}
+	if mode.strokes() {
+		ops = append(ops, fmt.Sprintf("%.3f w", width))
+	}
+	if mode != TextRenderFill {
+		ops = append(ops, fmt.Sprintf("%d Tr", mode))
+	}
+	convert.pdf.SaveGraphicsState()
+	if mode.strokes() {
+		convert.pdf.SetStrokeColor(stroke[0], stroke[1], stroke[2])
+	}
+	if err := convert.rawContent(strings.Join(ops, "\n")); err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	convert.pdf.RestoreGraphicsState()
+	return nil
+}
+
+// shapedText writes s at the current position as runs of shaped glyphs, in visual order. A run ends after a glyph
+// whose shaped advance differs from the one gopdf applies (kerning, mark positioning, ...), after a glyph
+// followed by word or character spacing and around glyphs drawn with an offset; each run starts at its
+// shaped pen position.
+func (convert *Converter) shapedText(s string) error {
+	key := convert.shapingKey()
+	glyphs, ok := convert.shaper.shape(key, s, convert.direction)
+	if !ok {
+		return convert.pdf.Text(s)
+	}
+
+	scale := float64(convert.font.Size) / convert.shaper.upem(key)
+	spacing := convert.glyphSpacing(glyphs, scale)
+	x, y := convert.pdf.GetX(), convert.pdf.GetY()
+	pen := 0.0
+	for i := 0; i < len(glyphs); {
+		first := glyphs[i]
+		run := []rune{first.r}
+		advance := first.advance
+		j := i + 1
+		for ; j < len(glyphs); j++ {
+			prev, next := glyphs[j-1], glyphs[j]
+			if math.Abs(prev.advance-prev.nominal) > 0.5 || spacing[j-1] != 0 ||
+				prev.dx != 0 || prev.dy != 0 || next.dx != 0 || next.dy != 0 {
+				break
+			}
+			run = append(run, next.r)
+			advance += next.advance
+		}
+
+		for _, g := range glyphs[i:j] {
+			convert.glyphText(key, g)
+		}
+		convert.pdf.SetX(x + (pen+first.dx)*scale)
+		convert.pdf.SetY(y - first.dy*scale)
+		if err := convert.textRun(string(run)); err != nil {
+			return err
+		}
+		pen += advance + spacing[j-1]
+		i = j
+	}
+	convert.pdf.SetX(x + pen*scale)
+	convert.pdf.SetY(y)
+	return nil
+}
+
+// glyphSpacing returns the word and character spacing following each glyph, in font units.
+// Only the gaps between the first and the last glyph that is not a space are widened.
+func (convert *Converter) glyphSpacing(glyphs []shapedGlyph, scale float64) []float64 {
+	spacing := make([]float64, len(glyphs))
+	if convert.wordSpacing == 0 && convert.charSpacing == 0 {
+		return spacing
+	}
+	runes := make([]rune, len(glyphs))
+	for i, g := range glyphs {
+		runes[i] = g.r
+	}
+	first, last := visibleRange(runes)
+	for i := first; i >= 0 && i < last; i++ {
+		spacing[i] = convert.charSpacing / scale
+		if isWordSpace(runes[i]) {
+			spacing[i] += convert.wordSpacing / scale
+		}
+	}
+	return spacing
+}
+
+// shapingKey returns the shaper key of the face the current font resolves to.
+func (convert *Converter) shapingKey() string {
+	return fontKey(convert.font.Family, convert.ResolveFontStyle(convert.font.Family, convert.font.Style))
+}
+
+// textWidth returns the shaped width of text in the current font.
+func (convert *Converter) textWidth(text string) (float64, error) {
+	key := convert.shapingKey()
+	if w, ok := convert.shaper.width(key, text); ok {
+		return w * float64(convert.font.Size) / convert.shaper.upem(key), nil
+	}
+	return convert.pdf.MeasureTextWidth(text)
+}
+
 func (convert *Converter) setPosition(x string, y string, line string) error {
 	xv, err := parseFloatCell(x, line)
 	if err != nil {
@@ -704,7 +1243,7 @@ func (convert *Converter) ExternalLink(line string, elements []string) error {
 	convert.pdf.SetX(x)
 	convert.pdf.SetY(y)
 
-	if err := convert.pdf.Text(elements[5]); err != nil {
+	if err := convert.text(elements[5]); err != nil {
 		return fmt.Errorf("%w; line %s", err, line)
 	}
 	y1 := y
@@ -744,7 +1283,7 @@ func (convert *Converter) InternalLinkAnchor(line string, elements []string) err
 	convert.pdf.SetX(x)
 	convert.pdf.SetY(y)
 
-	if err := convert.pdf.Text(elements[5]); err != nil {
+	if err := convert.text(elements[5]); err != nil {
 		return fmt.Errorf("%w; line %s", err, line)
 	}
 	y1 := y
@@ -777,7 +1316,7 @@ func (convert *Converter) InternalLinkLink(line string, elements []string) error
 	convert.pdf.SetX(xv)
 	convert.pdf.SetY(yv)
 
-	if err := convert.pdf.Text(elements[4]); err != nil {
+	if err := convert.text(elements[4]); err != nil {
 		return fmt.Errorf("%w; line %s", err, line)
 	}
 	convert.pdf.SetAnchor(elements[5])
@@ -813,8 +1352,9 @@ func (convert *Converter) GetXY() (x, y float64) {
 	return convert.pdf.GetX(), convert.pdf.GetY()
 }
 
+// MeasureTextWidth returns the width of text in the current font, shaped as Cell writes it.
 func (convert *Converter) MeasureTextWidth(text string) float64 {
-	w, err := convert.pdf.MeasureTextWidth(text)
+	w, err := convert.textWidth(text)
 	if err != nil {
 		panic(err)
 	}
@@ -822,11 +1362,37 @@ func (convert *Converter) MeasureTextWidth(text string) float64 {
 	return w
 }
 
+// MeasureTextWidthWithFont returns the width of text in font, keeping the current font.
+func (convert *Converter) MeasureTextWidthWithFont(text string, font Font) float64 {
+	current := convert.font
+	if font == current {
+		return convert.MeasureTextWidth(text)
+	}
+	convert.SetFont(font.Family, font.Style, font.Size)
+	defer func() {
+		if current.Family != "" {
+			convert.SetFont(current.Family, current.Style, current.Size)
+		}
+		convert.font = current
+	}()
+	return convert.MeasureTextWidth(text)
+}
+
 func (convert *Converter) SetFont(family, style string, size int) {
-	err := convert.pdf.SetFont(family, style, size)
-	if err != nil {
+	family, style = resolveFontAlias(family, style)
+	if err := convert.selectFont(family, style, size); err != nil {
 		panic(err.Error())
 	}
+	convert.font = Font{Family: family, Style: style, Size: size}
+}
+
+// selectFont sets the gopdf font to the face style of family resolves to, loading it first.
+func (convert *Converter) selectFont(family, style string, size int) error {
+	style = convert.ResolveFontStyle(family, style)
+	if err := convert.loadFace(fontKey(family, style)); err != nil {
+		return err
+	}
+	return convert.pdf.SetFont(family, style, size)
 }
 
 func (convert *Converter) NoCompression() {
@@ -834,15 +1400,26 @@ func (convert *Converter) NoCompression() {
 }
 
 func (convert *Converter) WritePdf(filepath string) error {
-	return convert.pdf.WritePdf(filepath)
+	data, err := convert.pdf.GetBytesPdfReturnErr()
+	if err != nil {
+		return err
+	}
+	if data, err = convert.fixToUnicode(data); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath, data, 0644)
 }
 
 func (convert *Converter) CompressLevel(level int) {
 	convert.pdf.SetCompressLevel(level)
 }
 
-func (convert *Converter) GetBytesPdf() (ret []byte) {
-	return convert.pdf.GetBytesPdf()
+func (convert *Converter) GetBytesPdf() ([]byte, error) {
+	data, err := convert.pdf.GetBytesPdfReturnErr()
+	if err != nil {
+		return nil, err
+	}
+	return convert.fixToUnicode(data)
 }
 
 func (convert *Converter) CleanupTempFonts() {
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pageContent returns the inflated content streams of pdf.
+func pageContent(t *testing.T, pdf []byte) []string {
+	t.Helper()
+	var streams []string
+	for {
+		start := bytes.Index(pdf, []byte("stream\n"))
+		if start < 0 {
+			return streams
+		}
+		pdf = pdf[start+len("stream\n"):]
+		end := bytes.Index(pdf, []byte("endstream"))
+		if end < 0 {
+			return streams
+		}
+		if zr, err := zlib.NewReader(bytes.NewReader(pdf[:end])); err == nil {
+			if data, err := ioutil.ReadAll(zr); err == nil && bytes.Contains(data, []byte(" re ")) {
+				streams = append(streams, string(data))
+			}
+		}
+		pdf = pdf[end+len("endstream"):]
+	}
+}
+
+// stateDepths returns the depth of the graphics state stack at each line of content, and
+// whether the stack stays balanced: never popped below zero and empty at the end.
+func stateDepths(content string) (map[string]int, bool) {
+	depths := map[string]int{}
+	depth := 0
+	for _, line := range strings.Split(content, "\n") {
+		for _, op := range strings.Fields(line) {
+			switch op {
+			case "q":
+				depth++
+			case "Q":
+				depth--
+			}
+			if depth < 0 {
+				return depths, false
+			}
+		}
+		if _, ok := depths[line]; !ok {
+			depths[line] = depth
+		}
+	}
+	return depths, depth == 0
+}
+
+func TestRawContentBalanced(t *testing.T) {
+	r := CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *Report) {
+		report.SetFontWithStyle(FontSans, "I", 12) // synthetic oblique: a cm around the text
+		report.PushTransform(TranslateMatrix(10, 20))
+		report.ClipRect(0, 0, 100, 50)
+		report.TextRenderMode(TextRenderStroke)
+		report.Cell(5, 30, "inside")
+		report.TextRenderMode(TextRenderFill)
+		report.PopTransform()
+		report.Path(new(Path).MoveTo(0, 0).LineTo(50, 50), PathStyle{Stroke: "0,0,0", StrokeWidth: 1})
+		report.PopTransform()
+		report.PushTransform(ScaleMatrix(2, 2)) // left open: closed at the end of the page
+		report.Cell(5, 100, "open")
+	}, Detail)
+	pdf, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streams := pageContent(t, pdf)
+	if len(streams) != 1 {
+		t.Fatalf("%d page content streams, want 1", len(streams))
+	}
+	depths, balanced := stateDepths(streams[0])
+	if !balanced {
+		t.Fatalf("unbalanced q/Q in the page content:\n%s", streams[0])
+	}
+	// the raw operators run at the level of the surrounding content, not inside the q/Q pair
+	// of the rectangle that carries them: a cm inside it would have no effect
+	want := map[string]int{
+		"1.000000 -0.000000 -0.000000 1.000000 10.0000 -20.0000 cm":         1,
+		"0.00 841.89 m 100.00 841.89 l 100.00 791.89 l 0.00 791.89 l h W n": 2,
+		"1 Tr": 3,
+		"2.000000 -0.000000 -0.000000 2.000000 0.0000 -841.8900 cm": 1,
+	}
+	for op, depth := range want {
+		got, ok := -1, false
+		for line, d := range depths {
+			if strings.HasSuffix(line, op) {
+				got, ok = d, true
+			}
+		}
+		if !ok {
+			t.Errorf("content without %q", op)
+		} else if got != depth {
+			t.Errorf("%q at graphics state depth %d, want %d", op, got, depth)
+		}
+	}
+
+	convert := r.converter
+	if err := convert.rawContent("q\n1 0 0 1 5 5 cm"); err == nil {
+		t.Error("raw content with an unmatched q accepted")
+	}
+	if err := convert.rawContent("Q\nq"); err == nil {
+		t.Error("raw content popping below its level accepted")
+	}
+}
+
+// TestContentHookVersion pins the gopdf version whose content layout rawContent relies on.
+func TestContentHookVersion(t *testing.T) {
+	mod, err := ioutil.ReadFile("../go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := ""
+	for _, line := range strings.Split(string(mod), "\n") {
+		fields := strings.Fields(line)
+		if fields = append(fields, "", ""); fields[0] == "require" {
+			fields = fields[1:]
+		}
+		if fields[0] == gopdfModule {
+			version = fields[1]
+		}
+	}
+	if version != gopdfVersion {
+		t.Errorf("go.mod requires gopdf %q, the content hook is written for %s", version, gopdfVersion)
+	}
+	if err := checkGopdfVersion(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTextClipRegion(t *testing.T) {
+	for _, compress := range []bool{true, false} {
+		r := CreateReport()
+		if err := r.SetPage("A4", "P"); err != nil {
+			t.Fatal(err)
+		}
+		if !compress {
+			r.NoCompression()
+		}
+		r.RegisterExecutor(func(report *Report) {
+			report.SetFontWithStyle(FontSans, "I", 20)
+			report.PushTextClip()
+			report.TextRenderMode(TextRenderClip)
+			report.Cell(50, 100, "first")
+			report.Cell(50, 130, "second")
+			report.TextRenderMode(TextRenderFill)
+			report.BackgroundColor(40, 70, 200, 80, "0,128,0", "0000")
+			report.PopTransform()
+		}, Detail)
+		pdf, err := r.GetBytesPdf()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the objects are still where the cross-reference table says
+		offsets, _, ok := readXref(pdf)
+		if !ok {
+			t.Fatal("unreadable cross-reference table")
+		}
+		for i, offset := range offsets {
+			if !bytes.HasPrefix(pdf[offset:], []byte(strconv.Itoa(i+1)+" 0 obj\n")) {
+				t.Errorf("object %d not at offset %d", i+1, offset)
+			}
+		}
+
+		streams := pageContent(t, pdf)
+		if len(streams) != 1 {
+			t.Fatalf("%d page content streams, want 1", len(streams))
+		}
+		content := streams[0]
+		if _, balanced := stateDepths(content); !balanced {
+			t.Errorf("unbalanced q/Q in the page content:\n%s", content)
+		}
+		if strings.Count(content, "BT\n") != 1 {
+			t.Fatalf("clipping text not joined into one text object:\n%s", content)
+		}
+		// both lines in the text object, skewed around their baselines, then the rectangle
+		text := content[strings.Index(content, "7 Tr\nBT\n"):]
+		first := strings.Index(text, "1 0 0.2126 1 50.00 741.89 Tm\n")
+		second := strings.Index(text, "1 0 0.2126 1 50.00 711.89 Tm\n")
+		end := strings.Index(text, "ET\n0 Tr\n")
+		fill := strings.Index(text, "0.000 0.502 0.000 rg")
+		if first < 0 || second < first || end < second || fill < end {
+			t.Errorf("clipping text written as:\n%s", text)
+		}
+	}
+}
+
+func TestTextClipRegionPop(t *testing.T) {
+	r := CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *Report) {
+		report.SetFontWithStyle(FontSans, "", 20)
+		report.PushTextClip()
+		report.TextRenderMode(TextRenderFillClip)
+		report.Cell(50, 100, "first")
+		report.Cell(50, 130, "second")
+		report.TextRenderMode(TextRenderFill)
+		report.PopTransform()
+		report.PushTextClip() // no clipping text: no text object
+		report.PopTransform()
+	}, Detail)
+	pdf, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := pageContent(t, pdf)[0]
+	if _, balanced := stateDepths(content); !balanced {
+		t.Errorf("unbalanced q/Q in the page content:\n%s", content)
+	}
+	// the painted lines, then the clipping text of both in one text object before the pop
+	clip := strings.Index(content, "7 Tr\nBT\n")
+	if clip < 0 || strings.Count(content, "7 Tr\n") != 1 || strings.Count(content[:clip], "BT\n") != 2 {
+		t.Fatalf("clipping text not written after the painted lines:\n%s", content)
+	}
+	text := content[clip : clip+strings.Index(content[clip:], "ET\n")]
+	if strings.Count(text, " Tm\n") != 2 || strings.Count(text, "TJ") != 2 {
+		t.Errorf("clipping text written as:\n%s", text)
+	}
+	// the font and the glyphs are the ones of the painted lines
+	painted := content[strings.Index(content, "BT\n"):]
+	font := painted[strings.Index(painted, "/F"):strings.Index(painted, " Tf")]
+	painted = painted[strings.Index(painted, "[<") : strings.Index(painted, ">]")+2]
+	if !strings.Contains(text, " Tm\n"+font+" Tf\n"+painted+" TJ") {
+		t.Errorf("clipping text %q, want the painted glyphs %q in %q", text, painted, font)
+	}
+	if rest := content[clip+len(text):]; !strings.HasPrefix(rest, "ET\n0 Tr\n") || strings.Contains(rest, "BT\n") {
+		t.Errorf("content after the clipping text:\n%s", rest)
+	}
+}
+
+func TestTransparency(t *testing.T) {
+	r := CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *Report) {
+		report.SetFontWithStyle(FontSans, "", 20)
+		report.PushTransform(TranslateMatrix(10, 10))
+		report.SetTransparency(0.5, 0.25) // outlives the transform
+		report.Path(new(Path).MoveTo(0, 0).LineTo(50, 50).LineTo(0, 50), PathStyle{Fill: "255,0,0", Stroke: "0,0,0", StrokeWidth: 1})
+		report.PopTransform()
+		report.Cell(50, 100, "half")
+		if fill, stroke := report.GetTransparency(); fill != 0.5 || stroke != 0.25 {
+			t.Errorf("transparency %v, %v, want 0.5, 0.25", fill, stroke)
+		}
+		report.ClearTransparency()
+		report.Path(new(Path).MoveTo(0, 0).LineTo(50, 50), PathStyle{Stroke: "0,0,0", StrokeWidth: 1})
+	}, Detail)
+	pdf, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// fill and stroke opacity of the graphics states by name
+	states := map[string][2]string{}
+	for _, m := range regexp.MustCompile(`(\d+) 0 obj\n<<\n\t/Type /ExtGState\n\t/ca (\S+)\n\t/CA (\S+)\n`).FindAllStringSubmatch(string(pdf), -1) {
+		states["/GS"+m[1]] = [2]string{m[2], m[3]}
+	}
+	streams := pageContent(t, pdf)
+	if len(streams) != 1 {
+		t.Fatalf("%d page content streams, want 1", len(streams))
+	}
+
+	// opacity in effect where the paths are painted and the text is written
+	var (
+		painted []string
+		stack   = [][2]string{{"1.000", "1.000"}}
+	)
+	for _, line := range strings.Split(streams[0], "\n") {
+		current := &stack[len(stack)-1]
+		switch {
+		case line == "q":
+			stack = append(stack, *current)
+		case line == "Q":
+			stack = stack[:len(stack)-1]
+		case strings.HasSuffix(line, " gs"):
+			state, ok := states[strings.TrimSuffix(line, " gs")]
+			if !ok {
+				t.Fatalf("graphics state %q not defined", line)
+			}
+			*current = state
+		case line == "B" || line == "S" || line == "BT":
+			painted = append(painted, line+" "+current[0]+" "+current[1])
+		}
+	}
+	want := []string{"B 0.500 0.250", "BT 0.500 0.250", "S 1.000 1.000"}
+	if strings.Join(painted, ", ") != strings.Join(want, ", ") {
+		t.Errorf("painted %q, want %q", painted, want)
+	}
+}
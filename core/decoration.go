@@ -0,0 +1,163 @@
+package core
+
+import (
+	"strings"
+
+	tsfont "github.com/go-text/typesetting/font"
+)
+
+// Text decorations are lines the Converter draws along every written text: underline ("U"),
+// strikethrough ("S") and overline ("O") requested by the letters of Font.Style, and a highlight
+// behind the text set by "HC" records. Their position and thickness come from the post
+// (underline) and OS/2 (strikeout) tables of the face; the lines take the text color.
+
+// Font style letters of the decoration lines, combined with the face styles of FontMap.Style.
+const (
+	FontStyleUnderline = "U"
+	FontStyleStrike    = "S"
+	FontStyleOverline  = "O"
+)
+
+// Decoration metrics used for faces that do not define them, relative to the font size.
+const (
+	defaultUnderlinePosition  = -0.1 // top of the underline, above the baseline
+	defaultUnderlineThickness = 0.05
+	defaultStrikePosition     = 0.3 // top of the strikethrough, above the baseline
+)
+
+// decorationMetrics holds where the decoration lines of a face go, per em, y up from the baseline.
+type decorationMetrics struct {
+	underlinePosition  float64 // top of the underline
+	underlineThickness float64
+	strikePosition     float64 // top of the strikethrough
+	strikeThickness    float64
+}
+
+// newDecorationMetrics reads the decoration metrics of face, falling back to defaults for the
+// values the face leaves zero.
+func newDecorationMetrics(face *tsfont.Face) decorationMetrics {
+	m := decorationMetrics{
+		underlinePosition:  defaultUnderlinePosition,
+		underlineThickness: defaultUnderlineThickness,
+		strikePosition:     defaultStrikePosition,
+	}
+	if face != nil {
+		upem := float64(face.Upem())
+		if v := float64(face.LineMetric(tsfont.UnderlinePosition)); v != 0 {
+			m.underlinePosition = v / upem
+		}
+		if v := float64(face.LineMetric(tsfont.UnderlineThickness)); v > 0 {
+			m.underlineThickness = v / upem
+		}
+		if v := float64(face.LineMetric(tsfont.StrikethroughPosition)); v > 0 {
+			m.strikePosition = v / upem
+		}
+		if v := float64(face.LineMetric(tsfont.StrikethroughThickness)); v > 0 {
+			m.strikeThickness = v / upem
+		}
+	}
+	if m.strikeThickness == 0 {
+		m.strikeThickness = m.underlineThickness
+	}
+	return m
+}
+
+// fontDecorations returns the decoration letters of style, in the order "U", "S", "O".
+func fontDecorations(style string) string {
+	style = strings.ToUpper(style)
+	decorations := ""
+	for _, d := range []string{FontStyleUnderline, FontStyleStrike, FontStyleOverline} {
+		if strings.Contains(style, d) {
+			decorations += d
+		}
+	}
+	return decorations
+}
+
+// decorationMetrics returns the decoration metrics of the face the current font resolves to.
+func (convert *Converter) decorationMetrics() decorationMetrics {
+	if m, ok := convert.metrics(convert.shapingKey()); ok {
+		return m.decoration
+	}
+	return newDecorationMetrics(nil)
+}
+
+// TextHighlight sets the color filled behind the following text, "HC" without a color removes it.
+func (convert *Converter) TextHighlight(line string, elements []string) error {
+	if len(elements) == 1 || len(elements) == 2 && elements[1] == "" {
+		convert.highlight = nil
+		return nil
+	}
+	if err := checkLength(line, elements, 4); err != nil {
+		return err
+	}
+	var rgb [3]uint8
+	for i := range rgb {
+		v, err := parseIntCell(elements[i+1], line)
+		if err != nil {
+			return err
+		}
+		rgb[i] = uint8(v)
+	}
+	convert.highlight = &rgb
+	return nil
+}
+
+// writtenWidth returns the width text takes when written with the current font and spacing.
+func (convert *Converter) writtenWidth(text string) (float64, error) {
+	key := convert.shapingKey()
+	glyphs, ok := convert.shaper.shape(key, text, convert.direction)
+	if !ok {
+		return convert.pdf.MeasureTextWidth(text)
+	}
+	scale := float64(convert.font.Size) / convert.shaper.upem(key)
+	width := 0.0
+	for i, spacing := range convert.glyphSpacing(glyphs, scale) {
+		width += glyphs[i].advance + spacing
+	}
+	return width * scale, nil
+}
+
+// decorate fills the highlight behind the text of the given width written at (x, baseline), if
+// there is one, or draws the decoration lines of the current font over it.
+func (convert *Converter) decorate(x, baseline, width float64, highlight bool) error {
+	if width <= 0 {
+		return nil
+	}
+	size := float64(convert.font.Size)
+	// rectangles by their distance above the baseline
+	var rects [][2]float64
+	fill := convert.textColor
+	if highlight {
+		if convert.highlight == nil {
+			return nil
+		}
+		fill = *convert.highlight
+		ascender, descender := convert.GetFontMetricsWithStyle(convert.font.Family, convert.font.Style, size)
+		rects = append(rects, [2]float64{ascender, ascender - descender})
+	} else {
+		decorations := fontDecorations(convert.font.Style)
+		if decorations == "" {
+			return nil
+		}
+		m := convert.decorationMetrics()
+		if strings.Contains(decorations, FontStyleUnderline) {
+			rects = append(rects, [2]float64{m.underlinePosition * size, m.underlineThickness * size})
+		}
+		if strings.Contains(decorations, FontStyleStrike) {
+			rects = append(rects, [2]float64{m.strikePosition * size, m.strikeThickness * size})
+		}
+		if strings.Contains(decorations, FontStyleOverline) {
+			ascender, _ := convert.GetFontMetricsWithStyle(convert.font.Family, convert.font.Style, size)
+			rects = append(rects, [2]float64{ascender, m.underlineThickness * size})
+		}
+	}
+
+	convert.pdf.SaveGraphicsState()
+	convert.pdf.SetFillColor(fill[0], fill[1], fill[2])
+	for _, r := range rects {
+		convert.pdf.RectFromUpperLeftWithStyle(x, baseline-r[0], width, r[1], "F")
+	}
+	convert.pdf.RestoreGraphicsState()
+	return nil
+}
@@ -1,5 +1,7 @@
 package core
 
+import "strings"
+
 // Logical font names for embedded Alibaba PuHuiTi. Layout uses PDF points (pt) only;
 // text uses registered TTF — not PDF built-in fonts.
 const (
@@ -7,7 +9,53 @@ const (
 	FontSansBold = "gopdf-sans-bold"
 )
 
+// Font styles understood by FontMap.Style and core.Font.Style (decorations such as "U" may be added to any of them).
+const (
+	FontStyleRegular    = ""
+	FontStyleBold       = "B"
+	FontStyleItalic     = "I"
+	FontStyleBoldItalic = "BI"
+)
+
+// FontFamily groups the faces of one family. Regular is required, the other faces are optional;
+// a missing face falls back to the closest registered one (see Converter.ResolveFontStyle).
+type FontFamily struct {
+	Name       string
+	Regular    *FontMap
+	Bold       *FontMap
+	Italic     *FontMap
+	BoldItalic *FontMap
+}
+
+// fontMaps flattens the family into FontMap entries that share Name and differ by Style.
+func (family FontFamily) fontMaps() []*FontMap {
+	faces := []struct {
+		style string
+		face  *FontMap
+	}{
+		{FontStyleRegular, family.Regular},
+		{FontStyleBold, family.Bold},
+		{FontStyleItalic, family.Italic},
+		{FontStyleBoldItalic, family.BoldItalic},
+	}
+
+	out := make([]*FontMap, 0, len(faces))
+	for _, f := range faces {
+		if f.face == nil {
+			continue
+		}
+		c := *f.face
+		c.FontName = family.Name
+		c.Style = f.style
+		out = append(out, &c)
+	}
+	return out
+}
+
 // DefaultFontMaps returns embedded Alibaba Regular and Bold (no filesystem paths).
+// FontSans is registered as a family whose "B" face is the bold file, so
+// Font{Family: FontSans, Style: "B"} selects the bold face. FontSansBold is not registered
+// on its own: it is an alias of that face (see resolveFontAlias).
 func DefaultFontMaps() []*FontMap {
 	reg := make([]byte, len(embeddedAlibabaSans))
 	copy(reg, embeddedAlibabaSans)
@@ -15,10 +63,25 @@ func DefaultFontMaps() []*FontMap {
 	copy(bold, embeddedAlibabaSansBold)
 	return []*FontMap{
 		{FontName: FontSans, Data: reg, FileName: "AlibabaPuHuiTi-Regular.ttf"},
-		{FontName: FontSansBold, Data: bold, FileName: "AlibabaPuHuiTi-Bold.ttf"},
+		{FontName: FontSans, Style: FontStyleBold, Data: bold, FileName: "AlibabaPuHuiTi-Bold.ttf"},
 	}
 }
 
+// fontAliases maps family names kept for compatibility onto a face of a registered family,
+// so that the face is embedded once.
+var fontAliases = map[string]struct{ family, style string }{
+	FontSansBold: {FontSans, FontStyleBold},
+}
+
+// resolveFontAlias returns the family and style that family stands for, adding the face of
+// an alias to the requested style. Other families are returned as they are.
+func resolveFontAlias(family, style string) (string, string) {
+	if alias, ok := fontAliases[family]; ok {
+		return alias.family, alias.style + style
+	}
+	return family, style
+}
+
 func cloneFontMaps(src []*FontMap) []*FontMap {
 	if len(src) == 0 {
 		return nil
@@ -33,3 +96,35 @@ func cloneFontMaps(src []*FontMap) []*FontMap {
 	}
 	return out
 }
+
+// normalizeFontStyle returns the face part of style ("", "B", "I" or "BI"), dropping decorations.
+func normalizeFontStyle(style string) string {
+	style = strings.ToUpper(style)
+	face := ""
+	if strings.Contains(style, "B") {
+		face += "B"
+	}
+	if strings.Contains(style, "I") {
+		face += "I"
+	}
+	return face
+}
+
+// fontStyleFallbacks lists the faces tried, in order, when style is requested.
+func fontStyleFallbacks(style string) []string {
+	switch normalizeFontStyle(style) {
+	case FontStyleBoldItalic:
+		return []string{FontStyleBoldItalic, FontStyleBold, FontStyleItalic, FontStyleRegular}
+	case FontStyleBold:
+		return []string{FontStyleBold, FontStyleRegular}
+	case FontStyleItalic:
+		return []string{FontStyleItalic, FontStyleRegular}
+	default:
+		return []string{FontStyleRegular}
+	}
+}
+
+func fontKey(family, style string) string {
+	family, style = resolveFontAlias(family, style)
+	return family + "|" + normalizeFontStyle(style)
+}
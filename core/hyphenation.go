@@ -0,0 +1,250 @@
+package core
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Words are hyphenated with Liang's algorithm, reading TeX pattern files: the body of
+// \patterns{...} holds the patterns, the body of \hyphenation{...} the exceptions, "%" starts a
+// comment. A file without \patterns is read as a plain list of patterns.
+
+//go:embed hyphenation/*.tex
+var embeddedHyphenation embed.FS
+
+// Hyphenation configures the hyphenation of a text component.
+type Hyphenation struct {
+	Language string // pattern language, e.g. "en-US" or "de"; empty disables hyphenation
+	MinLeft  int    // minimum number of characters before a hyphen, 0 for the language default
+	MinRight int    // minimum number of characters after a hyphen, 0 for the language default
+}
+
+// Hyphenator finds the hyphenation points of words.
+type Hyphenator struct {
+	patterns   map[string][]uint8 // letters (with "." at word boundaries) -> values between them
+	exceptions map[string][]int   // word -> hyphen positions
+	maxLength  int                // runes of the longest pattern
+
+	MinLeft, MinRight int // defaults for Hyphenation.MinLeft and MinRight
+}
+
+type hyphenationLanguage struct {
+	file              string
+	minLeft, minRight int
+}
+
+var (
+	// bundled pattern files, key: language without region
+	bundledHyphenation = map[string]hyphenationLanguage{
+		"en": {file: "hyphenation/hyph-en-us.tex", minLeft: 2, minRight: 3},
+		"de": {file: "hyphenation/hyph-de-1996.tex", minLeft: 2, minRight: 2},
+	}
+
+	hyphenatorsMu sync.Mutex
+	hyphenators   = map[string]*Hyphenator{}
+)
+
+// NewHyphenator reads a TeX pattern file.
+func NewHyphenator(r io.Reader) (*Hyphenator, error) {
+	h := &Hyphenator{
+		patterns:   make(map[string][]uint8),
+		exceptions: make(map[string][]int),
+		MinLeft:    2,
+		MinRight:   2,
+	}
+
+	var (
+		plain   []string // words outside of \patterns and \hyphenation
+		section string
+		found   bool
+	)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '%'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.NewReplacer("{", " { ", "}", " } ").Replace(line)
+		for _, word := range strings.Fields(line) {
+			switch {
+			case word == `\patterns` || word == `\hyphenation`:
+				section = word
+				found = true
+			case word == "{":
+			case word == "}":
+				section = ""
+			case section == `\patterns`:
+				h.addPattern(word)
+			case section == `\hyphenation`:
+				h.addException(word)
+			case strings.HasPrefix(word, `\`):
+			default:
+				plain = append(plain, word)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		for _, word := range plain {
+			h.addPattern(word)
+		}
+	}
+	if len(h.patterns) == 0 && len(h.exceptions) == 0 {
+		return nil, fmt.Errorf("no hyphenation patterns")
+	}
+	return h, nil
+}
+
+func (h *Hyphenator) addPattern(pattern string) {
+	var (
+		letters []rune
+		values  = []uint8{0}
+	)
+	for _, r := range pattern {
+		if r >= '0' && r <= '9' {
+			values[len(values)-1] = uint8(r - '0')
+			continue
+		}
+		letters = append(letters, unicode.ToLower(r))
+		values = append(values, 0)
+	}
+	if len(letters) == 0 {
+		return
+	}
+	h.patterns[string(letters)] = values
+	if len(letters) > h.maxLength {
+		h.maxLength = len(letters)
+	}
+}
+
+func (h *Hyphenator) addException(word string) {
+	var (
+		letters   []rune
+		positions []int
+	)
+	for _, r := range word {
+		if r == '-' {
+			positions = append(positions, len(letters))
+			continue
+		}
+		letters = append(letters, unicode.ToLower(r))
+	}
+	h.exceptions[string(letters)] = positions
+}
+
+// Hyphenate returns the rune offsets in word at which it may be hyphenated, keeping at least
+// minLeft runes before and minRight runes after the hyphen (the Hyphenator defaults if 0).
+func (h *Hyphenator) Hyphenate(word string, minLeft, minRight int) []int {
+	if minLeft <= 0 {
+		minLeft = h.MinLeft
+	}
+	if minRight <= 0 {
+		minRight = h.MinRight
+	}
+	letters := []rune(strings.ToLower(word))
+	n := len(letters)
+	if n < minLeft+minRight {
+		return nil
+	}
+
+	var positions []int
+	if exception, ok := h.exceptions[string(letters)]; ok {
+		for _, p := range exception {
+			if p >= minLeft && n-p >= minRight {
+				positions = append(positions, p)
+			}
+		}
+		return positions
+	}
+
+	text := make([]rune, 0, n+2)
+	text = append(append(append(text, '.'), letters...), '.')
+	values := make([]uint8, len(text)+1)
+	for i := range text {
+		for j := i + 1; j <= len(text) && j-i <= h.maxLength; j++ {
+			pattern, ok := h.patterns[string(text[i:j])]
+			if !ok {
+				continue
+			}
+			for k, v := range pattern {
+				if v > values[i+k] {
+					values[i+k] = v
+				}
+			}
+		}
+	}
+	for p := minLeft; p <= n-minRight; p++ {
+		if values[p+1]%2 == 1 { // before letters[p], text[p+1]
+			positions = append(positions, p)
+		}
+	}
+	return positions
+}
+
+// hyphens returns the hyphenation points function of the configured language, nil when
+// hyphenation is disabled or the language has no patterns.
+func (hyphenation Hyphenation) hyphens() func(word string) []int {
+	if hyphenation.Language == "" {
+		return nil
+	}
+	h, err := GetHyphenator(hyphenation.Language)
+	if err != nil {
+		return nil
+	}
+	return func(word string) []int {
+		return h.Hyphenate(word, hyphenation.MinLeft, hyphenation.MinRight)
+	}
+}
+
+// RegisterHyphenator makes h the hyphenator of language, replacing a bundled one.
+func RegisterHyphenator(language string, h *Hyphenator) {
+	hyphenatorsMu.Lock()
+	defer hyphenatorsMu.Unlock()
+	hyphenators[normalizeLanguage(language)] = h
+}
+
+// GetHyphenator returns the hyphenator of language: a registered one, or the bundled patterns
+// of its base language ("en-US" and "de" are bundled).
+func GetHyphenator(language string) (*Hyphenator, error) {
+	language = normalizeLanguage(language)
+	base := language
+	if i := strings.IndexByte(base, '-'); i >= 0 {
+		base = base[:i]
+	}
+
+	hyphenatorsMu.Lock()
+	defer hyphenatorsMu.Unlock()
+	for _, key := range []string{language, base} {
+		if h, ok := hyphenators[key]; ok {
+			return h, nil
+		}
+	}
+
+	bundled, ok := bundledHyphenation[base]
+	if !ok {
+		return nil, fmt.Errorf("no hyphenation patterns for language %q", language)
+	}
+	file, err := embeddedHyphenation.Open(bundled.file)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h, err := NewHyphenator(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w; file %s", err, bundled.file)
+	}
+	h.MinLeft, h.MinRight = bundled.minLeft, bundled.minRight
+	hyphenators[base] = h
+	return h, nil
+}
+
+func normalizeLanguage(language string) string {
+	return strings.ToLower(strings.Replace(language, "_", "-", -1))
+}
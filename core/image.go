@@ -0,0 +1,50 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/signintech/gopdf"
+)
+
+// Images can be registered in memory with Report.RegisterImage. An "I" record whose path starts
+// with ImageKeyPrefix references the registered image by its key instead of a file, and its
+// bytes are handed to the PDF writer directly. The key is the SHA-256 of the image data, so
+// identical images share one registry entry and are embedded as a single XObject however many
+// times and on however many pages they are placed.
+
+// ImageKeyPrefix marks the path of an "I" record as the key of a registered image.
+const ImageKeyPrefix = "mem:"
+
+// registryKey returns the registry key of the path of an "I" record, if it references one.
+func registryKey(path string) (string, bool) {
+	if !strings.HasPrefix(path, ImageKeyPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(path, ImageKeyPrefix), true
+}
+
+// registerImage stores data in the image registry, unless identical data is already there, and
+// returns its key.
+func (convert *Converter) registerImage(data []byte) string {
+	if convert.images == nil {
+		convert.images = make(map[string][]byte)
+	}
+	sum := sha256.Sum256(data)
+	key := hex.EncodeToString(sum[:])
+	if _, ok := convert.images[key]; !ok {
+		convert.images[key] = data
+	}
+	return key
+}
+
+// imageHolder returns the registered image of key for the PDF writer.
+func (convert *Converter) imageHolder(key string) (gopdf.ImageHolder, error) {
+	data, ok := convert.images[key]
+	if !ok {
+		return nil, fmt.Errorf("image %q is not registered", key)
+	}
+	return gopdf.ImageHolderByBytes(data)
+}
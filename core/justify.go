@@ -0,0 +1,121 @@
+package core
+
+import (
+	"unicode"
+)
+
+// Justified lines are stretched to their column with spacing the Converter adds while writing
+// glyphs: word spacing after every space between words ("WS" records), character spacing
+// between every two characters ("CS" records). Spaces before the first and after the last
+// visible character of a written text get no spacing, so padding and trailing blanks keep
+// their width.
+
+// isWordSpace reports whether word spacing applies to r.
+func isWordSpace(r rune) bool {
+	return r == ' ' || r == '\u00a0'
+}
+
+// isCJK reports whether r belongs to a script written without spaces between words.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Bopomofo)
+}
+
+// visibleRange returns the index of the first and the last rune of text that is not a word
+// space, -1 and -1 if there is none.
+func visibleRange(text []rune) (first, last int) {
+	first, last = -1, -1
+	for i, r := range text {
+		if isWordSpace(r) {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	return first, last
+}
+
+// JustifySpacing returns the word and character spacing that widen the line text by extra
+// points. See JustifyLine.
+func JustifySpacing(text string, extra float64) (word, char float64) {
+	word, char, _, _ = JustifyLine([]string{text}, extra)
+	return word, char
+}
+
+// JustifyLine spreads extra points over the gaps of a line written as consecutive texts, each
+// text starting where the previous one ends. Lines with Chinese or Japanese characters spread
+// extra over all gaps between characters, other lines over the spaces between words; lines
+// without gaps, and lines that are already too wide (extra <= 0), are not stretched.
+//
+// Every text is written with the returned word and character spacing; shifts holds how far
+// each text moves from its unjustified position, grows how much wider it gets.
+func JustifyLine(texts []string, extra float64) (word, char float64, shifts, grows []float64) {
+	shifts = make([]float64, len(texts))
+	grows = make([]float64, len(texts))
+
+	var (
+		line   []rune
+		starts = make([]int, len(texts)+1)
+	)
+	for i, text := range texts {
+		starts[i] = len(line)
+		line = append(line, []rune(text)...)
+	}
+	starts[len(texts)] = len(line)
+
+	first, last := visibleRange(line)
+	if extra <= 0 || first < 0 || first == last {
+		return 0, 0, shifts, grows
+	}
+	for _, r := range line[first : last+1] {
+		if isCJK(r) {
+			char = extra / float64(last-first)
+			break
+		}
+	}
+	if char == 0 {
+		spaces := 0
+		for _, r := range line[first:last] {
+			if isWordSpace(r) {
+				spaces++
+			}
+		}
+		if spaces == 0 {
+			return 0, 0, shifts, grows
+		}
+		word = extra / float64(spaces)
+	}
+
+	// gap p follows line[p], the gaps of the line are line[first:last]. A text widens the gaps
+	// inside its own visible range and moves by the gaps before that range.
+	gap := func(p int) float64 {
+		if p < first || p >= last {
+			return 0
+		}
+		if isWordSpace(line[p]) {
+			return char + word
+		}
+		return char
+	}
+	sum := 0.0
+	for i := range texts {
+		f, l := visibleRange(line[starts[i]:starts[i+1]])
+		if f < 0 {
+			f, l = starts[i+1]-starts[i], starts[i+1]-starts[i]
+		}
+		f, l = f+starts[i], l+starts[i]
+		for p := starts[i]; p < f; p++ {
+			sum += gap(p)
+		}
+		shifts[i] = sum
+		for p := f; p < l; p++ {
+			grows[i] += gap(p)
+		}
+		sum += grows[i]
+		for p := l; p < starts[i+1]; p++ {
+			sum += gap(p)
+		}
+	}
+	return word, char, shifts, grows
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/go-text/typesetting/segmenter"
+)
+
+// Text components wrap paragraphs at the break opportunities of the Unicode Line Breaking
+// Algorithm (UAX #14), tightened by the kinsoku rules of Chinese and Japanese typesetting. With
+// hyphenation enabled, the word that does not fit any more is broken at a hyphenation point that
+// fits, and the line ends with a hyphen. A word wider than the line is broken between grapheme
+// clusters.
+
+const (
+	// kinsokuNoLineStart holds characters that must not start a line.
+	kinsokuNoLineStart = ")]}）］｝〕〉》」』】〙〗〟’”｠»" +
+		"、。，．：；？！‼⁇⁈⁉・…‥ー‐゠–〜～" +
+		"ヽヾゝゞ々〻ぁぃぅぇぉっゃゅょゎゕゖァィゥェォッャュョヮヵヶㇰㇱㇲㇳㇴㇵㇶㇷㇸㇹㇺㇻㇼㇽㇾㇿ" +
+		"%％‰℃"
+	// kinsokuNoLineEnd holds characters that must not end a line.
+	kinsokuNoLineEnd = "([{（［｛〔〈《「『【〘〖〝‘“｟«"
+)
+
+type lineBreak uint8
+
+const (
+	breakNone      lineBreak = iota // no break before the rune
+	breakAllowed                    // a line may start at the rune
+	breakMandatory                  // a line must start at the rune
+)
+
+// lineBreaks returns the break opportunities of text: element i tells whether a line may start
+// at text[i]; element len(text) is the end of the text.
+func lineBreaks(text []rune) []lineBreak {
+	breaks := make([]lineBreak, len(text)+1)
+	var seg segmenter.Segmenter
+	seg.Init(text)
+	iter := seg.LineIterator()
+	for iter.Next() {
+		line := iter.Line()
+		end := line.Offset + len(line.Text)
+		breaks[end] = breakAllowed
+		if line.IsMandatoryBreak {
+			breaks[end] = breakMandatory
+		}
+	}
+
+	for i := 1; i < len(text); i++ {
+		if breaks[i] != breakAllowed {
+			continue
+		}
+		if strings.ContainsRune(kinsokuNoLineStart, text[i]) || strings.ContainsRune(kinsokuNoLineEnd, text[i-1]) {
+			breaks[i] = breakNone
+		}
+	}
+	breaks[len(text)] = breakMandatory
+	return breaks
+}
+
+// trimLineEnd drops the spaces and line terminators a line ends with; they take no room.
+func trimLineEnd(line []rune) []rune {
+	n := len(line)
+	for n > 0 {
+		switch line[n-1] {
+		case ' ', '\t', '\n', '\r', '\v', '\f', '\u0085', '\u2028', '\u2029':
+			n--
+			continue
+		}
+		break
+	}
+	return line[:n]
+}
+
+// fitLine returns the number of runes of text that make up the next line, breaks being the
+// break opportunities of text, and whether the line ends with a hyphen. hyphens returns the
+// hyphenation points of a word, nil disables hyphenation. See FitLineWithHyphenation.
+func fitLine(text []rune, breaks []lineBreak, width float64, measure func(string) float64, force bool,
+	hyphens func(word string) []int) (int, bool) {
+	fit := 0
+	for i := 1; i <= len(text); i++ {
+		if breaks[i] == breakNone {
+			continue
+		}
+		if measure(string(trimLineEnd(text[:i]))) > width {
+			break
+		}
+		fit = i
+		if breaks[i] == breakMandatory {
+			return fit, false
+		}
+	}
+
+	if hyphens != nil {
+		end := fit + 1
+		for end < len(text) && breaks[end] == breakNone {
+			end++
+		}
+		if p := hyphenationPoint(text, fit, end, width, measure, hyphens); p > fit {
+			return p, true
+		}
+	}
+	if fit > 0 || !force || len(text) == 0 {
+		return fit, false
+	}
+
+	// the first word does not fit: break it between grapheme clusters, at least one
+	var seg segmenter.Segmenter
+	seg.Init(text)
+	iter := seg.GraphemeIterator()
+	for iter.Next() {
+		g := iter.Grapheme()
+		end := g.Offset + len(g.Text)
+		if fit > 0 && measure(string(text[:end])) > width {
+			break
+		}
+		fit = end
+	}
+	return fit, false
+}
+
+// hyphenationPoint returns the last hyphenation point of the word in text[start:end] at which
+// text[:point] and a hyphen fit in width, 0 if there is none.
+func hyphenationPoint(text []rune, start, end int, width float64, measure func(string) float64,
+	hyphens func(word string) []int) int {
+	isWordRune := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsMark(r) }
+	for start < end && !isWordRune(text[start]) {
+		start++
+	}
+	wordEnd := start
+	for wordEnd < end && isWordRune(text[wordEnd]) {
+		wordEnd++
+	}
+	if wordEnd == start {
+		return 0
+	}
+
+	points := hyphens(string(text[start:wordEnd]))
+	for i := len(points) - 1; i >= 0; i-- {
+		p := start + points[i]
+		if measure(string(text[:p])+"-") <= width {
+			return p
+		}
+	}
+	return 0
+}
+
+// FitLine returns the number of runes of text that start a line no wider than width: the text up
+// to its last break opportunity that fits, with spaces at the break included but not measured.
+// measure returns the width of a string. When not even the first word fits, force breaks the word
+// between grapheme clusters (taking at least one), otherwise FitLine returns 0.
+func FitLine(text []rune, width float64, measure func(string) float64, force bool) int {
+	n, _ := fitLine(text, lineBreaks(text), width, measure, force, nil)
+	return n
+}
+
+// FitLineWithHyphenation is FitLine, also breaking the word that does not fit at a hyphenation
+// point; hyphen reports whether the line must be written with a trailing hyphen.
+func FitLineWithHyphenation(text []rune, width float64, measure func(string) float64, force bool,
+	hyphenation Hyphenation) (n int, hyphen bool) {
+	return fitLine(text, lineBreaks(text), width, measure, force, hyphenation.hyphens())
+}
+
+// WrapText splits the paragraph text into lines no wider than width, measured by measure. Lines
+// end at break opportunities and lose their trailing spaces; an empty text is one empty line.
+func WrapText(text string, width float64, measure func(string) float64) []string {
+	return WrapTextWithHyphenation(text, width, measure, Hyphenation{})
+}
+
+// WrapTextWithHyphenation is WrapText, also breaking words at their hyphenation points. Lines
+// broken inside a word end with a hyphen.
+func WrapTextWithHyphenation(text string, width float64, measure func(string) float64, hyphenation Hyphenation) []string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+
+	var (
+		breaks  = lineBreaks(runes)
+		hyphens = hyphenation.hyphens()
+		lines   []string
+	)
+	for start := 0; start < len(runes); {
+		n, hyphen := fitLine(runes[start:], breaks[start:], width, measure, true, hyphens)
+		line := string(trimLineEnd(runes[start : start+n]))
+		if hyphen {
+			line += "-"
+		}
+		lines = append(lines, line)
+		start += n
+	}
+	return lines
+}
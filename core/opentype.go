@@ -0,0 +1,585 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// gopdf only embeds and subsets TrueType (glyf) outlines from single-face files. Fonts are
+// rewritten before they are handed over:
+//   - a face of a TrueType/OpenType collection (.ttc/.otc) is copied into a standalone file;
+//   - CFF outlines (.otf) are converted to quadratic glyf outlines, the other tables
+//     (hmtx, OS/2, post, name, GPOS, GSUB, ...) are kept as they are;
+//   - Apple TrueType files ('true' tag) are written with the standard TrueType tag;
+//   - the cmap gets a group mapping glyphRuneBase+gid to every glyph, used to write shaped glyphs.
+
+const (
+	sfntTrueType   = 0x00010000
+	sfntAppleType  = 0x74727565 // "true", TrueType outlines in fonts made for Apple platforms
+	sfntOpenType   = 0x4F54544F // "OTTO"
+	sfntCollection = 0x74746366 // "ttcf"
+)
+
+// cffCurveTolerance is the maximal distance, in font units, between a CFF cubic curve
+// and the quadratic curves replacing it.
+const cffCurveTolerance = 0.5
+
+type sfntTable struct {
+	tag  string
+	data []byte
+}
+
+// trueTypeFont returns the single-face TrueType data gopdf embeds for face index of data.
+func trueTypeFont(data []byte, index int) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, errors.New("font data too short")
+	}
+	if binary.BigEndian.Uint32(data) != sfntCollection && index != 0 {
+		return nil, fmt.Errorf("face index %d given for a font that is not a collection", index)
+	}
+
+	version, tables, err := readSfntTables(data, index)
+	if err != nil {
+		return nil, err
+	}
+	switch version {
+	case sfntTrueType, sfntAppleType:
+	case sfntOpenType:
+		tables, err = cffToGlyf(data, index, tables)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported font format %08x", version)
+	}
+
+	numGlyphs := 0
+	for _, t := range tables {
+		if t.tag == "maxp" && len(t.data) >= 6 {
+			numGlyphs = int(binary.BigEndian.Uint16(t.data[4:]))
+		}
+	}
+	for i, t := range tables {
+		if t.tag == "cmap" {
+			if tables[i].data, err = addGlyphCmap(t.data, numGlyphs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return writeSfnt(sfntTrueType, tables), nil
+}
+
+// isCFFFont reports whether face index of data has CFF outlines, checking that they can be read.
+func isCFFFont(data []byte, index int) (bool, error) {
+	if len(data) < 12 {
+		return false, errors.New("font data too short")
+	}
+	version, _, err := readSfntTables(data, index)
+	if err != nil || version != sfntOpenType {
+		return false, err
+	}
+	if _, err := parseCFFFace(data, index); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// parseCFFFace parses face index of data with the CFF outlines.
+func parseCFFFace(data []byte, index int) (*sfnt.Font, error) {
+	var (
+		face *sfnt.Font
+		err  error
+	)
+	if binary.BigEndian.Uint32(data) == sfntCollection {
+		var c *sfnt.Collection
+		if c, err = sfnt.ParseCollection(data); err == nil {
+			face, err = c.Font(index)
+		}
+	} else {
+		face, err = sfnt.Parse(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse CFF font: %w", err)
+	}
+	return face, nil
+}
+
+// validGroups returns the groups mapping only to glyphs below numGlyphs.
+func validGroups(groups []cmapGroup, numGlyphs int) []cmapGroup {
+	valid := groups[:0]
+	for _, g := range groups {
+		if uint64(g.gid)+uint64(g.end-g.start) < uint64(numGlyphs) {
+			valid = append(valid, g)
+		}
+	}
+	return valid
+}
+
+type cmapGroup struct {
+	start, end, gid uint32
+}
+
+// cmapFormat4Groups returns the mappings of a format 4 subtable as format 12 groups.
+func cmapFormat4Groups(sub []byte) []cmapGroup {
+	if len(sub) < 14 {
+		return nil
+	}
+	segCount := int(binary.BigEndian.Uint16(sub[6:])) / 2
+	if len(sub) < 16+8*segCount {
+		return nil
+	}
+	ends := sub[14:]
+	starts := sub[16+2*segCount:]
+	deltas := sub[16+4*segCount:]
+	rangeOffsets := sub[16+6*segCount:]
+
+	var groups []cmapGroup
+	for i := 0; i < segCount; i++ {
+		start := int(binary.BigEndian.Uint16(starts[2*i:]))
+		end := int(binary.BigEndian.Uint16(ends[2*i:]))
+		delta := int(binary.BigEndian.Uint16(deltas[2*i:]))
+		rangeOffset := int(binary.BigEndian.Uint16(rangeOffsets[2*i:]))
+		for c := start; c <= end && c != 0xFFFF; c++ {
+			gid := 0
+			if rangeOffset == 0 {
+				gid = (c + delta) & 0xFFFF
+			} else {
+				at := 16 + 6*segCount + 2*i + rangeOffset + 2*(c-start)
+				if at+2 > len(sub) {
+					break
+				}
+				if gid = int(binary.BigEndian.Uint16(sub[at:])); gid != 0 {
+					gid = (gid + delta) & 0xFFFF
+				}
+			}
+			if gid == 0 {
+				continue
+			}
+			if n := len(groups); n > 0 && groups[n-1].end+1 == uint32(c) &&
+				groups[n-1].gid+uint32(c)-groups[n-1].start == uint32(gid) {
+				groups[n-1].end++
+				continue
+			}
+			groups = append(groups, cmapGroup{uint32(c), uint32(c), uint32(gid)})
+		}
+	}
+	return groups
+}
+
+type cmapRecord struct {
+	platformID, encodingID uint16
+	offset                 uint32
+}
+
+// addGlyphCmap returns cmap with its (3,10) format 12 subtable extended, or created from the
+// (3,1) format 4 subtable, with the group glyphRuneBase..glyphRuneBase+numGlyphs-1 -> 0..numGlyphs-1.
+// Groups of the existing subtables mapping to glyphs beyond numGlyphs are dropped.
+func addGlyphCmap(cmap []byte, numGlyphs int) ([]byte, error) {
+	if numGlyphs < 1 || numGlyphs > 0x10000 {
+		return nil, fmt.Errorf("invalid number of glyphs %d", numGlyphs)
+	}
+	if len(cmap) < 4 {
+		return nil, errors.New("invalid cmap table")
+	}
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	headerLen := 4 + 8*numTables
+	if len(cmap) < headerLen {
+		return nil, errors.New("invalid cmap table")
+	}
+
+	last := uint32(glyphRuneBase + numGlyphs - 1)
+	groups := []cmapGroup{{glyphRuneBase, last, 0}}
+	var (
+		records  []cmapRecord
+		format4  []cmapGroup
+		format12 bool
+	)
+	for i := 0; i < numTables; i++ {
+		r := cmap[4+8*i:]
+		record := cmapRecord{
+			platformID: binary.BigEndian.Uint16(r),
+			encodingID: binary.BigEndian.Uint16(r[2:]),
+			offset:     binary.BigEndian.Uint32(r[4:]),
+		}
+		if int(record.offset) < headerLen || int(record.offset)+2 > len(cmap) {
+			return nil, errors.New("invalid cmap table")
+		}
+		sub := cmap[record.offset:]
+		if record.platformID == 3 && record.encodingID == 1 && binary.BigEndian.Uint16(sub) == 4 {
+			format4 = validGroups(cmapFormat4Groups(sub), numGlyphs)
+		}
+		if record.platformID != 3 || record.encodingID != 10 {
+			records = append(records, record)
+			continue
+		}
+		if len(sub) < 16 || binary.BigEndian.Uint16(sub) != 12 {
+			continue
+		}
+		format12 = true
+		n := int(binary.BigEndian.Uint32(sub[12:]))
+		if len(sub) < 16+12*n {
+			return nil, errors.New("invalid cmap format 12 subtable")
+		}
+		for j := 0; j < n; j++ {
+			g := sub[16+12*j:]
+			old := cmapGroup{binary.BigEndian.Uint32(g), binary.BigEndian.Uint32(g[4:]), binary.BigEndian.Uint32(g[8:])}
+			if old.end < old.start {
+				return nil, errors.New("invalid cmap format 12 subtable")
+			}
+			if (old.end < glyphRuneBase || old.start > last) && len(validGroups([]cmapGroup{old}, numGlyphs)) == 1 {
+				groups = append(groups, old)
+			}
+		}
+	}
+	if !format12 {
+		groups = append(groups, format4...)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].start < groups[j].start })
+
+	newHeaderLen := 4 + 8*(len(records)+1)
+	body := cmap[headerLen:]
+	subOffset := newHeaderLen + (len(body)+3)&^3
+	for i := range records {
+		records[i].offset = records[i].offset - uint32(headerLen) + uint32(newHeaderLen)
+	}
+	records = append(records, cmapRecord{platformID: 3, encodingID: 10, offset: uint32(subOffset)})
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].platformID != records[j].platformID {
+			return records[i].platformID < records[j].platformID
+		}
+		return records[i].encodingID < records[j].encodingID
+	})
+
+	out := make([]byte, subOffset+16+12*len(groups))
+	binary.BigEndian.PutUint16(out[2:], uint16(len(records)))
+	for i, record := range records {
+		r := out[4+8*i:]
+		binary.BigEndian.PutUint16(r, record.platformID)
+		binary.BigEndian.PutUint16(r[2:], record.encodingID)
+		binary.BigEndian.PutUint32(r[4:], record.offset)
+	}
+	copy(out[newHeaderLen:], body)
+	sub := out[subOffset:]
+	binary.BigEndian.PutUint16(sub, 12)
+	binary.BigEndian.PutUint32(sub[4:], uint32(len(sub)))
+	binary.BigEndian.PutUint32(sub[12:], uint32(len(groups)))
+	for j, g := range groups {
+		binary.BigEndian.PutUint32(sub[16+12*j:], g.start)
+		binary.BigEndian.PutUint32(sub[20+12*j:], g.end)
+		binary.BigEndian.PutUint32(sub[24+12*j:], g.gid)
+	}
+	return out, nil
+}
+
+// readSfntTables reads the table directory of a single-face file, or of face index of a collection.
+func readSfntTables(data []byte, index int) (version uint32, tables []sfntTable, err error) {
+	offset := 0
+	if binary.BigEndian.Uint32(data) == sfntCollection {
+		numFonts := int(binary.BigEndian.Uint32(data[8:]))
+		if index < 0 || index >= numFonts {
+			return 0, nil, fmt.Errorf("face index %d out of range, collection has %d faces", index, numFonts)
+		}
+		if len(data) < 12+4*numFonts {
+			return 0, nil, errors.New("invalid font collection header")
+		}
+		offset = int(binary.BigEndian.Uint32(data[12+4*index:]))
+	}
+	if offset+12 > len(data) {
+		return 0, nil, errors.New("invalid font header")
+	}
+
+	version = binary.BigEndian.Uint32(data[offset:])
+	numTables := int(binary.BigEndian.Uint16(data[offset+4:]))
+	if offset+12+16*numTables > len(data) {
+		return 0, nil, errors.New("invalid font table directory")
+	}
+	for i := 0; i < numTables; i++ {
+		record := data[offset+12+16*i:]
+		start := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if start < 0 || length < 0 || start+length > len(data) {
+			return 0, nil, fmt.Errorf("font table %q out of bounds", string(record[:4]))
+		}
+		tables = append(tables, sfntTable{tag: string(record[:4]), data: data[start : start+length]})
+	}
+	return version, tables, nil
+}
+
+// writeSfnt serializes tables as a single-face sfnt file.
+func writeSfnt(version uint32, tables []sfntTable) []byte {
+	sort.Slice(tables, func(i, j int) bool { return tables[i].tag < tables[j].tag })
+
+	numTables := len(tables)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	size := 12 + 16*numTables
+	for _, t := range tables {
+		size += (len(t.data) + 3) &^ 3
+	}
+	out := make([]byte, size)
+	binary.BigEndian.PutUint32(out, version)
+	binary.BigEndian.PutUint16(out[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*numTables-searchRange))
+
+	offset := 12 + 16*numTables
+	for i, t := range tables {
+		record := out[12+16*i:]
+		copy(record, t.tag)
+		binary.BigEndian.PutUint32(record[4:], sfntChecksum(t.data))
+		binary.BigEndian.PutUint32(record[8:], uint32(offset))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(t.data)))
+		copy(out[offset:], t.data)
+		offset += (len(t.data) + 3) &^ 3
+	}
+	return out
+}
+
+func sfntChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// cffToGlyf replaces the CFF table of face index by glyf/loca outlines and a version 1.0 maxp.
+func cffToGlyf(data []byte, index int, tables []sfntTable) ([]sfntTable, error) {
+	face, err := parseCFFFace(data, index)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		buf         sfnt.Buffer
+		glyf        []byte
+		loca        = make([]byte, 4*(face.NumGlyphs()+1))
+		maxPoints   int
+		maxContours int
+	)
+	ppem := fixed.Int26_6(face.UnitsPerEm()) << 6 // outlines in font units
+	for gid := 0; gid < face.NumGlyphs(); gid++ {
+		binary.BigEndian.PutUint32(loca[4*gid:], uint32(len(glyf)))
+		segments, err := face.LoadGlyph(&buf, sfnt.GlyphIndex(gid), ppem, nil)
+		if err != nil {
+			return nil, fmt.Errorf("load CFF glyph %d: %w", gid, err)
+		}
+		contours := quadContours(segments)
+		glyph, points := encodeSimpleGlyph(contours)
+		glyf = append(glyf, glyph...)
+		for len(glyf)%4 != 0 {
+			glyf = append(glyf, 0)
+		}
+		if points > maxPoints {
+			maxPoints = points
+		}
+		if len(contours) > maxContours {
+			maxContours = len(contours)
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*face.NumGlyphs():], uint32(len(glyf)))
+
+	maxp := make([]byte, 32)
+	binary.BigEndian.PutUint32(maxp, 0x00010000)
+	binary.BigEndian.PutUint16(maxp[4:], uint16(face.NumGlyphs()))
+	binary.BigEndian.PutUint16(maxp[6:], uint16(maxPoints))
+	binary.BigEndian.PutUint16(maxp[8:], uint16(maxContours))
+	binary.BigEndian.PutUint16(maxp[14:], 2) // maxZones
+
+	out := make([]sfntTable, 0, len(tables)+2)
+	for _, t := range tables {
+		switch t.tag {
+		case "CFF ", "CFF2", "VORG", "maxp":
+			continue
+		case "head":
+			if len(t.data) < 54 {
+				return nil, errors.New("invalid head table")
+			}
+			head := append([]byte(nil), t.data...)
+			binary.BigEndian.PutUint32(head[8:], 0)  // checkSumAdjustment
+			binary.BigEndian.PutUint16(head[50:], 1) // indexToLocFormat: long offsets
+			t.data = head
+		}
+		out = append(out, t)
+	}
+	return append(out,
+		sfntTable{tag: "glyf", data: glyf},
+		sfntTable{tag: "loca", data: loca},
+		sfntTable{tag: "maxp", data: maxp},
+	), nil
+}
+
+type quadPoint struct {
+	x, y    float64
+	onCurve bool
+}
+
+// quadContours turns sfnt segments (y down, 26.6 font units) into TrueType contours (y up),
+// approximating each cubic curve by quadratic ones.
+func quadContours(segments []sfnt.Segment) [][]quadPoint {
+	var (
+		contours [][]quadPoint
+		current  []quadPoint
+		last     quadPoint
+	)
+	pt := func(p fixed.Point26_6) quadPoint {
+		return quadPoint{x: float64(p.X) / 64, y: -float64(p.Y) / 64, onCurve: true}
+	}
+	closeContour := func() {
+		if n := len(current); n > 1 && current[n-1].x == current[0].x && current[n-1].y == current[0].y {
+			current = current[:n-1]
+		}
+		if len(current) > 0 {
+			contours = append(contours, current)
+		}
+		current = nil
+	}
+
+	for _, s := range segments {
+		switch s.Op {
+		case sfnt.SegmentOpMoveTo:
+			closeContour()
+			last = pt(s.Args[0])
+			current = append(current, last)
+		case sfnt.SegmentOpLineTo:
+			last = pt(s.Args[0])
+			current = append(current, last)
+		case sfnt.SegmentOpQuadTo:
+			c := pt(s.Args[0])
+			c.onCurve = false
+			last = pt(s.Args[1])
+			current = append(current, c, last)
+		case sfnt.SegmentOpCubeTo:
+			p0, c1, c2, p3 := last, pt(s.Args[0]), pt(s.Args[1]), pt(s.Args[2])
+			current = append(current, cubicToQuads(p0, c1, c2, p3)...)
+			last = p3
+		}
+	}
+	closeContour()
+	return contours
+}
+
+// cubicToQuads splits the cubic curve p0..p3 into n equal pieces, each replaced by one quadratic
+// curve, with n chosen so that the error stays below cffCurveTolerance. It returns the points
+// after p0: alternating off-curve control points and on-curve end points.
+func cubicToQuads(p0, c1, c2, p3 quadPoint) []quadPoint {
+	// error of a single quadratic approximation: sqrt(3)/36 * |p3 - 3c2 + 3c1 - p0|
+	dx := p3.x - 3*c2.x + 3*c1.x - p0.x
+	dy := p3.y - 3*c2.y + 3*c1.y - p0.y
+	e := math.Sqrt(3) / 36 * math.Hypot(dx, dy)
+	n := 1
+	for n < 16 && e/float64(n*n*n) > cffCurveTolerance {
+		n++
+	}
+
+	out := make([]quadPoint, 0, 2*n)
+	for i := 0; i < n; i++ {
+		s, q1, q2, e := cubicPiece(p0, c1, c2, p3, float64(i)/float64(n), float64(i+1)/float64(n))
+		ctrl := quadPoint{
+			x: (3*(q1.x+q2.x) - (s.x + e.x)) / 4,
+			y: (3*(q1.y+q2.y) - (s.y + e.y)) / 4,
+		}
+		e.onCurve = true
+		out = append(out, ctrl, e)
+	}
+	return out
+}
+
+// cubicPiece returns the end and control points of the part of the cubic between t0 and t1.
+func cubicPiece(p0, c1, c2, p3 quadPoint, t0, t1 float64) (s, q1, q2, e quadPoint) {
+	point := func(t float64) quadPoint {
+		mt := 1 - t
+		return quadPoint{
+			x: mt*mt*mt*p0.x + 3*mt*mt*t*c1.x + 3*mt*t*t*c2.x + t*t*t*p3.x,
+			y: mt*mt*mt*p0.y + 3*mt*mt*t*c1.y + 3*mt*t*t*c2.y + t*t*t*p3.y,
+		}
+	}
+	deriv := func(t float64) quadPoint {
+		mt := 1 - t
+		return quadPoint{
+			x: 3*mt*mt*(c1.x-p0.x) + 6*mt*t*(c2.x-c1.x) + 3*t*t*(p3.x-c2.x),
+			y: 3*mt*mt*(c1.y-p0.y) + 6*mt*t*(c2.y-c1.y) + 3*t*t*(p3.y-c2.y),
+		}
+	}
+	h := (t1 - t0) / 3
+	s, e = point(t0), point(t1)
+	ds, de := deriv(t0), deriv(t1)
+	return s, quadPoint{x: s.x + ds.x*h, y: s.y + ds.y*h}, quadPoint{x: e.x - de.x*h, y: e.y - de.y*h}, e
+}
+
+// encodeSimpleGlyph writes contours as a TrueType simple glyph without instructions.
+// An empty outline is encoded as an empty glyph.
+func encodeSimpleGlyph(contours [][]quadPoint) (glyph []byte, points int) {
+	if len(contours) == 0 {
+		return nil, 0
+	}
+
+	var (
+		xs, ys                 []int
+		flags                  []byte
+		endPts                 []int
+		xMin, yMin, xMax, yMax = math.MaxInt32, math.MaxInt32, math.MinInt32, math.MinInt32
+	)
+	for _, contour := range contours {
+		for _, p := range contour {
+			x, y := int(math.Round(p.x)), int(math.Round(p.y))
+			xs, ys = append(xs, x), append(ys, y)
+			flag := byte(0)
+			if p.onCurve {
+				flag = 1
+			}
+			flags = append(flags, flag)
+			if x < xMin {
+				xMin = x
+			}
+			if x > xMax {
+				xMax = x
+			}
+			if y < yMin {
+				yMin = y
+			}
+			if y > yMax {
+				yMax = y
+			}
+		}
+		endPts = append(endPts, len(xs)-1)
+	}
+
+	glyph = make([]byte, 10, 10+2*len(endPts)+2+5*len(xs))
+	binary.BigEndian.PutUint16(glyph, uint16(len(contours)))
+	binary.BigEndian.PutUint16(glyph[2:], uint16(int16(xMin)))
+	binary.BigEndian.PutUint16(glyph[4:], uint16(int16(yMin)))
+	binary.BigEndian.PutUint16(glyph[6:], uint16(int16(xMax)))
+	binary.BigEndian.PutUint16(glyph[8:], uint16(int16(yMax)))
+	for _, end := range endPts {
+		glyph = append(glyph, byte(end>>8), byte(end))
+	}
+	glyph = append(glyph, 0, 0) // instructionLength
+	glyph = append(glyph, flags...)
+	prev := 0
+	for _, x := range xs {
+		d := int16(x - prev)
+		glyph = append(glyph, byte(uint16(d)>>8), byte(d))
+		prev = x
+	}
+	prev = 0
+	for _, y := range ys {
+		d := int16(y - prev)
+		glyph = append(glyph, byte(uint16(d)>>8), byte(d))
+		prev = y
+	}
+	return glyph, len(xs)
+}
@@ -0,0 +1,438 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Paths ("PA" records) are outlines of lines and cubic Bézier curves in report coordinates,
+// painted as a whole: the shapes of vector graphics. A path is filled with a color or with a
+// gradient defined by a "GD" record, and stroked with a color, width, caps, joins and dashes of
+// its own; the line style of lines and rectangles is left as it is. Gradients are axial or
+// radial PDF shadings, painted through a form XObject inside the path clipped. The form is
+// created at the first use of the gradient and shared by every later one.
+
+// GradientKeyPrefix marks the fill of a path as the key of a gradient.
+const GradientKeyPrefix = "gradient:"
+
+// gradientBox bounds the form XObject of a gradient, in gradient space. The path clips the form,
+// the box only has to contain it.
+const gradientBox = 32767
+
+// Path is the outline of a shape in report coordinates: subpaths of lines and cubic Bézier
+// curves, each started by MoveTo.
+type Path struct {
+	segments []string
+}
+
+// MoveTo starts a new subpath at (x, y).
+func (p *Path) MoveTo(x, y float64) *Path {
+	p.segments = append(p.segments, "M "+formatPoints(x, y))
+	return p
+}
+
+// LineTo adds a line from the current point to (x, y).
+func (p *Path) LineTo(x, y float64) *Path {
+	p.segments = append(p.segments, "L "+formatPoints(x, y))
+	return p
+}
+
+// CurveTo adds a cubic Bézier curve from the current point to (x, y) with the control points
+// (x1, y1) and (x2, y2).
+func (p *Path) CurveTo(x1, y1, x2, y2, x, y float64) *Path {
+	p.segments = append(p.segments, "C "+formatPoints(x1, y1, x2, y2, x, y))
+	return p
+}
+
+// Close closes the current subpath with a line to its start.
+func (p *Path) Close() *Path {
+	p.segments = append(p.segments, "Z")
+	return p
+}
+
+// Empty reports whether p has no segments.
+func (p *Path) Empty() bool {
+	return len(p.segments) == 0
+}
+
+// String formats p as the data field of a "PA" record.
+func (p *Path) String() string {
+	return strings.Join(p.segments, " ")
+}
+
+// PathStyle is the paint of a path.
+type PathStyle struct {
+	Fill        string    // fill color "r,g,b" or the key of a gradient, "" for no fill
+	EvenOdd     bool      // fill by the even-odd rule instead of the nonzero winding rule
+	Stroke      string    // stroke color "r,g,b", "" for no stroke
+	StrokeWidth float64   // stroke width
+	LineCap     int       // 0 butt, 1 round, 2 square
+	LineJoin    int       // 0 miter, 1 round, 2 bevel
+	MiterLimit  float64   // miter limit, 0 for the PDF default 10
+	Dash        []float64 // lengths of alternating dashes and gaps, none for a solid stroke
+	DashPhase   float64   // distance into the dash pattern at the start of the stroke
+}
+
+// String formats style as the paint fields of a "PA" record.
+func (style PathStyle) String() string {
+	rule := "nonzero"
+	if style.EvenOdd {
+		rule = "evenodd"
+	}
+	return strings.Join([]string{style.Fill, rule, style.Stroke, formatPoints(style.StrokeWidth),
+		strconv.Itoa(style.LineCap), strconv.Itoa(style.LineJoin), formatPoints(style.MiterLimit),
+		formatPoints(style.Dash...), formatPoints(style.DashPhase)}, "|")
+}
+
+// GradientStop is a color of a gradient.
+type GradientStop struct {
+	Offset float64 // position along the gradient, from 0 to 1
+	Color  string  // "r,g,b"
+}
+
+// Gradient is a linear or radial color gradient. Colors between the stops are interpolated,
+// beyond the first and the last stop they are extended.
+type Gradient struct {
+	Radial         bool
+	X1, Y1, X2, Y2 float64 // linear: the gradient vector, from offset 0 to offset 1
+	CX, CY, R      float64 // radial: the circle of offset 1
+	FX, FY         float64 // radial: the focal point of offset 0, moved inside the circle
+	Transform      Matrix  // maps the gradient coordinates to report coordinates, zero for none
+	Stops          []GradientStop
+}
+
+// String formats gradient as the fields of a "GD" record after the key.
+func (gradient Gradient) String() string {
+	kind, coords := "linear", formatPoints(gradient.X1, gradient.Y1, gradient.X2, gradient.Y2)
+	if gradient.Radial {
+		kind, coords = "radial", formatPoints(gradient.CX, gradient.CY, gradient.R, gradient.FX, gradient.FY)
+	}
+	m := gradient.Transform
+	stops := make([]string, len(gradient.Stops))
+	for i, stop := range gradient.Stops {
+		stops[i] = formatPoints(stop.Offset) + ":" + stop.Color
+	}
+	return strings.Join([]string{kind, coords, formatPoints(m.A, m.B, m.C, m.D, m.E, m.F),
+		strings.Join(stops, " ")}, "|")
+}
+
+// formatPoints formats values as space separated fields.
+func formatPoints(values ...float64) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.FormatFloat(v, 'f', 4, 64)
+	}
+	return strings.Join(s, " ")
+}
+
+// parsePoints parses the space separated values of a field.
+func parsePoints(field, line string) ([]float64, error) {
+	var values []float64
+	for _, s := range strings.Fields(field) {
+		v, err := parseFloatCell(s, line)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// gradient is a gradient of a "GD" record: its shading dictionary in gradient space, flipped to
+// PDF coordinates, and the transform into the report in PDF space.
+type gradient struct {
+	shading string
+	matrix  Matrix
+	name    string // name of the form XObject, "" until the gradient is used
+}
+
+// Gradient defines ("GD|key|linear|x1 y1 x2 y2|matrix|stops" or "GD|key|radial|cx cy r fx fy|matrix|stops")
+// a gradient for the fill of paths.
+func (convert *Converter) Gradient(line string, elements []string) error {
+	if err := checkLength(line, elements, 6); err != nil {
+		return err
+	}
+	coords, err := parsePoints(elements[3], line)
+	if err != nil {
+		return err
+	}
+	m, err := parsePoints(elements[4], line)
+	if err != nil {
+		return err
+	}
+	if len(m) != 6 {
+		return fmt.Errorf("invalid gradient transform; line %s", line)
+	}
+	stops, err := parseGradientStops(elements[5], line)
+	if err != nil {
+		return err
+	}
+
+	// gradient space is flipped like report space, the shading is defined in the flipped space
+	u, h := convert.unit, convert.pageHeight
+	var shading string
+	switch {
+	case elements[2] == "linear" && len(coords) == 4:
+		shading = fmt.Sprintf("/ShadingType 2\n/Coords [%.4f %.4f %.4f %.4f]",
+			coords[0]*u, h-coords[1]*u, coords[2]*u, h-coords[3]*u)
+	case elements[2] == "radial" && len(coords) == 5:
+		cx, cy, r, fx, fy := coords[0], coords[1], coords[2], coords[3], coords[4]
+		if d := math.Hypot(fx-cx, fy-cy); d > r*0.999 {
+			fx, fy = cx+(fx-cx)*r*0.999/d, cy+(fy-cy)*r*0.999/d
+		}
+		shading = fmt.Sprintf("/ShadingType 3\n/Coords [%.4f %.4f 0 %.4f %.4f %.4f]",
+			fx*u, h-fy*u, cx*u, h-cy*u, r*u)
+	default:
+		return fmt.Errorf("invalid gradient; line %s", line)
+	}
+
+	matrix := Matrix{A: m[0], B: m[1], C: m[2], D: m[3], E: m[4], F: m[5]}
+	if matrix == (Matrix{}) {
+		matrix = IdentityMatrix()
+	}
+	if convert.gradients == nil {
+		convert.gradients = make(map[string]*gradient)
+	}
+	convert.gradients[elements[1]] = &gradient{
+		shading: fmt.Sprintf("<<\n%s\n/ColorSpace /DeviceRGB\n/Function %s\n/Extend [true true]\n>>\n",
+			shading, gradientFunction(stops)),
+		matrix: convert.pdfMatrix(matrix),
+	}
+	return nil
+}
+
+// parseGradientStops parses the "offset:r,g,b" stops of a gradient: offsets are clamped to
+// 0..1 and to the offset before, the first and the last color are extended to 0 and 1.
+func parseGradientStops(field, line string) ([]gradientStop, error) {
+	var stops []gradientStop
+	for _, s := range strings.Fields(field) {
+		parts := strings.SplitN(s, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid gradient stop %q; line %s", s, line)
+		}
+		offset, err := parseFloatCell(parts[0], line)
+		if err != nil {
+			return nil, err
+		}
+		rgb, err := parseRGB(parts[1], line)
+		if err != nil {
+			return nil, err
+		}
+		offset = math.Max(0, math.Min(1, offset))
+		if len(stops) > 0 {
+			offset = math.Max(offset, stops[len(stops)-1].offset)
+		}
+		stops = append(stops, gradientStop{offset: offset, rgb: rgb})
+	}
+	if len(stops) == 0 {
+		return nil, fmt.Errorf("gradient without stops; line %s", line)
+	}
+	if stops[0].offset > 0 {
+		stops = append([]gradientStop{{rgb: stops[0].rgb}}, stops...)
+	}
+	if last := stops[len(stops)-1]; last.offset < 1 || len(stops) == 1 {
+		stops = append(stops, gradientStop{offset: 1, rgb: last.rgb})
+	}
+	return stops, nil
+}
+
+type gradientStop struct {
+	offset float64
+	rgb    [3]float64
+}
+
+// gradientFunction returns the PDF function interpolating the colors of stops over 0..1:
+// an exponential function between two stops, stitched for more.
+func gradientFunction(stops []gradientStop) string {
+	interpolate := func(a, b gradientStop) string {
+		return fmt.Sprintf("<< /FunctionType 2 /Domain [0 1] /C0 [%.3f %.3f %.3f] /C1 [%.3f %.3f %.3f] /N 1 >>",
+			a.rgb[0], a.rgb[1], a.rgb[2], b.rgb[0], b.rgb[1], b.rgb[2])
+	}
+	if len(stops) == 2 {
+		return interpolate(stops[0], stops[1])
+	}
+
+	var functions, bounds, encode []string
+	for i := 1; i < len(stops); i++ {
+		functions = append(functions, interpolate(stops[i-1], stops[i]))
+		encode = append(encode, "0 1")
+		if i < len(stops)-1 {
+			bounds = append(bounds, fmt.Sprintf("%.4f", stops[i].offset))
+		}
+	}
+	return fmt.Sprintf("<< /FunctionType 3 /Domain [0 1] /Functions [%s] /Bounds [%s] /Encode [%s] >>",
+		strings.Join(functions, " "), strings.Join(bounds, " "), strings.Join(encode, " "))
+}
+
+// gradientForm returns the gradient of key with the name of the form XObject painting it,
+// creating the shading and the form at the first use.
+func (convert *Converter) gradientForm(key string) (*gradient, error) {
+	g, ok := convert.gradients[key]
+	if !ok {
+		return nil, fmt.Errorf("gradient %q is not defined", key)
+	}
+	if g.name != "" {
+		return g, nil
+	}
+
+	id := convert.pdf.GetNextObjectID()
+	content := "/Sh1 sh"
+	form := fmt.Sprintf("<<\n/Type /XObject\n/Subtype /Form\n/BBox [%d %d %d %d]\n"+
+		"/Resources << /Shading << /Sh1 %d 0 R >> >>\n/Length %d\n>>\nstream\n%s\nendstream\n",
+		-gradientBox, -gradientBox, gradientBox, gradientBox, id, len(content), content)
+	g.name = fmt.Sprintf("/GR%d", id+1)
+	convert.pdf.ImportObjects(map[int]string{id: g.shading, id + 1: form}, id)
+	convert.pdf.ImportTemplates(map[string]int{g.name: id + 1})
+	return g, nil
+}
+
+// Path paints ("PA|fill|rule|stroke|width|cap|join|miter|dash|phase|data") a path.
+func (convert *Converter) Path(line string, elements []string) error {
+	if err := checkLength(line, elements, 11); err != nil {
+		return err
+	}
+	path, err := convert.pathOps(elements[10], line)
+	if err != nil || path == "" {
+		return err
+	}
+	fill, stroke := elements[1], elements[3]
+	evenOdd := elements[2] == "evenodd"
+
+	var ops []string
+	if strings.HasPrefix(fill, GradientKeyPrefix) {
+		g, err := convert.gradientForm(fill)
+		if err != nil {
+			return fmt.Errorf("%w; line %s", err, line)
+		}
+		clip := "W n"
+		if evenOdd {
+			clip = "W* n"
+		}
+		m := g.matrix
+		ops = append(ops, "q")
+		if convert.alpha != "" {
+			ops = append(ops, convert.alpha)
+		}
+		ops = append(ops, path, clip, fmt.Sprintf("%.6f %.6f %.6f %.6f %.4f %.4f cm", m.A, m.B, m.C, m.D, m.E, m.F),
+			g.name+" Do", "Q")
+		fill = ""
+	}
+
+	var paint string
+	switch {
+	case fill != "" && stroke != "":
+		paint = "B"
+	case fill != "":
+		paint = "f"
+	case stroke != "":
+		paint = "S"
+	default:
+		return convert.rawContent(strings.Join(ops, "\n"))
+	}
+	if fill != "" && evenOdd {
+		paint += "*"
+	}
+
+	ops = append(ops, "q")
+	if convert.alpha != "" {
+		ops = append(ops, convert.alpha)
+	}
+	if fill != "" {
+		rgb, err := parseRGB(fill, line)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, fmt.Sprintf("%.3f %.3f %.3f rg", rgb[0], rgb[1], rgb[2]))
+	}
+	if stroke != "" {
+		style, err := convert.strokeStyle(elements[3:10], line)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, style...)
+	}
+	ops = append(ops, path, paint, "Q")
+	return convert.rawContent(strings.Join(ops, "\n"))
+}
+
+// strokeStyle returns the operators of the stroke fields "stroke|width|cap|join|miter|dash|phase".
+func (convert *Converter) strokeStyle(fields []string, line string) ([]string, error) {
+	rgb, err := parseRGB(fields[0], line)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parsePoints(strings.Join([]string{fields[1], fields[2], fields[3], fields[4], fields[6]}, " "), line)
+	if err != nil || len(values) != 5 {
+		return nil, fmt.Errorf("invalid path stroke; line %s", line)
+	}
+	width, lineCap, lineJoin, miter, phase := values[0], values[1], values[2], values[3], values[4]
+	dash, err := parsePoints(fields[5], line)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := []string{
+		fmt.Sprintf("%.3f %.3f %.3f RG", rgb[0], rgb[1], rgb[2]),
+		fmt.Sprintf("%.4f w %d J %d j", width*convert.unit, int(lineCap), int(lineJoin)),
+	}
+	if miter >= 1 {
+		ops = append(ops, fmt.Sprintf("%.4f M", miter))
+	}
+	lengths := make([]string, len(dash))
+	for i, v := range dash {
+		lengths[i] = fmt.Sprintf("%.4f", v*convert.unit)
+	}
+	ops = append(ops, fmt.Sprintf("[%s] %.4f d", strings.Join(lengths, " "), phase*convert.unit))
+	return ops, nil
+}
+
+// pathOps returns the PDF path construction operators of the data field of a "PA" record.
+func (convert *Converter) pathOps(data, line string) (string, error) {
+	var (
+		ops    []string
+		fields = strings.Fields(data)
+		counts = map[string]int{"M": 2, "L": 2, "C": 6, "Z": 0}
+		names  = map[string]string{"M": "m", "L": "l", "C": "c", "Z": "h"}
+	)
+	for i := 0; i < len(fields); {
+		command := fields[i]
+		n, ok := counts[command]
+		if !ok || i+n >= len(fields) {
+			return "", fmt.Errorf("invalid path segment %q; line %s", command, line)
+		}
+		op := make([]string, 0, n+1)
+		for j := 0; j < n; j += 2 {
+			x, err := parseFloatCell(fields[i+1+j], line)
+			if err != nil {
+				return "", err
+			}
+			y, err := parseFloatCell(fields[i+2+j], line)
+			if err != nil {
+				return "", err
+			}
+			op = append(op, fmt.Sprintf("%.4f %.4f", x*convert.unit, convert.pageHeight-y*convert.unit))
+		}
+		ops = append(ops, strings.Join(append(op, names[command]), " "))
+		i += n + 1
+	}
+	return strings.Join(ops, "\n"), nil
+}
+
+// parseRGB parses the color "r,g,b" into PDF color components.
+func parseRGB(color, line string) ([3]float64, error) {
+	var rgb [3]float64
+	parts := strings.Split(color, ",")
+	if len(parts) != 3 {
+		return rgb, fmt.Errorf("invalid color %q; line %s", color, line)
+	}
+	for i, part := range parts {
+		v, err := parseIntCell(strings.TrimSpace(part), line)
+		if err != nil {
+			return rgb, err
+		}
+		rgb[i] = float64(uint8(v)) / 255
+	}
+	return rgb, nil
+}
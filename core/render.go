@@ -0,0 +1,280 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	tsfont "github.com/go-text/typesetting/font"
+)
+
+// A text render mode ("TR" records) chooses how the glyphs of the following text are painted:
+// filled, stroked, both or neither, and whether their outlines clip the following drawing.
+// The mode is set around each text write, like synthetic bold, so it does not leak into other
+// drawing. Invisible text is neither filled nor stroked but can still be selected, copied and
+// searched, as the text layer over a scanned page.
+//
+// Stroked glyphs are drawn with the text stroke ("TS" and "TW" records), independent of the
+// text color: by default the text color and a width of 3% of the font size.
+//
+// Text written in a clip mode pushes a clipping region on the transform stack at its first
+// write after the mode is set: what is drawn until the matching "TQ" record is visible only
+// inside the glyphs. As in PDF, every further write intersects the region, so clipping text
+// is meant to be written at once, in one line.
+//
+// A "TX" record pushes the clipping region itself, for text of several lines: the clipping text
+// written at its depth until the matching "TQ" record forms one region, the union of the glyphs.
+// PDF makes one region of the glyphs of one text object, gopdf writes a text object per write:
+// the glyphs of the region are kept until its clipping text ends, at the first record drawing
+// anything else or at its "TQ" record, and then written in one text object. The region applies
+// from there; without clipping text it clips nothing.
+
+// TextRenderMode is a PDF text rendering mode (Tr).
+type TextRenderMode int
+
+const (
+	TextRenderFill           TextRenderMode = iota // fill the glyphs (default)
+	TextRenderStroke                               // stroke the glyph outlines
+	TextRenderFillStroke                           // fill, then stroke the glyphs
+	TextRenderInvisible                            // neither fill nor stroke the glyphs
+	TextRenderFillClip                             // fill the glyphs and clip to them
+	TextRenderStrokeClip                           // stroke the glyph outlines and clip to them
+	TextRenderFillStrokeClip                       // fill, stroke the glyphs and clip to them
+	TextRenderClip                                 // clip to the glyphs only
+)
+
+// defaultTextStroke is the stroke width of stroked text without a width of its own, relative to
+// the font size.
+const defaultTextStroke = 0.03
+
+// RenderMode sets ("TR|mode") or resets ("TR") the render mode of the following text.
+func (convert *Converter) RenderMode(line string, elements []string) error {
+	// a new mode ends the clipping text of the previous one, its region stays pushed; the region
+	// of a "TX" record ends with its "TQ" record
+	if convert.textClipID == 0 {
+		convert.textClip = 0
+	}
+	if len(elements) == 1 || len(elements) == 2 && elements[1] == "" {
+		convert.renderMode = TextRenderFill
+		return nil
+	}
+	if err := checkLength(line, elements, 2); err != nil {
+		return err
+	}
+	mode, err := parseIntCell(elements[1], line)
+	if err != nil {
+		return err
+	}
+	if mode < int(TextRenderFill) || mode > int(TextRenderClip) {
+		return fmt.Errorf("unknown text render mode %d; line %s", mode, line)
+	}
+	convert.renderMode = TextRenderMode(mode)
+	return nil
+}
+
+// TextStroke sets the stroke color ("TS|r|g|b") or the stroke width ("TW|width") of stroked text;
+// "TS" resets both.
+func (convert *Converter) TextStroke(line string, elements []string) error {
+	if elements[0] == "TW" {
+		if err := checkLength(line, elements, 2); err != nil {
+			return err
+		}
+		width, err := parseFloatCell(elements[1], line)
+		if err != nil {
+			return err
+		}
+		convert.strokeWidth = width * convert.unit
+		return nil
+	}
+
+	if len(elements) == 1 || len(elements) == 2 && elements[1] == "" {
+		convert.strokeColor, convert.strokeWidth = nil, 0
+		return nil
+	}
+	if err := checkLength(line, elements, 4); err != nil {
+		return err
+	}
+	var rgb [3]uint8
+	for i := range rgb {
+		v, err := parseIntCell(elements[i+1], line)
+		if err != nil {
+			return err
+		}
+		rgb[i] = uint8(v)
+	}
+	convert.strokeColor = &rgb
+	return nil
+}
+
+// textStrokeStyle returns the stroke color and the stroke width of stroked text.
+func (convert *Converter) textStrokeStyle() ([3]uint8, float64) {
+	color, width := convert.textColor, convert.strokeWidth
+	if convert.strokeColor != nil {
+		color = *convert.strokeColor
+	}
+	if width <= 0 {
+		width = float64(convert.font.Size) * defaultTextStroke
+	}
+	return color, width
+}
+
+// TextClip pushes ("TX") the clipping region of the following clipping text.
+func (convert *Converter) TextClip(line string, elements []string) error {
+	convert.pdf.SaveGraphicsState()
+	convert.transforms++
+	convert.textClips++
+	convert.textClip, convert.textClipID = convert.transforms, convert.textClips
+	return nil
+}
+
+// clipGlyphs is the placement of the glyphs of a clipping text write of a "TX" region.
+type clipGlyphs struct {
+	skew     float64     // skew of the synthetic italic style, 0 for none
+	baseline float64     // baseline in PDF space, the axis of the skew
+	sideways *[2]float64 // rotation center in PDF space of sideways text, nil for none
+}
+
+// clipText adds the glyphs written by write to the clipping region of the current clip mode,
+// pushing the region at the first write. The glyphs are written at the level of the region,
+// without a q/Q pair that would end it, and the state they set is undone after them. The glyphs
+// written at the level of a "TX" region in a face of the shaper are kept, by textRun, until
+// endTextClip writes them.
+func (convert *Converter) clipText(write func() error, italic bool) error {
+	if convert.textClip == 0 {
+		convert.pdf.SaveGraphicsState()
+		convert.transforms++
+		convert.textClip = convert.transforms
+	}
+
+	x, y := convert.pdf.GetX(), convert.pdf.GetY()
+	skew := 0.0
+	if italic {
+		skew = syntheticObliqueSkew
+	}
+	if convert.shaper.upem(convert.shapingKey()) > 0 && convert.textClipID != 0 && convert.transforms == convert.textClip {
+		convert.clipGlyphs = &clipGlyphs{skew: skew, baseline: convert.pageHeight - y, sideways: convert.sideways}
+		err := write()
+		convert.clipGlyphs = nil
+		convert.pdf.SetX(x)
+		convert.pdf.SetY(y)
+		return err
+	}
+
+	ops, undo := []string{fmt.Sprintf("%d Tr", TextRenderClip)}, []string{fmt.Sprintf("%d Tr", TextRenderFill)}
+	if italic {
+		baseline := convert.pageHeight - y
+		ops = append(ops, fmt.Sprintf("1 0 %.4f 1 %.4f 0 cm", skew, -skew*baseline))
+		undo = append(undo, fmt.Sprintf("1 0 %.4f 1 %.4f 0 cm", -skew, skew*baseline))
+	}
+	if err := convert.rawContent(strings.Join(ops, "\n")); err != nil {
+		return err
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	convert.pdf.SetX(x)
+	convert.pdf.SetY(y)
+	return convert.rawContent(strings.Join(undo, "\n"))
+}
+
+// textRun writes s, a run of glyphs of the current font, at the current position. The glyphs of
+// the clipping text of a "TX" region are kept in the region, positioned by a text matrix, and
+// the current position moves past them as if they were written.
+func (convert *Converter) textRun(s string) error {
+	c := convert.clipGlyphs
+	if c == nil {
+		return convert.pdf.Text(s)
+	}
+	key := convert.shapingKey()
+	face := convert.shaper.faces[key]
+	width, err := convert.pdf.MeasureTextWidth(s) // adds the characters to the font subset
+	if err != nil {
+		return err
+	}
+	index := convert.pdfFont(key)
+	if index < 0 {
+		return fmt.Errorf("font %q is not embedded", key)
+	}
+
+	var gids strings.Builder
+	for _, r := range s {
+		gid, ok := tsfont.GID(r-glyphRuneBase), isGlyphRune(r)
+		if !ok {
+			gid, ok = face.NominalGlyph(r)
+		}
+		if ok {
+			fmt.Fprintf(&gids, "%04X", gid)
+		}
+	}
+
+	// the origin of the run, skewed around the baseline, then rotated around the center of
+	// sideways text: clockwise by 90 degrees
+	gx, gy := convert.pdf.GetX(), convert.pdf.GetY()
+	x, y := gx+c.skew*(convert.pageHeight-gy-c.baseline), convert.pageHeight-gy
+	m := [4]float64{1, 0, c.skew, 1}
+	if c.sideways != nil {
+		px, py := c.sideways[0], c.sideways[1]
+		x, y = px+(y-py), py-(x-px)
+		m = [4]float64{0, -1, 1, -c.skew}
+	}
+	convert.clipRuns = append(convert.clipRuns, fmt.Sprintf("%s %s %s %s %.2f %.2f Tm\n/F%d %d Tf\n[<%s>] TJ",
+		matrixValue(m[0]), matrixValue(m[1]), matrixValue(m[2]), matrixValue(m[3]), x, y, index+1, convert.font.Size, gids.String()))
+	convert.pdf.SetX(gx + width)
+	return nil
+}
+
+// matrixValue formats v, a factor of a text matrix, without trailing zeros.
+func matrixValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*10000)/10000, 'f', -1, 64)
+}
+
+// endTextClip ends the clipping text of the current "TX" region: its glyphs are written in one
+// text object, whose clipping region is the union of the glyphs. A region without glyphs gets no
+// text object, which would clip everything.
+func (convert *Converter) endTextClip() error {
+	if len(convert.clipRuns) == 0 {
+		return nil
+	}
+	ops := fmt.Sprintf("%d Tr\nBT\n%s\nET\n%d Tr", TextRenderClip, strings.Join(convert.clipRuns, "\n"), TextRenderFill)
+	convert.clipRuns = nil
+	return convert.rawContent(ops)
+}
+
+// endsTextClip reports whether the record of elements ends the clipping text of the current
+// "TX" region, which must clip what the record draws: every record but the ones setting the
+// state of the following drawing and the text written in a clip mode at the level of the region.
+func (convert *Converter) endsTextClip(elements []string) bool {
+	if len(convert.clipRuns) == 0 {
+		return false
+	}
+	switch elements[0] {
+	case "F", "TC", "TD", "WS", "CS", "HC", "BS", "TR", "TS", "TW", "TA", "GD", "LC", "GF", "GS", "M":
+		return false
+	case "C", "CL", "CR", "CV", "EL", "ILA", "ILL":
+		return !convert.renderMode.clips() || convert.transforms != convert.textClip || convert.textClipID == 0
+	}
+	return true
+}
+
+// String formats mode as the field of a "TR" record.
+func (mode TextRenderMode) String() string {
+	return strconv.Itoa(int(mode))
+}
+
+// clips reports whether the glyphs written in mode clip the following drawing.
+func (mode TextRenderMode) clips() bool {
+	return mode >= TextRenderFillClip
+}
+
+// strokes reports whether the glyphs written in mode are stroked.
+func (mode TextRenderMode) strokes() bool {
+	mode &^= TextRenderFillClip
+	return mode == TextRenderStroke || mode == TextRenderFillStroke
+}
+
+// decorated reports whether the text written in mode gets its highlight and decoration lines:
+// only painted text that does not clip.
+func (mode TextRenderMode) decorated() bool {
+	return mode != TextRenderInvisible && !mode.clips()
+}
@@ -39,6 +39,15 @@ type pageMark struct {
 	pageNo int
 }
 
+// MissingImagePolicy 图片文件不存在或无法读取时的处理方式
+type MissingImagePolicy int
+
+const (
+	MissingImagePlaceholder MissingImagePolicy = iota // 绘制指定大小的占位框, 框内写文件名(默认)
+	MissingImageError                                 // 图片的 GenerateAtomicCell 返回错误
+	MissingImageSkip                                  // 跳过图片, 不占据空间
+)
+
 type Executor func(report *Report)
 type CallBack func(report *Report)
 
@@ -54,6 +63,12 @@ type Report struct {
 	flags        map[string]bool      // Mark (automatic paging and reset page number)
 	pageNo       int                  // Record the number of pages of the current Page
 	linew        float64              // line width
+	jpegQuality  int                  // quality of images re-encoded as JPEG, 0: keep JPEG and lossless images
+	imageDPI     float64              // resolution of images without one, 0: 72 (a pixel is a point)
+	missingImage MissingImagePolicy   // handling of image files that cannot be read
+	gradients    int                  // number of registered gradients, for their keys
+	textColor    [3]int               // color of the following text, set by TextColor
+	opacity      [2]float64           // fill and stroke opacity of the following drawing, set by SetTransparency
 
 	// page info
 	pageWidth, pageHeight       float64
@@ -74,6 +89,7 @@ func CreateReport() *Report {
 	report.executors = make(map[string]*Executor)
 	report.callbacks = make([]CallBack, 0)
 	report.flags = make(map[string]bool)
+	report.opacity = [2]float64{1, 1}
 
 	report.flags[Flag_AutoAddNewPage] = false
 	report.flags[Flag_ResetPageNo] = false
@@ -131,9 +147,11 @@ func (report *Report) GetBytesPdf() ([]byte, error) {
 	if err := report.execute(true); err != nil {
 		return nil, err
 	}
-	ret := report.converter.GetBytesPdf()
-
+	ret, err := report.converter.GetBytesPdf()
 	report.converter.CleanupTempFonts()
+	if err != nil {
+		return nil, err
+	}
 
 	return ret, nil
 }
@@ -276,6 +294,21 @@ func (report *Report) SetFonts(fmap []*FontMap) {
 	report.converter.fonts = fmap
 }
 
+// 注册字体族(regular/bold/italic/bold-italic), 须在 SetPage 之前调用.
+// 注册之后 Font{Family: family.Name, Style: "BI"} 等会选择对应的字体文件.
+func (report *Report) AddFontFamily(family FontFamily) error {
+	if family.Name == "" || family.Regular == nil {
+		return fmt.Errorf("font family needs a name and a regular face")
+	}
+	report.converter.fonts = append(report.converter.fonts, family.fontMaps()...)
+	return nil
+}
+
+// 字体族是否注册了 style 对应的字体文件
+func (report *Report) HasFontStyle(family, style string) bool {
+	return report.converter.HasFontStyle(family, style)
+}
+
 // 获取当前页面编号
 func (report *Report) GetCurrentPageNo() int {
 	return report.pageNo
@@ -313,6 +346,10 @@ func (report *Report) GetPageEndXY() (x, y float64) {
 func (report *Report) GetPageStartXY() (x, y float64) {
 	return report.pageStartX, report.pageStartY
 }
+func (report *Report) GetPageWidthAndHeight() (width, height float64) {
+	return report.pageWidth, report.pageHeight
+}
+
 func (report *Report) GetContentWidthAndHeight() (width, height float64) {
 	return report.contentWidth, report.contentHeight
 }
@@ -401,14 +438,53 @@ func (report *Report) MeasureTextWidth(text string) float64 {
 	return report.converter.MeasureTextWidth(text)
 }
 
+// 计算文本以 font 写入时的宽度, 不改变当前字体
+func (report *Report) MeasureTextWidthWithFont(text string, font Font) float64 {
+	return report.converter.MeasureTextWidthWithFont(text, font)
+}
+
+// 计算文本竖排(VerticalCell)时的高度, 必须先调用 SetFontWithStyle() 或者 SetFont()
+func (report *Report) MeasureTextHeight(text string) float64 {
+	return report.converter.MeasureTextHeight(text)
+}
+
+// 按当前字体将一个段落折成竖排时高度不超过 height 的多列, 断行规则同 WrapText
+func (report *Report) WrapVerticalText(text string, height float64) []string {
+	return WrapText(text, height, report.MeasureTextHeight)
+}
+
+// 按当前字体将一个段落折成宽度不超过 width 的多行, 断行位置遵循 UAX #14 与中日文避头尾规则
+func (report *Report) WrapText(text string, width float64) []string {
+	return WrapText(text, width, report.MeasureTextWidth)
+}
+
+// 同 WrapText, 放不下的单词按 hyphenation 的语言规则断词, 断词的行以连字符结尾
+func (report *Report) WrapTextWithHyphenation(text string, width float64, hyphenation Hyphenation) []string {
+	return WrapTextWithHyphenation(text, width, report.MeasureTextWidth, hyphenation)
+}
+
 func (report *Report) GetFontMetrics(family string, size float64) (ascender, descender float64) {
 	return report.converter.GetFontMetrics(family, size)
 }
 
+func (report *Report) GetFontMetricsWithStyle(family, style string, size float64) (ascender, descender float64) {
+	return report.converter.GetFontMetricsWithStyle(family, style, size)
+}
+
+// 上标/下标以 size 大小的行中的字号以及基线的偏移(正值上移), 取自字体的 OS/2 表
+func (report *Report) GetScriptMetrics(family, style string, size float64, script Script) (scriptSize, shift float64) {
+	return report.converter.GetScriptMetrics(family, style, size, script)
+}
+
 func (report *Report) GetSpaceWidth(family string, size float64) float64 {
 	return report.converter.GetSpaceWidth(family, size)
 }
 
+// 当前的字体, 没有设置时 Family 为空
+func (report *Report) GetCurrentFont() Font {
+	return report.converter.font
+}
+
 // 设置当前文本字体, 先注册,后设置
 func (report *Report) SetFontWithStyle(family, style string, size int) {
 	report.converter.SetFont(family, style, size)
@@ -419,6 +495,40 @@ func (report *Report) SetFont(family string, size int) {
 	report.addAtomicCell("F|" + family + "|" + "" + "|" + strconv.Itoa(size))
 }
 
+// 设置图片重新编码成 JPEG 的质量(1-100). 默认为 0, JPEG 原样嵌入, PNG 等无损图片不转换成 JPEG
+func (report *Report) SetJPEGQuality(quality int) {
+	if quality > 100 {
+		quality = 100
+	}
+	report.jpegQuality = quality
+}
+
+func (report *Report) GetJPEGQuality() int {
+	return report.jpegQuality
+}
+
+// 设置没有记录分辨率的图片的 DPI, 图片的原始大小为像素宽高 * 72 / dpi(pt). 默认为 72, 一个像素为 1pt.
+// 须在创建图片之前设置
+func (report *Report) SetImageDPI(dpi float64) {
+	report.imageDPI = dpi
+}
+
+func (report *Report) GetImageDPI() float64 {
+	if report.imageDPI <= 0 {
+		return 72
+	}
+	return report.imageDPI
+}
+
+// 设置图片文件不存在或无法读取时的处理方式. 图片数据无法解码时图片的 GenerateAtomicCell 总是返回错误
+func (report *Report) SetMissingImagePolicy(policy MissingImagePolicy) {
+	report.missingImage = policy
+}
+
+func (report *Report) GetMissingImagePolicy() MissingImagePolicy {
+	return report.missingImage
+}
+
 func (report *Report) AddCallBack(callback CallBack) {
 	report.callbacks = append(report.callbacks, callback)
 }
@@ -449,6 +559,13 @@ func (report *Report) CellRight(x float64, y float64, w float64, content string)
 		util.Ftoa(w) + "|" + content)
 	report.SetXY(report.converter.GetXY())
 }
+
+// 竖排写入一列文本: x 为列的中线, y 为列的顶部. 中日韩文字直立并使用竖排字形(标点, 括号),
+// 拉丁文等横排文字顺时针旋转 90 度
+func (report *Report) VerticalCell(x float64, y float64, content string) {
+	report.addAtomicCell("CV|" + util.Ftoa(x) + "|" + util.Ftoa(y) + "|" + content)
+	report.SetXY(report.converter.GetXY())
+}
 func (report *Report) CellGray(x float64, y float64, content string, grayScale float64) {
 	report.grayFill(grayScale)
 	report.addAtomicCell("CL|" + util.Ftoa(x) + "|" + util.Ftoa(y) + "|" + content)
@@ -484,8 +601,26 @@ func (report *Report) Oval(x1 float64, y1 float64, x2 float64, y2 float64) {
 		"|" + util.Ftoa(y2))
 }
 
+// 绘制路径: 以颜色或渐变(RegisterGradient 返回的 key)填充, 以 style 的颜色, 宽度, 端点, 连接与虚线描边.
+// 不改变线条的颜色与线型
+func (report *Report) Path(path *Path, style PathStyle) {
+	if path.Empty() {
+		return
+	}
+	report.addAtomicCell("PA|" + style.String() + "|" + path.String())
+}
+
+// 注册线性或径向渐变, 返回的 key 作为 PathStyle.Fill 填充路径. 渐变的坐标为报表的坐标, 随变换(PushTransform)变换
+func (report *Report) RegisterGradient(gradient Gradient) string {
+	report.gradients++
+	key := GradientKeyPrefix + strconv.Itoa(report.gradients)
+	report.addAtomicCell("GD|" + key + "|" + gradient.String())
+	return key
+}
+
 // 设置当前的字体颜色, 线条颜色
 func (report *Report) TextDefaultColor() {
+	report.textColor = [3]int{1, 1, 1}
 	report.addAtomicCell("TC|" + strconv.Itoa(1) + "|" + strconv.Itoa(1) +
 		"|" + strconv.Itoa(1))
 }
@@ -496,9 +631,111 @@ func (report *Report) LineDefaultColor() {
 }
 
 func (report *Report) TextColor(red int, green int, blue int) {
+	report.textColor = [3]int{red, green, blue}
 	report.addAtomicCell("TC|" + strconv.Itoa(red) + "|" + strconv.Itoa(green) +
 		"|" + strconv.Itoa(blue))
 }
+
+// 当前的字体颜色, 没有设置时为黑色
+func (report *Report) GetTextColor() (red, green, blue int) {
+	return report.textColor[0], report.textColor[1], report.textColor[2]
+}
+
+// 设置之后绘制的内容的不透明度, 取值 0 到 1: fill 作用于填充的路径, 文本与图片, stroke 作用于描边的路径与线.
+// 直到 ClearTransparency, 不随 PopTransform 恢复
+func (report *Report) SetTransparency(fill, stroke float64) {
+	report.opacity = [2]float64{fill, stroke}
+	report.addAtomicCell("TA|" + strconv.FormatFloat(fill, 'f', 3, 64) + "|" + strconv.FormatFloat(stroke, 'f', 3, 64))
+}
+
+// 恢复不透明
+func (report *Report) ClearTransparency() {
+	report.opacity = [2]float64{1, 1}
+	report.addAtomicCell("TA")
+}
+
+// 当前的不透明度, 没有设置时都为 1
+func (report *Report) GetTransparency() (fill, stroke float64) {
+	return report.opacity[0], report.opacity[1]
+}
+
+// 设置后续文本行所在段落的基础方向(双向文本), DirectionAuto 时由每行的首个强方向字符决定
+func (report *Report) TextDirection(dir TextDirection) {
+	report.addAtomicCell("TD|" + dir.String())
+}
+
+// 设置后续文本的词间距(空格后追加的宽度)与字间距, 用于两端对齐, 见 JustifySpacing; 间距按行内分摊, 保留 4 位小数. 写完对齐的行后须恢复为 0
+func (report *Report) WordSpacing(spacing float64) {
+	report.addAtomicCell("WS|" + strconv.FormatFloat(spacing, 'f', 4, 64))
+}
+func (report *Report) CharSpacing(spacing float64) {
+	report.addAtomicCell("CS|" + strconv.FormatFloat(spacing, 'f', 4, 64))
+}
+
+// 设置后续文本的背景高亮颜色, 按字体的上伸/下伸填充文本所占的区域; 下划线/删除线/上划线由字体样式 "U"/"S"/"O" 指定
+func (report *Report) TextHighlight(red int, green int, blue int) {
+	report.addAtomicCell("HC|" + strconv.Itoa(red) + "|" + strconv.Itoa(green) +
+		"|" + strconv.Itoa(blue))
+}
+
+// 取消文本的背景高亮
+func (report *Report) TextDefaultHighlight() {
+	report.addAtomicCell("HC")
+}
+
+// 设置后续文本的渲染方式: 填充, 描边(TextRenderStroke), 填充并描边, 不可见(TextRenderInvisible, 不绘制文本但可以
+// 选择, 复制与搜索)以及裁剪. 裁剪方式下第一次写入的文本压入裁剪区域, 之后绘制的内容只在字形内可见, 直到对应的
+// PopTransform; 再次写入的文本与裁剪区域求交, 裁剪的文本应一次写入, 多行的文本使用 PushTextClip. TextRenderFill 恢复
+func (report *Report) TextRenderMode(mode TextRenderMode) {
+	report.addAtomicCell("TR|" + mode.String())
+}
+
+// 设置描边文本的描边颜色与宽度, 与文本颜色无关. 默认描边颜色为文本颜色, 宽度为字号的 3%
+func (report *Report) TextStrokeColor(red int, green int, blue int) {
+	report.addAtomicCell("TS|" + strconv.Itoa(red) + "|" + strconv.Itoa(green) +
+		"|" + strconv.Itoa(blue))
+}
+func (report *Report) TextStrokeWidth(width float64) {
+	report.addAtomicCell("TW|" + util.Ftoa(width))
+}
+
+// 恢复默认的描边颜色与宽度
+func (report *Report) TextDefaultStroke() {
+	report.addAtomicCell("TS")
+}
+
+// 变换之后绘制的内容(文本, 线, 矩形, 图片等), 直到对应的 PopTransform. 变换可以嵌套, 内层的变换先作用;
+// 变换不跨页, 换页时关闭仍未恢复的变换. 变换内设置的颜色与线型在恢复后失效
+func (report *Report) PushTransform(m Matrix) {
+	report.addAtomicCell("TP|" + m.String())
+}
+
+// 恢复最近一次 PushTransform 之前的坐标
+func (report *Report) PopTransform() {
+	report.addAtomicCell("TQ")
+}
+
+// 之后绘制的内容只在矩形 (x, y, w, h) 内可见, 直到对应的 PopTransform. 与变换使用同一个栈, 换页时关闭
+func (report *Report) ClipRect(x, y, w, h float64) {
+	report.addAtomicCell("TK|" + util.Ftoa(x) + "|" + util.Ftoa(y) + "|" + util.Ftoa(w) + "|" + util.Ftoa(h))
+}
+
+// 压入文本的裁剪区域, 直到对应的 PopTransform: 之后以裁剪方式写入的文本(可以多行)合成一个裁剪区域, 在第一次
+// 绘制其它内容或 PopTransform 时写入, 之后绘制的内容只在各行的字形内可见. 没有写入裁剪的文本时不裁剪
+func (report *Report) PushTextClip() {
+	report.addAtomicCell("TX")
+}
+
+// 以 (x, y) 为中心逆时针旋转 degrees 度, 之后绘制的内容旋转, 直到对应的 PopTransform
+func (report *Report) Rotate(degrees, x, y float64) {
+	report.PushTransform(RotateMatrix(degrees).Around(x, y))
+}
+
+// 设置后续文本基线的偏移, 正值上移, 负值下移; 写完后须恢复为 0
+func (report *Report) BaselineShift(shift float64) {
+	report.addAtomicCell("BS|" + strconv.FormatFloat(shift, 'f', 4, 64))
+}
+
 func (report *Report) LineColor(red int, green int, blue int) {
 	report.addAtomicCell("LC|" + strconv.Itoa(red) + "|" + strconv.Itoa(green) +
 		"|" + strconv.Itoa(blue))
@@ -569,6 +806,17 @@ func (report *Report) Image(path string, x1 float64, y1 float64, x2 float64, y2
 		util.Ftoa(x2) + "|" + util.Ftoa(y2))
 }
 
+// 注册内存中的图片数据(JPEG, PNG 等 gopdf 支持的格式), 返回引用图片的 key. 图片数据不写入磁盘.
+// key 为图片数据的哈希, 相同的图片只注册一次, 在 PDF 中只嵌入一次
+func (report *Report) RegisterImage(data []byte) string {
+	return report.converter.registerImage(data)
+}
+
+// 内存中的图片, key 为 RegisterImage 返回的 key
+func (report *Report) ImageByKey(key string, x1 float64, y1 float64, x2 float64, y2 float64) {
+	report.Image(ImageKeyPrefix+key, x1, y1, x2, y2)
+}
+
 // 添加变量
 func (report *Report) Var(name string, val string) {
 	report.addAtomicCell("V|" + name + "|" + val)
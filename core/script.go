@@ -0,0 +1,111 @@
+package core
+
+import (
+	"encoding/binary"
+)
+
+// Superscripts and subscripts are written in a smaller size with the baseline shifted up or
+// down ("BS" records). Size and shift come from the OS/2 table of the face (ySuperscriptYSize,
+// ySuperscriptYOffset, ySubscriptYSize, ySubscriptYOffset).
+
+// Script is the position of text relative to the baseline of its line.
+type Script int
+
+const (
+	ScriptBaseline Script = iota // on the baseline, in the size of the line
+	ScriptSuper                  // superscript: smaller, above the baseline
+	ScriptSub                    // subscript: smaller, below the baseline
+)
+
+// Script metrics used for faces that do not define them, relative to the font size.
+const (
+	defaultScriptSize  = 0.65
+	defaultSuperOffset = 0.35
+	defaultSubOffset   = 0.15
+)
+
+// scriptMetrics holds the size and the baseline shift of super- and subscripts, per em.
+type scriptMetrics struct {
+	superSize, superOffset float64 // superOffset raises the baseline
+	subSize, subOffset     float64 // subOffset lowers the baseline
+}
+
+// newScriptMetrics reads the script metrics of the OS/2 table of the TrueType data ttf, falling
+// back to defaults for the values the face leaves zero.
+func newScriptMetrics(ttf []byte) scriptMetrics {
+	m := scriptMetrics{
+		superSize:   defaultScriptSize,
+		superOffset: defaultSuperOffset,
+		subSize:     defaultScriptSize,
+		subOffset:   defaultSubOffset,
+	}
+	if len(ttf) < 12 {
+		return m
+	}
+	_, tables, err := readSfntTables(ttf, 0)
+	if err != nil {
+		return m
+	}
+	var head, os2 []byte
+	for _, table := range tables {
+		switch table.tag {
+		case "head":
+			head = table.data
+		case "OS/2":
+			os2 = table.data
+		}
+	}
+	if len(head) < 20 || len(os2) < 26 {
+		return m
+	}
+	upem := float64(binary.BigEndian.Uint16(head[18:]))
+	if upem == 0 {
+		return m
+	}
+	value := func(offset int) float64 {
+		return float64(int16(binary.BigEndian.Uint16(os2[offset:]))) / upem
+	}
+	if v := value(12); v > 0 {
+		m.subSize = v
+	}
+	if v := value(16); v > 0 {
+		m.subOffset = v
+	}
+	if v := value(20); v > 0 {
+		m.superSize = v
+	}
+	if v := value(24); v > 0 {
+		m.superOffset = v
+	}
+	return m
+}
+
+// GetScriptMetrics returns the font size of script text written in a line of the given size,
+// and how far its baseline is raised (negative: lowered).
+func (convert *Converter) GetScriptMetrics(family, style string, size float64, script Script) (scriptSize, shift float64) {
+	m := newScriptMetrics(nil)
+	if fm, ok := convert.metrics(fontKey(family, convert.ResolveFontStyle(family, style))); ok {
+		m = fm.script
+	}
+	switch script {
+	case ScriptSuper:
+		return m.superSize * size, m.superOffset * size
+	case ScriptSub:
+		return m.subSize * size, -m.subOffset * size
+	default:
+		return size, 0
+	}
+}
+
+// BaselineShift raises the baseline of the following text, lowers it for negative values.
+func (convert *Converter) BaselineShift(line string, elements []string) error {
+	if err := checkLength(line, elements, 2); err != nil {
+		return err
+	}
+	shift, err := parseFloatCell(elements[1], line)
+	if err != nil {
+		return err
+	}
+	convert.baselineShift = shift * convert.unit
+	return nil
+}
@@ -0,0 +1,219 @@
+package core
+
+import (
+	"bytes"
+
+	"github.com/go-text/typesetting/di"
+	tsfont "github.com/go-text/typesetting/font"
+	"github.com/go-text/typesetting/shaping"
+	"github.com/go-text/typesetting/unicodedata"
+	"golang.org/x/image/math/fixed"
+)
+
+// The shaping stage sits between the "C"/"CL"/"CR" records and gopdf.Text: text is shaped with
+// the GSUB/GPOS tables of the embedded font (kerning, ligatures, Arabic joining, Indic reordering,
+// ...) and written as runs of glyphs at their shaped positions.
+//
+// gopdf only writes glyphs reachable through the cmap, so every embedded font gets a format 12
+// cmap group mapping glyphRuneBase+gid to glyph gid (see trueTypeFont). Shaped glyphs that are not
+// the nominal glyph of a single character are written through these private-use code points;
+// the ToUnicode maps of the written PDF map them back to the characters they stand for (see
+// fixToUnicode).
+
+// glyphRuneBase is the first code point of the private-use plane mapped onto the glyphs of every embedded font.
+const glyphRuneBase = 0xF0000
+
+// shapedWidthCacheSize bounds the number of measured strings kept per Converter.
+const shapedWidthCacheSize = 8192
+
+type shapedGlyph struct {
+	r       rune    // code point written to the PDF
+	text    string  // characters of the cluster on its first glyph, empty on the others
+	cluster int     // index of the first character of the cluster in the shaped text
+	advance float64 // shaped advance, font units
+	nominal float64 // advance gopdf uses for r, font units
+	dx, dy  float64 // shaped offset, font units (y up)
+}
+
+type textShaper struct {
+	faces     map[string]*tsfont.Face // key: fontKey(family, style)
+	segmenter shaping.Segmenter
+	shaper    shaping.HarfbuzzShaper
+	widths    map[string]float64 // key: fontKey + "\x00" + text, value: font units
+}
+
+type singleFace struct {
+	face *tsfont.Face
+}
+
+func (f singleFace) ResolveFace(rune) *tsfont.Face { return f.face }
+
+func newTextShaper() *textShaper {
+	return &textShaper{
+		faces:  make(map[string]*tsfont.Face),
+		widths: make(map[string]float64),
+	}
+}
+
+// addFace registers the TrueType data embedded for key. Fonts the shaper cannot read are
+// written unshaped.
+func (s *textShaper) addFace(key string, ttf []byte) {
+	face, err := tsfont.ParseTTF(bytes.NewReader(ttf))
+	if err != nil {
+		delete(s.faces, key)
+		return
+	}
+	s.faces[key] = face
+}
+
+// upem returns the units per em of the face registered for key, 0 if there is none.
+func (s *textShaper) upem(key string) float64 {
+	if s == nil {
+		return 0
+	}
+	if face, ok := s.faces[key]; ok {
+		return float64(face.Upem())
+	}
+	return 0
+}
+
+// shape returns the glyphs of text in the face registered for key, in drawing order. text is one
+// line of a paragraph with base direction dir: its bidi runs are shaped in their own direction
+// and drawn in visual order.
+func (s *textShaper) shape(key, text string, dir TextDirection) ([]shapedGlyph, bool) {
+	if s == nil {
+		return nil, false
+	}
+	face, ok := s.faces[key]
+	if !ok {
+		return nil, false
+	}
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return nil, true
+	}
+
+	// every run of characters at one embedding level is segmented and shaped in its direction
+	space, _ := face.NominalGlyph(' ')
+	var (
+		runs      [][]shapedGlyph
+		runLevels []int
+	)
+	levels := bidiLevels(runes, ResolveDirection(dir, text) == DirectionRTL)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && levels[end] == levels[start] {
+			end++
+		}
+		input := shaping.Input{
+			Text:      runes,
+			RunStart:  start,
+			RunEnd:    end,
+			Direction: di.DirectionLTR,
+			Face:      face,
+			Size:      fixed.Int26_6(face.Upem()) << 6, // positions in font units
+		}
+		if levels[start]%2 == 1 {
+			input.Direction = di.DirectionRTL
+		}
+		for _, run := range s.segmenter.Split(input, singleFace{face: face}) {
+			out := s.shaper.Shape(run)
+			var glyphs []shapedGlyph
+			for _, g := range out.Glyphs {
+				sg := shapedGlyph{
+					cluster: g.ClusterIndex,
+					advance: float64(g.XAdvance) / 64,
+					nominal: float64(face.HorizontalAdvance(g.GlyphID)),
+					dx:      float64(g.XOffset) / 64,
+					dy:      float64(g.YOffset) / 64,
+				}
+				sg.r = glyphRune(face, g, runes[g.ClusterIndex])
+				sg.text = clusterText(runes, g, glyphs)
+				if g.GlyphID == 0 {
+					sg.advance = float64(face.HorizontalAdvance(space))
+					sg.nominal = sg.advance
+				}
+				glyphs = append(glyphs, sg)
+			}
+			runs = append(runs, glyphs)
+			runLevels = append(runLevels, levels[start])
+		}
+		start = end
+	}
+
+	var glyphs []shapedGlyph
+	for _, i := range VisualOrder(runLevels) {
+		glyphs = append(glyphs, runs[i]...)
+	}
+	return glyphs, true
+}
+
+// width returns the shaped advance of text in font units.
+func (s *textShaper) width(key, text string) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+	cacheKey := key + "\x00" + text
+	if w, ok := s.widths[cacheKey]; ok {
+		return w, true
+	}
+	glyphs, ok := s.shape(key, text, DirectionAuto)
+	if !ok {
+		return 0, false
+	}
+	w := 0.0
+	for _, g := range glyphs {
+		w += g.advance
+	}
+	if len(s.widths) >= shapedWidthCacheSize {
+		s.widths = make(map[string]float64)
+	}
+	s.widths[cacheKey] = w
+	return w, true
+}
+
+// glyphRune returns the code point written for the shaped glyph g, first being the first
+// character of its cluster.
+func glyphRune(face *tsfont.Face, g shaping.Glyph, first rune) rune {
+	switch {
+	case g.GlyphID == 0:
+		// missing glyph: let gopdf substitute it, as unshaped text does
+		return first
+	case g.RuneCount == 1 && g.GlyphCount == 1 && nominalGlyph(face, first) == g.GlyphID:
+		return first
+	case g.RuneCount == 1 && g.GlyphCount == 1 && mirroredGlyph(face, first) == g.GlyphID:
+		// bracket mirrored in a right-to-left run (rule L4)
+		m, _ := unicodedata.LookupMirrorChar(first)
+		return m
+	default:
+		return glyphRuneBase + rune(g.GlyphID)
+	}
+}
+
+// clusterText returns the characters of the cluster of g, runes[ClusterIndex:] for RuneCount
+// characters, if g is the first of glyphs in its cluster.
+func clusterText(runes []rune, g shaping.Glyph, glyphs []shapedGlyph) string {
+	start := g.ClusterIndex
+	end := start + g.RuneCount
+	if start < 0 || end > len(runes) {
+		return ""
+	}
+	if n := len(glyphs); n > 0 && glyphs[n-1].cluster == g.ClusterIndex {
+		return ""
+	}
+	return string(runes[start:end])
+}
+
+func nominalGlyph(face *tsfont.Face, r rune) tsfont.GID {
+	gid, _ := face.NominalGlyph(r)
+	return gid
+}
+
+// mirroredGlyph returns the nominal glyph of the mirrored form of r, 0 if r does not mirror.
+func mirroredGlyph(face *tsfont.Face, r rune) tsfont.GID {
+	m, ok := unicodedata.LookupMirrorChar(r)
+	if !ok {
+		return 0
+	}
+	return nominalGlyph(face, m)
+}
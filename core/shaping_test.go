@@ -0,0 +1,269 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"testing"
+	"unicode/utf16"
+)
+
+// ligatureFont returns testdata/TestGPOSOne.ttf, a font with the fi, fl and ffi ligatures.
+func ligatureFont(t *testing.T) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile("../testdata/TestGPOSOne.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// toUnicodeMaps returns the glyph to text maps of the ToUnicode CMaps of pdf.
+func toUnicodeMaps(t *testing.T, pdf []byte) []map[int]string {
+	t.Helper()
+	entry := regexp.MustCompile(`<([0-9A-F]{4})><([0-9A-F]{4})><([0-9A-F]+)>`)
+	var maps []map[int]string
+	for _, stream := range bytes.Split(pdf, []byte("beginbfrange\n"))[1:] {
+		stream = stream[:bytes.Index(stream, []byte("endbfrange"))]
+		m := map[int]string{}
+		for _, e := range entry.FindAllSubmatch(stream, -1) {
+			gid, _ := strconv.ParseInt(string(e[1]), 16, 32)
+			if len(e[3])%4 != 0 {
+				t.Fatalf("destination %s is not UTF-16", e[3])
+			}
+			var units []uint16
+			for i := 0; i < len(e[3]); i += 4 {
+				u, _ := strconv.ParseUint(string(e[3][i:i+4]), 16, 16)
+				units = append(units, uint16(u))
+			}
+			m[int(gid)] = string(utf16.Decode(units))
+		}
+		maps = append(maps, m)
+	}
+	return maps
+}
+
+func TestShapeLigature(t *testing.T) {
+	ttf, err := trueTypeFont(ligatureFont(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTextShaper()
+	s.addFace("liga", ttf)
+
+	tests := []struct {
+		text  string
+		runes []rune
+		texts []string
+	}{
+		{"fi", []rune{glyphRuneBase + 28}, []string{"fi"}},
+		{"fl", []rune{glyphRuneBase + 29}, []string{"fl"}},
+		{"ffi", []rune{'f', glyphRuneBase + 28}, []string{"f", "fi"}},
+	}
+	for _, test := range tests {
+		glyphs, ok := s.shape("liga", test.text, DirectionLTR)
+		if !ok || len(glyphs) != len(test.runes) {
+			t.Errorf("%q shaped into %d glyphs, want %d", test.text, len(glyphs), len(test.runes))
+			continue
+		}
+		for i, g := range glyphs {
+			if g.r != test.runes[i] || g.text != test.texts[i] {
+				t.Errorf("%q glyph %d: %U for %q, want %U for %q", test.text, i, g.r, g.text, test.runes[i], test.texts[i])
+			}
+		}
+	}
+}
+
+func TestToUnicodeLigature(t *testing.T) {
+	r := CreateReport()
+	if err := r.AddFontFamily(FontFamily{Name: "liga", Regular: &FontMap{Data: ligatureFont(t)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *Report) {
+		report.SetFont("liga", 12)
+		report.Cell(50, 50, "fi fl ffi")
+	}, Detail)
+	pdf, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the ligatures are extracted as their characters, not as private-use code points
+	var liga map[int]string
+	for _, m := range toUnicodeMaps(t, pdf) {
+		if _, ok := m[28]; ok && len(m) < 10 {
+			liga = m
+		}
+	}
+	if liga == nil {
+		t.Fatalf("no ToUnicode map of the ligature font")
+	}
+	want := map[int]string{28: "fi", 29: "fl"}
+	for gid, text := range want {
+		if liga[gid] != text {
+			t.Errorf("glyph %d extracted as %q, want %q", gid, liga[gid], text)
+		}
+	}
+	for gid, text := range liga {
+		for _, c := range text {
+			if isGlyphRune(c) {
+				t.Errorf("glyph %d extracted as the private-use %U", gid, c)
+			}
+		}
+	}
+
+	// the cross-reference table still points at the objects
+	offsets, xref, ok := readXref(pdf)
+	if !ok || !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatal("cross-reference table not found")
+	}
+	for i, offset := range offsets {
+		if obj := fmt.Sprintf("%d 0 obj\n", i+1); !bytes.HasPrefix(pdf[offset:], []byte(obj)) {
+			t.Errorf("cross-reference entry %d points at %q", i+1, pdf[offset:offset+len(obj)])
+		}
+	}
+}
+
+func TestToUnicodeLayout(t *testing.T) {
+	r := CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *Report) {
+		report.SetFont(FontSans, 12)
+		report.Cell(50, 50, "text")
+	}, Detail)
+	pdf, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	convert := r.converter
+	if _, err := convert.fixToUnicode(pdf); err != nil {
+		t.Fatalf("written document rejected: %v", err)
+	}
+
+	// a document not laid out as gopdf writes it is an error, not returned as it is
+	broken := map[string][]byte{
+		"cross-reference table": bytes.Replace(pdf, []byte("startxref\n"), []byte("startxref\nx"), 1),
+		"font resources":        bytes.Replace(pdf, []byte("/Font <<"), []byte("/Font [["), 1),
+		"font resource":         bytes.Replace(pdf, []byte("\t/F1 "), []byte("\t/F9 "), 1),
+		"ToUnicode reference":   bytes.Replace(pdf, []byte("/ToUnicode "), []byte("/ToUnicodE "), -1),
+	}
+	for name, data := range broken {
+		if out, err := convert.fixToUnicode(data); err == nil || out != nil {
+			t.Errorf("document with a broken %s accepted", name)
+		}
+	}
+	convert.pdfFonts = append(convert.pdfFonts, "missing|")
+	if _, err := convert.fixToUnicode(pdf); err == nil {
+		t.Error("document with fewer font resources than fonts accepted")
+	}
+}
+
+func TestAddGlyphCmapBounds(t *testing.T) {
+	valid := []byte{0, 0, 0, 1, 0, 3, 0, 1, 0, 0, 0, 12, 0, 4, 0, 0}
+	if _, err := addGlyphCmap(valid, 5); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		cmap      []byte
+		numGlyphs int
+	}{
+		{"no glyphs", valid, 0},
+		{"short header", []byte{0, 0, 0}, 5},
+		{"short directory", []byte{0, 0, 0, 2, 0, 3, 0, 1, 0, 0, 0, 12}, 5},
+		{"subtable in the directory", []byte{0, 0, 0, 1, 0, 3, 0, 1, 0, 0, 0, 4, 0, 4}, 5},
+		{"subtable out of the table", []byte{0, 0, 0, 1, 0, 3, 0, 1, 0, 0, 0, 15, 0, 4, 0, 0}, 5},
+		{"short format 12", []byte{0, 0, 0, 1, 0, 3, 0, 10, 0, 0, 0, 12, 0, 12, 0, 0, 0, 0, 0, 40, 0, 0, 0, 0, 0, 0, 0, 9}, 5},
+	}
+	for _, test := range tests {
+		if _, err := addGlyphCmap(test.cmap, test.numGlyphs); err == nil {
+			t.Errorf("%s: cmap accepted", test.name)
+		}
+	}
+}
+
+func TestShapeBidi(t *testing.T) {
+	ttf, err := trueTypeFont(DefaultFontMaps()[0].Data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTextShaper()
+	s.addFace("sans", ttf)
+
+	// the face has no Hebrew nor Arabic glyph: their characters are written as they are, in visual order
+	tests := []struct {
+		text string
+		dir  TextDirection
+		want string
+	}{
+		{"abc שלום def", DirectionLTR, "abc םולש def"},
+		{"שלום abc", DirectionAuto, "abc םולש"},
+		{"abc שלום", DirectionRTL, "םולש abc"},
+		{"سلام 123", DirectionRTL, "123 مالس"},
+		{"سلام عليكم", DirectionAuto, "مكيلع مالس"},
+		// brackets take their mirrored glyph in right-to-left runs (rule L4)
+		{"(שלום)", DirectionRTL, "(םולש)"},
+		{"a [שלום] b", DirectionRTL, "b [םולש] a"},
+		{"a (b) c", DirectionLTR, "a (b) c"},
+		// numbers after right-to-left text stay at level 2 (rules W7, I1), a neutral between them
+		// takes the direction of the numbers (N1)
+		{"abc שלום 123", DirectionLTR, "abc 123 םולש"},
+		{"abc שלום 1.5%", DirectionLTR, "abc 1.5% םולש"},
+		{"abc 123", DirectionLTR, "abc 123"},
+		{"שלום 12, abc", DirectionLTR, "12 םולש, abc"},
+		{"שלום 123abc", DirectionLTR, "123 םולשabc"},
+		// neutrals between runs of opposite direction take the paragraph direction (N2)
+		{"abc - שלום", DirectionLTR, "abc - םולש"},
+		{"שלום - abc", DirectionRTL, "abc - םולש"},
+		{"abc - שלום - def", DirectionRTL, "def - םולש - abc"},
+		{"abc 123 def", DirectionRTL, "abc 123 def"},
+	}
+	for _, test := range tests {
+		glyphs, ok := s.shape("sans", test.text, test.dir)
+		if !ok {
+			t.Fatalf("%q not shaped", test.text)
+		}
+		var got []rune
+		for _, g := range glyphs {
+			got = append(got, g.r)
+		}
+		if string(got) != test.want {
+			t.Errorf("%q (%s) drawn as %q, want %q", test.text, test.dir, string(got), test.want)
+		}
+	}
+}
+
+func TestShapeVertical(t *testing.T) {
+	data, err := ioutil.ReadFile("../testdata/VertTest.ttf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTextShaper()
+	s.addFace("vert", data)
+
+	// the corner bracket (glyph 1) takes its vertical form (glyph 2)
+	runs, ok := s.shapeVertical("vert", "「")
+	if !ok || len(runs) != 1 || runs[0].sideways || len(runs[0].glyphs) != 1 {
+		t.Fatalf("got runs %+v, want one upright glyph", runs)
+	}
+	if g := runs[0].glyphs[0]; g.r != glyphRuneBase+2 || g.text != "「" {
+		t.Errorf("got %U for %q, want %U for %q", g.r, g.text, glyphRuneBase+2, "「")
+	}
+
+	// the vertical form is extracted as the bracket. gopdf does not embed the test font (it has no
+	// PostScript name): its ToUnicode entry for the form is rewritten as the one gopdf writes
+	convert := &Converter{}
+	convert.glyphText("vert", runs[0].glyphs[0])
+	cmap := "1 beginbfrange\n<0002><0002><F0002>\nendbfrange"
+	fixed, changed := rewriteCMap([]byte(cmap), convert.glyphTexts["vert"])
+	if want := "1 beginbfrange\n<0002><0002><300C>\nendbfrange"; !changed || string(fixed) != want {
+		t.Errorf("got ToUnicode %q, want %q", fixed, want)
+	}
+}
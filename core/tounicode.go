@@ -0,0 +1,292 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The ToUnicode map gopdf writes for a font maps each of its glyphs to the code point written for
+// it. Shaped glyphs written through the private-use code points glyphRuneBase+gid (ligatures,
+// contextual and vertical forms, ...) would be extracted as these code points: fixToUnicode maps
+// them back to the characters they stand for, recorded by glyphText while the text is written.
+// It also writes the code points beyond the BMP as UTF-16 surrogate pairs, as the CMap requires.
+
+var (
+	toUnicodeRef  = regexp.MustCompile(`/ToUnicode (\d+) 0 R\n`)
+	fontResources = regexp.MustCompile(`(?s)/Font <<\n(.*?)>>\n`)
+	fontResource  = regexp.MustCompile(`/F(\d+) (\d+) 0 R\n`)
+	bfrangeCount  = regexp.MustCompile(`(?m)^\d+ beginbfrange$`)
+	bfrangeEntry  = regexp.MustCompile(`^<([0-9A-F]+)><([0-9A-F]+)><([0-9A-F]+)>$`)
+)
+
+// glyphText records the characters the glyph g written with the face key stands for. The first
+// characters recorded for a glyph are kept.
+func (convert *Converter) glyphText(key string, g shapedGlyph) {
+	if g.r < glyphRuneBase || g.text == "" {
+		return
+	}
+	if convert.glyphTexts == nil {
+		convert.glyphTexts = make(map[string]map[rune]string)
+	}
+	texts, ok := convert.glyphTexts[key]
+	if !ok {
+		texts = make(map[rune]string)
+		convert.glyphTexts[key] = texts
+	}
+	if _, ok := texts[g.r]; !ok {
+		texts[g.r] = g.text
+	}
+}
+
+// fixToUnicode returns pdf, as written by gopdf, with the ToUnicode maps of its fonts rewritten
+// and its cross-reference table updated. The fonts are found through the font resources the
+// content streams use: the resource /Fn is the font object of the n-th face added to gopdf
+// (pdfFonts). It returns an error when pdf is not laid out as expected.
+func (convert *Converter) fixToUnicode(pdf []byte) ([]byte, error) {
+	offsets, xref, ok := readXref(pdf)
+	if !ok {
+		return nil, errors.New("ToUnicode maps: unreadable cross-reference table")
+	}
+	if bytes.Contains(pdf[xref:], []byte("/Encrypt ")) {
+		return nil, errors.New("ToUnicode maps: the document is encrypted")
+	}
+	resources := fontResources.FindSubmatch(pdf[:xref])
+	if resources == nil {
+		return nil, errors.New("ToUnicode maps: no font resources")
+	}
+	fonts := fontResource.FindAllSubmatch(resources[1], -1)
+	if len(fonts) != len(convert.pdfFonts) {
+		return nil, fmt.Errorf("ToUnicode maps: %d font resources for %d fonts", len(fonts), len(convert.pdfFonts))
+	}
+
+	var edits []pdfEdit
+	for _, font := range fonts {
+		n, _ := strconv.Atoi(string(font[1]))
+		id, _ := strconv.Atoi(string(font[2]))
+		if n < 1 || n > len(convert.pdfFonts) || id < 1 || id > len(offsets) {
+			return nil, fmt.Errorf("ToUnicode maps: font resource %q out of range", bytes.TrimSpace(font[0]))
+		}
+		key := convert.pdfFonts[n-1]
+
+		obj := pdf[offsets[id-1]:xref]
+		end := bytes.Index(obj, []byte("endobj\n"))
+		if end < 0 || !bytes.Contains(obj[:end], []byte("/Subtype /Type0\n")) {
+			return nil, fmt.Errorf("ToUnicode maps: font %q is not a Type0 font", key)
+		}
+		m := toUnicodeRef.FindSubmatch(obj[:end])
+		if m == nil {
+			return nil, fmt.Errorf("ToUnicode maps: font %q without a ToUnicode map", key)
+		}
+		id, _ = strconv.Atoi(string(m[1]))
+		if id < 1 || id > len(offsets) {
+			return nil, fmt.Errorf("ToUnicode maps: font %q with its map out of range", key)
+		}
+		start, body, ok := readStream(pdf[:xref], offsets[id-1])
+		if !ok {
+			return nil, fmt.Errorf("ToUnicode maps: unreadable map of font %q", key)
+		}
+		cmap, changed := rewriteCMap(pdf[body.start:body.end], convert.glyphTexts[key])
+		if !changed {
+			continue
+		}
+		data := []byte(fmt.Sprintf("<<\n/Length %d\n>>\nstream\n", len(cmap)))
+		edits = append(edits, pdfEdit{start: start, end: body.end, data: append(data, cmap...)})
+	}
+	out := applyEdits(pdf, offsets, xref, edits)
+	if out == nil {
+		return nil, errors.New("ToUnicode maps: unreadable trailer")
+	}
+	return out, nil
+}
+
+// pdfEdit replaces the bytes of a written PDF in [start, end) with data.
+type pdfEdit struct {
+	start, end int
+	data       []byte
+}
+
+// applyEdits returns pdf, whose objects start at offsets and whose cross-reference table is at
+// xref, with the object ranges of edits replaced and its cross-reference table updated, or nil
+// when the trailer is not found.
+func applyEdits(pdf []byte, offsets []int, xref int, edits []pdfEdit) []byte {
+	if len(edits) == 0 {
+		return pdf
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	// new offset of the byte at old offset at
+	shift := func(at int) int {
+		moved := at
+		for _, e := range edits {
+			if e.start >= at {
+				break
+			}
+			moved += len(e.data) - (e.end - e.start)
+		}
+		return moved
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, e := range edits {
+		out.Write(pdf[last:e.start])
+		out.Write(e.data)
+		last = e.end
+	}
+	trailer := bytes.Index(pdf[xref:], []byte("trailer\n"))
+	if trailer < 0 {
+		return nil
+	}
+	out.Write(pdf[last:xref])
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", shift(offset))
+	}
+	tail := pdf[xref+trailer:]
+	startxref := bytes.LastIndex(tail, []byte("startxref\n"))
+	out.Write(tail[:startxref])
+	fmt.Fprintf(&out, "startxref\n%d\n%%%%EOF\n", shift(xref))
+	return out.Bytes()
+}
+
+// readXref reads the cross-reference table gopdf writes: a single section of in-use objects
+// numbered from 1, at the offset given by startxref.
+func readXref(pdf []byte) (offsets []int, xref int, ok bool) {
+	at := bytes.LastIndex(pdf, []byte("startxref\n"))
+	if at < 0 {
+		return nil, 0, false
+	}
+	fields := strings.Fields(string(pdf[at+len("startxref\n"):]))
+	if len(fields) == 0 {
+		return nil, 0, false
+	}
+	xref, err := strconv.Atoi(fields[0])
+	if err != nil || xref < 0 || xref >= at {
+		return nil, 0, false
+	}
+
+	var size int
+	table := pdf[xref:at]
+	if _, err := fmt.Sscanf(string(table), "xref\n0 %d\n", &size); err != nil || size < 1 {
+		return nil, 0, false
+	}
+	entries := table[bytes.IndexByte(table[len("xref\n"):], '\n')+len("xref\n")+1:]
+	if len(entries) < 20*size {
+		return nil, 0, false
+	}
+	for i := 1; i < size; i++ {
+		entry := entries[20*i : 20*i+20]
+		if !bytes.HasSuffix(entry, []byte(" 00000 n \n")) {
+			return nil, 0, false
+		}
+		offset, err := strconv.Atoi(string(entry[:10]))
+		if err != nil || offset >= xref {
+			return nil, 0, false
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, xref, true
+}
+
+type byteRange struct {
+	start, end int
+}
+
+// readStream reads the stream object at offset written by gopdf: a dictionary holding only its
+// /Length. It returns the offset of the dictionary and the range of the stream data.
+func readStream(pdf []byte, offset int) (dict int, data byteRange, ok bool) {
+	if offset >= len(pdf) {
+		return 0, data, false
+	}
+	obj := pdf[offset:]
+	header := bytes.Index(obj, []byte(" 0 obj\n"))
+	if header < 0 {
+		return 0, data, false
+	}
+	dict = header + len(" 0 obj\n")
+	var length int
+	if _, err := fmt.Sscanf(string(obj[dict:]), "<<\n/Length %d\n>>\nstream\n", &length); err != nil {
+		return 0, data, false
+	}
+	start := bytes.Index(obj[dict:], []byte(">>\nstream\n")) + dict + len(">>\nstream\n")
+	if length < 0 || start+length > len(obj) || !bytes.HasPrefix(obj[start+length:], []byte("endstream\n")) {
+		return 0, data, false
+	}
+	return offset + dict, byteRange{offset + start, offset + start + length}, true
+}
+
+// rewriteCMap returns the ToUnicode CMap cmap with its private-use destinations replaced by the
+// characters of texts, or dropped when there are none or when the glyph is already mapped to a
+// character, and its destinations beyond the BMP written in UTF-16. It reports whether cmap changed.
+func rewriteCMap(cmap []byte, texts map[rune]string) ([]byte, bool) {
+	lines := strings.Split(string(cmap), "\n")
+	type entry struct {
+		line     int
+		src, dst string
+		r        rune
+	}
+	var entries []entry
+	mapped := map[string]bool{}
+	for i, line := range lines {
+		m := bfrangeEntry.FindStringSubmatch(line)
+		if m == nil || m[1] != m[2] {
+			continue
+		}
+		r, err := strconv.ParseUint(m[3], 16, 32)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{line: i, src: m[1], dst: m[3], r: rune(r)})
+		if !isGlyphRune(rune(r)) {
+			mapped[m[1]] = true
+		}
+	}
+
+	changed := false
+	dropped := map[int]bool{}
+	for _, e := range entries {
+		var text string
+		switch {
+		case isGlyphRune(e.r) && (mapped[e.src] || texts[e.r] == ""):
+			dropped[e.line] = true
+			changed = true
+			continue
+		case isGlyphRune(e.r):
+			text = texts[e.r]
+		default:
+			text = string(e.r)
+		}
+		var dst strings.Builder
+		for _, u := range utf16.Encode([]rune(text)) {
+			fmt.Fprintf(&dst, "%04X", u)
+		}
+		if dst.String() != e.dst {
+			lines[e.line] = "<" + e.src + "><" + e.src + "><" + dst.String() + ">"
+			changed = true
+		}
+	}
+	if !changed {
+		return cmap, false
+	}
+
+	kept := lines[:0]
+	for i, line := range lines {
+		if !dropped[i] {
+			kept = append(kept, line)
+		}
+	}
+	out := strings.Join(kept, "\n")
+	out = bfrangeCount.ReplaceAllLiteralString(out, fmt.Sprintf("%d beginbfrange", len(entries)-len(dropped)))
+	return []byte(out), true
+}
+
+// isGlyphRune reports whether r is one of the private-use code points mapped onto the glyphs of a font.
+func isGlyphRune(r rune) bool {
+	return r >= glyphRuneBase && r < glyphRuneBase+0x10000
+}
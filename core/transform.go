@@ -0,0 +1,163 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/signintech/gopdf"
+)
+
+// Transforms map the report coordinates of everything drawn between a "TP" record (push) and
+// the matching "TQ" record (pop): text, lines, rectangles, images, ... Transforms nest, the
+// inner one is applied first. A transform ends with its page: transforms still open at a page
+// break are closed, their pops are ignored. Colors and line styles set inside a transform
+// are reset when it is popped. A "TK" record pushes a clipping rectangle on the same stack:
+// what is drawn until the matching "TQ" record is visible only inside it. A "TX" record pushes
+// the clipping region of the following clipping text (see RenderMode).
+
+// Matrix is an affine transform of report coordinates (pt, y down): the point (x, y) maps to
+// (A*x + C*y + E, B*x + D*y + F).
+type Matrix struct {
+	A, B, C, D, E, F float64
+}
+
+// IdentityMatrix returns the transform that keeps every point.
+func IdentityMatrix() Matrix {
+	return Matrix{A: 1, D: 1}
+}
+
+// TranslateMatrix returns the transform that moves every point by (tx, ty).
+func TranslateMatrix(tx, ty float64) Matrix {
+	return Matrix{A: 1, D: 1, E: tx, F: ty}
+}
+
+// ScaleMatrix returns the transform that scales by sx horizontally and sy vertically around
+// the origin.
+func ScaleMatrix(sx, sy float64) Matrix {
+	return Matrix{A: sx, D: sy}
+}
+
+// RotateMatrix returns the transform that rotates by degrees around the origin, counterclockwise
+// on the page.
+func RotateMatrix(degrees float64) Matrix {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	// quarter turns are exact
+	sin, cos = math.Round(sin*1e12)/1e12, math.Round(cos*1e12)/1e12
+	return Matrix{A: cos, B: -sin, C: sin, D: cos}
+}
+
+// SkewMatrix returns the transform that slants vertical lines by x degrees and horizontal lines
+// by y degrees around the origin.
+func SkewMatrix(x, y float64) Matrix {
+	return Matrix{A: 1, B: math.Tan(y * math.Pi / 180), C: math.Tan(x * math.Pi / 180), D: 1}
+}
+
+// Multiply returns the transform that applies m, then n.
+func (m Matrix) Multiply(n Matrix) Matrix {
+	return Matrix{
+		A: m.A*n.A + m.B*n.C,
+		B: m.A*n.B + m.B*n.D,
+		C: m.C*n.A + m.D*n.C,
+		D: m.C*n.B + m.D*n.D,
+		E: m.E*n.A + m.F*n.C + n.E,
+		F: m.E*n.B + m.F*n.D + n.F,
+	}
+}
+
+// Around returns m applied around the point (x, y) instead of the origin.
+func (m Matrix) Around(x, y float64) Matrix {
+	return TranslateMatrix(-x, -y).Multiply(m).Multiply(TranslateMatrix(x, y))
+}
+
+// Apply returns the point (x, y) maps to.
+func (m Matrix) Apply(x, y float64) (float64, float64) {
+	return m.A*x + m.C*y + m.E, m.B*x + m.D*y + m.F
+}
+
+// String formats m as the fields of a "TP" record.
+func (m Matrix) String() string {
+	s := ""
+	for i, v := range []float64{m.A, m.B, m.C, m.D, m.E, m.F} {
+		if i > 0 {
+			s += "|"
+		}
+		s += strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	return s
+}
+
+// Transform pushes ("TP") or pops ("TQ") a transform of the following drawing.
+func (convert *Converter) Transform(line string, elements []string) error {
+	if elements[0] == "TQ" {
+		if convert.transforms == 0 {
+			return nil
+		}
+		convert.transforms--
+		if convert.transforms < convert.textClip {
+			convert.textClip, convert.textClipID = 0, 0
+		}
+		convert.pdf.RestoreGraphicsState()
+		// the transparency outlives the transform
+		if convert.alpha != "" {
+			return convert.rawContent(convert.alpha)
+		}
+		return nil
+	}
+
+	if err := checkLength(line, elements, 7); err != nil {
+		return err
+	}
+	var v [6]float64
+	for i := range v {
+		f, err := parseFloatCell(elements[i+1], line)
+		if err != nil {
+			return err
+		}
+		v[i] = f
+	}
+	m := convert.pdfMatrix(Matrix{A: v[0], B: v[1], C: v[2], D: v[3], E: v[4], F: v[5]})
+	convert.transforms++
+	convert.pdf.SaveGraphicsState()
+	return convert.rawContent(fmt.Sprintf("%.6f %.6f %.6f %.6f %.4f %.4f cm", m.A, m.B, m.C, m.D, m.E, m.F))
+}
+
+// pdfMatrix returns the transform m of report coordinates in PDF space. Report space is PDF space
+// flipped at the page height: the transform is conjugated by the flip.
+func (convert *Converter) pdfMatrix(m Matrix) Matrix {
+	h := convert.pageHeight
+	return Matrix{A: m.A, B: -m.B, C: -m.C, D: m.D, E: m.E*convert.unit + m.C*h, F: h - m.D*h - m.F*convert.unit}
+}
+
+// Clip pushes ("TK") a clipping rectangle of the following drawing.
+func (convert *Converter) Clip(line string, elements []string) error {
+	if err := checkLength(line, elements, 5); err != nil {
+		return err
+	}
+	var v [4]float64
+	for i := range v {
+		f, err := parseFloatCell(elements[i+1], line)
+		if err != nil {
+			return err
+		}
+		v[i] = f * convert.unit
+	}
+	x, y, w, h := v[0], v[1], v[2], v[3]
+	convert.transforms++
+	convert.pdf.SaveGraphicsState()
+	convert.pdf.ClipPolygon([]gopdf.Point{{X: x, Y: y}, {X: x + w, Y: y}, {X: x + w, Y: y + h}, {X: x, Y: y + h}})
+	return nil
+}
+
+// closeTransforms pops the transforms still open.
+func (convert *Converter) closeTransforms() error {
+	if err := convert.endTextClip(); err != nil {
+		return err
+	}
+	convert.textClip, convert.textClipID = 0, 0
+	for convert.transforms > 0 {
+		convert.transforms--
+		convert.pdf.RestoreGraphicsState()
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestMatrixMultiply(t *testing.T) {
+	matrices := []Matrix{
+		TranslateMatrix(10, -20),
+		ScaleMatrix(2, 0.5),
+		RotateMatrix(90),
+		RotateMatrix(30),
+		SkewMatrix(15, -10),
+		{A: 1, B: 2, C: 3, D: 4, E: 5, F: 6},
+	}
+	points := [][2]float64{{0, 0}, {1, 0}, {0, 1}, {-3.5, 7}}
+	// m.Multiply(n) applies m, then n
+	for _, m := range matrices {
+		for _, n := range matrices {
+			mn := m.Multiply(n)
+			for _, p := range points {
+				x, y := m.Apply(p[0], p[1])
+				x, y = n.Apply(x, y)
+				gx, gy := mn.Apply(p[0], p[1])
+				if !near(gx, x) || !near(gy, y) {
+					t.Errorf("%v.Multiply(%v) maps %v to (%v, %v), want (%v, %v)", m, n, p, gx, gy, x, y)
+				}
+			}
+		}
+	}
+
+	// a quarter turn is counterclockwise on the page: y grows downwards
+	if x, y := RotateMatrix(90).Apply(1, 0); !near(x, 0) || !near(y, -1) {
+		t.Errorf("rotation by 90 degrees maps (1, 0) to (%v, %v)", x, y)
+	}
+	if x, y := TranslateMatrix(5, 0).Multiply(RotateMatrix(90)).Apply(1, 0); !near(x, 0) || !near(y, -6) {
+		t.Errorf("translation, then rotation maps (1, 0) to (%v, %v)", x, y)
+	}
+	if m := IdentityMatrix().Multiply(RotateMatrix(30)); m != RotateMatrix(30) {
+		t.Errorf("identity times rotation is %v", m)
+	}
+}
+
+func TestMatrixAround(t *testing.T) {
+	m := RotateMatrix(90).Around(10, 20)
+	if x, y := m.Apply(10, 20); !near(x, 10) || !near(y, 20) {
+		t.Errorf("center mapped to (%v, %v)", x, y)
+	}
+	if x, y := m.Apply(15, 20); !near(x, 10) || !near(y, 15) {
+		t.Errorf("(15, 20) mapped to (%v, %v), want (10, 15)", x, y)
+	}
+	if x, y := ScaleMatrix(2, 3).Around(1, 1).Apply(2, 2); !near(x, 3) || !near(y, 4) {
+		t.Errorf("scale around (1, 1) maps (2, 2) to (%v, %v), want (3, 4)", x, y)
+	}
+}
+
+// pdfMatrix is m conjugated by the page flip (x, y) -> (x, h-y): it maps the flipped point p to
+// the flipped m(p)
+func TestPdfMatrix(t *testing.T) {
+	const h = 841.89
+	convert := &Converter{unit: 1, pageHeight: h}
+	for _, m := range []Matrix{
+		TranslateMatrix(10, 20),
+		RotateMatrix(90).Around(100, 200),
+		ScaleMatrix(2, 3).Around(50, 60),
+		SkewMatrix(10, 20),
+	} {
+		pm := convert.pdfMatrix(m)
+		for _, p := range [][2]float64{{0, 0}, {100, 200}, {-30, 400}} {
+			x, y := m.Apply(p[0], p[1])
+			px, py := pm.Apply(p[0], h-p[1])
+			if !near(px, x) || !near(py, h-y) {
+				t.Errorf("%v in PDF space maps %v to (%v, %v), want (%v, %v)", m, p, px, py, x, h-y)
+			}
+		}
+	}
+}
@@ -38,8 +38,9 @@ func (scope *Scope) ReplaceMarign() {
 type Font struct {
 	Family string // Font family
 
-	// Font style, currently supported "", "U", "B", "I", where "B", "I" need to be defined by
-	// the font itself
+	// Font style, currently supported "", "B", "I", where "B", "I" need to be defined by
+	// the font itself, combined with the decorations "U" (underline), "S" (strikethrough) and
+	// "O" (overline)
 	Style string
 
 	Size int // Font size
@@ -0,0 +1,280 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/go-text/typesetting/di"
+	"github.com/go-text/typesetting/shaping"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/width"
+)
+
+// Vertical text ("CV" records) is written in a column from top to bottom. The column is shaped
+// top to bottom: wide and fullwidth characters (Han, Kana, Hangul, CJK punctuation and brackets)
+// stay upright, advance by the vertical metrics of the face (vhea/vmtx, the em height without
+// them) and take their vertical forms (GSUB vert). The other characters (Latin, digits, ...) are
+// written horizontally and rotated 90° clockwise, centered on the column. This approximates the
+// Vertical_Orientation property of UAX #50 by the East Asian Width of the characters.
+
+// verticalRun is a run of the text of a column, upright or sideways.
+type verticalRun struct {
+	text     string
+	sideways bool
+	glyphs   []shapedGlyph // upright glyphs: advance down, offset (y up) from the pen on the column center
+	length   float64       // length of the run in the column, in pt
+}
+
+// shapeVertical returns the runs of text shaped top to bottom in the face registered for key.
+func (s *textShaper) shapeVertical(key, text string) ([]verticalRun, bool) {
+	if s == nil {
+		return nil, false
+	}
+	face, ok := s.faces[key]
+	if !ok {
+		return nil, false
+	}
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return nil, true
+	}
+
+	var runs []verticalRun
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && isUpright(runes[end]) == isUpright(runes[start]) {
+			end++
+		}
+		run := verticalRun{text: string(runes[start:end]), sideways: !isUpright(runes[start])}
+		if !run.sideways {
+			input := shaping.Input{
+				Text:      runes,
+				RunStart:  start,
+				RunEnd:    end,
+				Direction: di.DirectionTTB,
+				Face:      face,
+				Size:      fixed.Int26_6(face.Upem()) << 6, // positions in font units
+			}
+			input.Direction.SetSideways(false)
+			for _, in := range s.segmenter.Split(input, singleFace{face: face}) {
+				for _, g := range s.shaper.Shape(in).Glyphs {
+					sg := shapedGlyph{
+						r:       glyphRune(face, g, runes[g.ClusterIndex]),
+						text:    clusterText(runes, g, run.glyphs),
+						cluster: g.ClusterIndex,
+						advance: -float64(g.YAdvance) / 64,
+						dx:      float64(g.XOffset) / 64,
+						dy:      float64(g.YOffset) / 64,
+					}
+					if g.GlyphID == 0 {
+						sg.advance = float64(face.Upem())
+					}
+					run.glyphs = append(run.glyphs, sg)
+				}
+			}
+		}
+		runs = append(runs, run)
+		start = end
+	}
+	return runs, true
+}
+
+// isUpright reports whether r stays upright in vertical text.
+func isUpright(r rune) bool {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return true
+	}
+	return false
+}
+
+// verticalText writes s in a column whose center and top are the current position. The position
+// ends at the bottom of the column.
+func (convert *Converter) verticalText(s string) error {
+	x, top := convert.pdf.GetX(), convert.pdf.GetY()
+	runs, err := convert.verticalRuns(s)
+	if err != nil {
+		return err
+	}
+
+	pen := top
+	for i, run := range runs {
+		if i > 0 {
+			pen += convert.charSpacing
+		}
+		if run.sideways {
+			if err := convert.sidewaysText(x, pen, run.text); err != nil {
+				return err
+			}
+			pen += run.length
+			continue
+		}
+
+		if err := convert.decorateVertical(x, pen, run.length, true); err != nil {
+			return err
+		}
+		y := pen
+		for j, g := range run.glyphs {
+			if j > 0 {
+				y += convert.charSpacing
+			}
+			convert.glyphText(convert.shapingKey(), g)
+			convert.pdf.SetX(x + g.dx)
+			convert.pdf.SetY(y - g.dy)
+			err := convert.styled(func() error {
+				return convert.textRun(string(g.r))
+			})
+			if err != nil {
+				return err
+			}
+			y += g.advance
+		}
+		if err := convert.decorateVertical(x, pen, run.length, false); err != nil {
+			return err
+		}
+		pen += run.length
+	}
+	convert.pdf.SetX(x)
+	convert.pdf.SetY(pen)
+	return nil
+}
+
+// verticalRuns returns the runs of s as written in a column with the current font and spacing,
+// in pt. Without a shaped face every character is upright, one em high.
+func (convert *Converter) verticalRuns(s string) ([]verticalRun, error) {
+	size := float64(convert.font.Size)
+	key := convert.shapingKey()
+	runs, ok := convert.shaper.shapeVertical(key, s)
+	if !ok {
+		ascender, _ := convert.GetFontMetricsWithStyle(convert.font.Family, convert.font.Style, size)
+		run := verticalRun{text: s}
+		for _, r := range s {
+			w, err := convert.pdf.MeasureTextWidth(string(r))
+			if err != nil {
+				return nil, err
+			}
+			run.glyphs = append(run.glyphs, shapedGlyph{r: r, advance: size, dx: -w / 2, dy: -ascender})
+		}
+		run.length = convert.glyphsLength(run.glyphs)
+		return []verticalRun{run}, nil
+	}
+
+	scale := size / convert.shaper.upem(key)
+	for i := range runs {
+		run := &runs[i]
+		if run.sideways {
+			w, err := convert.writtenWidth(run.text)
+			if err != nil {
+				return nil, err
+			}
+			run.length = w
+			continue
+		}
+		for j := range run.glyphs {
+			g := &run.glyphs[j]
+			g.advance, g.dx, g.dy = g.advance*scale, g.dx*scale, g.dy*scale
+		}
+		run.length = convert.glyphsLength(run.glyphs)
+	}
+	return runs, nil
+}
+
+// glyphsLength returns the length upright glyphs take in a column, with the character spacing
+// between them.
+func (convert *Converter) glyphsLength(glyphs []shapedGlyph) float64 {
+	length := 0.0
+	for i, g := range glyphs {
+		if i > 0 {
+			length += convert.charSpacing
+		}
+		length += g.advance
+	}
+	return length
+}
+
+// sidewaysText writes s rotated 90° clockwise down the column centered on x, starting at top.
+func (convert *Converter) sidewaysText(x, top float64, s string) error {
+	ascender, descender := convert.GetFontMetricsWithStyle(convert.font.Family, convert.font.Style, float64(convert.font.Size))
+	baseline := x - (ascender+descender)/2
+	// rotation around the start of the baseline
+	convert.pdf.Rotate(-90, baseline, top)
+	convert.sideways = &[2]float64{baseline, convert.pageHeight - top}
+	convert.pdf.SetX(baseline)
+	convert.pdf.SetY(top)
+	err := convert.text(s)
+	convert.sideways = nil
+	if err != nil {
+		return err
+	}
+	convert.pdf.RotateReset()
+	return nil
+}
+
+// verticalHeight returns the length text takes when written in a column with the current font
+// and spacing.
+func (convert *Converter) verticalHeight(text string) (float64, error) {
+	runs, err := convert.verticalRuns(text)
+	if err != nil {
+		return 0, err
+	}
+	height := 0.0
+	for i, run := range runs {
+		if i > 0 {
+			height += convert.charSpacing
+		}
+		height += run.length
+	}
+	return height, nil
+}
+
+// MeasureTextHeight returns the length of text written in a column ("CV") in the current font.
+func (convert *Converter) MeasureTextHeight(text string) float64 {
+	h, err := convert.verticalHeight(text)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// decorateVertical fills the highlight behind the upright text of the given length written
+// in the column centered on x from top, if there is one, or draws the decoration lines of the
+// current font along it: the underline on the right of the column, the overline on the left
+// and the strikethrough through its center.
+func (convert *Converter) decorateVertical(x, top, length float64, highlight bool) error {
+	if length <= 0 || !convert.renderMode.decorated() {
+		return nil
+	}
+	size := float64(convert.font.Size)
+	// rectangles by their left edge and width
+	var rects [][2]float64
+	fill := convert.textColor
+	if highlight {
+		if convert.highlight == nil {
+			return nil
+		}
+		fill = *convert.highlight
+		rects = append(rects, [2]float64{x - size/2, size})
+	} else {
+		decorations := fontDecorations(convert.font.Style)
+		if decorations == "" {
+			return nil
+		}
+		m := convert.decorationMetrics()
+		if strings.Contains(decorations, FontStyleUnderline) {
+			rects = append(rects, [2]float64{x + size/2, m.underlineThickness * size})
+		}
+		if strings.Contains(decorations, FontStyleStrike) {
+			rects = append(rects, [2]float64{x - m.strikeThickness*size/2, m.strikeThickness * size})
+		}
+		if strings.Contains(decorations, FontStyleOverline) {
+			rects = append(rects, [2]float64{x - size/2 - m.underlineThickness*size, m.underlineThickness * size})
+		}
+	}
+
+	convert.pdf.SaveGraphicsState()
+	convert.pdf.SetFillColor(fill[0], fill[1], fill[2])
+	for _, r := range rects {
+		convert.pdf.RectFromUpperLeftWithStyle(r[0], top, r[1], length, "F")
+	}
+	convert.pdf.RestoreGraphicsState()
+	return nil
+}
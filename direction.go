@@ -0,0 +1,33 @@
+package gopdf
+
+import (
+	"github.com/tiechui1994/gopdf/core"
+)
+
+// 双向文本: 组件按逻辑顺序折行, 段落方向随每一行写入 "TD", 由 core 按 UBA 对行内的文本重新排序.
+// 与行内容自身推断的方向(首个强方向字符)一致时不需要写入.
+
+// directionWriter 记录最近写入的段落方向, 只在方向变化时写入 "TD"
+type directionWriter struct {
+	pdf  *core.Report
+	last core.TextDirection
+}
+
+// line 在写入 text 之前设置其所在段落的方向 dir(已确定, 不是 DirectionAuto)
+func (w *directionWriter) line(dir core.TextDirection, text string) {
+	if dir == core.ResolveDirection(core.DirectionAuto, text) {
+		dir = core.DirectionAuto
+	}
+	if dir != w.last {
+		w.pdf.TextDirection(dir)
+		w.last = dir
+	}
+}
+
+// reset 恢复默认方向, 组件写完内容后调用
+func (w *directionWriter) reset() {
+	if w.last != core.DirectionAuto {
+		w.pdf.TextDirection(core.DirectionAuto)
+		w.last = core.DirectionAuto
+	}
+}
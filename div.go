@@ -22,18 +22,37 @@ type Div struct {
 	frameType int // 边框类型, 默认是无边框
 	contents  []string
 
+	directions []core.TextDirection // contents 中每一行所在段落的方向
+	lastLines  []bool               // contents 中每一行是否为段落的最后一行
+	richLines  []richLine           // 富文本内容的每一行(与 contents 对应), 纯文本内容时为 nil
+	direction  core.TextDirection   // 段落方向, 默认由每个段落的首个强方向字符决定
+
+	hyphenation    core.Hyphenation // 断词设置, 默认不断词
+	hyphenationErr error            // 断词的语言没有断词规则的错误, 由 GenerateAtomicCell 返回
+	tabStops       []TabStop        // 制表位, 按位置排序
+
 	width, height float64
 	lineHeight    float64
 	lineSpace     float64
 
-	fontColor string // 字体颜色
-	backColor string // 背景颜色
+	fontColor string     // 字体颜色
+	backColor string     // 背景颜色
+	highlight string     // 文本的背景高亮颜色
+	render    textRender // 文本的渲染方式与描边
 
 	margin core.Scope
 	border core.Scope
 
 	horizontalCentered bool // 水平居中
 	rightAlign         bool // 局右显示, 默认是居左显示
+	leftAlign          bool // 居左显示, 默认 RTL 段落居右显示
+	justify            bool // 两端对齐, 段落的最后一行除外
+
+	vertical     bool    // 竖排, contents 中每一项为一列
+	columnHeight float64 // 竖排时列的高度
+
+	overflow Overflow     // 内容超出时的处理方式, 默认写入全部内容
+	original *textMetrics // 缩小字号之前的字号, 行高与行间距, 未缩小时为 nil
 }
 
 func NewDiv(lineHeight, lineSpce float64, pdf *core.Report) *Div {
@@ -79,19 +98,32 @@ func NewDivWithWidth(width float64, lineHeight, lineSpce float64, pdf *core.Repo
 }
 
 func (div *Div) Copy(content string) *Div {
+	font, lineHeight, lineSpace := div.font, div.lineHeight, div.lineSpace
+	if div.original != nil {
+		font.Size, lineHeight, lineSpace = div.original.size, div.original.lineHeight, div.original.lineSpace
+	}
 	f := &Div{
-		pdf:        div.pdf,
-		frameType:  div.frameType,
-		width:      div.width,
-		lineHeight: div.lineHeight,
-		lineSpace:  div.lineSpace,
-		fontColor:  div.fontColor,
-		backColor:  div.backColor,
+		pdf:         div.pdf,
+		frameType:   div.frameType,
+		width:       div.width,
+		lineHeight:  lineHeight,
+		lineSpace:   lineSpace,
+		fontColor:   div.fontColor,
+		backColor:   div.backColor,
+		highlight:   div.highlight,
+		render:      div.render,
+		direction:   div.direction,
+		hyphenation: div.hyphenation,
+		tabStops:    div.tabStops,
+
+		vertical:     div.vertical,
+		columnHeight: div.columnHeight,
+		overflow:     div.overflow,
 	}
 
 	f.SetMarign(div.margin)
 	f.SetBorder(div.border)
-	f.SetFont(div.font)
+	f.SetFont(font)
 	f.SetContent(content)
 
 	return f
@@ -178,11 +210,74 @@ func (div *Div) GetWidth() (width float64) {
 func (div *Div) HorizontalCentered() *Div {
 	div.horizontalCentered = true
 	div.rightAlign = false
+	div.leftAlign = false
+	div.justify = false
 	return div
 }
 func (div *Div) RightAlign() *Div {
 	div.rightAlign = true
 	div.horizontalCentered = false
+	div.leftAlign = false
+	div.justify = false
+	return div
+}
+func (div *Div) LeftAlign() *Div {
+	div.leftAlign = true
+	div.horizontalCentered = false
+	div.rightAlign = false
+	div.justify = false
+	return div
+}
+
+// 两端对齐: 除段落的最后一行外, 每一行的剩余宽度分摊到词间或字间(中日文)
+func (div *Div) Justify() *Div {
+	div.justify = true
+	div.horizontalCentered = false
+	div.rightAlign = false
+	div.leftAlign = false
+	return div
+}
+
+// 设置段落方向, 须在 SetContent 之前调用. 未设置对齐方式时, RTL 段落居右显示
+func (div *Div) SetDirection(dir core.TextDirection) *Div {
+	div.direction = dir
+	return div
+}
+
+// 设置断词的语言及断词后两侧的最少字符数, 须在 SetContent 之前调用. 放不下的单词在断词点折行, 并在行尾添加连字符
+// 语言没有断词规则时不断词, 错误由 GenerateAtomicCell 返回
+func (div *Div) SetHyphenation(hyphenation core.Hyphenation) *Div {
+	div.hyphenationErr = nil
+	if hyphenation.Language != "" {
+		if _, err := core.GetHyphenator(hyphenation.Language); err != nil {
+			div.hyphenationErr = err
+			hyphenation = core.Hyphenation{}
+		}
+	}
+	div.hyphenation = hyphenation
+	return div
+}
+
+// 竖排: 段落从上向下折成高度为 columnHeight 的列, 列从右向左排列, 列排满宽度后换页; columnHeight 不大于 0 时
+// 为当前页剩余的高度. 须在 SetContent 之前调用. 竖排时 HorizontalCentered/RightAlign 使列的内容居中/居下,
+// 不支持 Justify. 富文本(SetRuns)不支持竖排, 按横排排版
+func (div *Div) SetVertical(columnHeight float64) *Div {
+	div.vertical = true
+	div.columnHeight = columnHeight
+	return div
+}
+
+// 设置制表位, 须在 SetContent 之前调用. 内容中的制表符将其后的文字移到下一个制表位, 未设置或超出时使用间隔为
+// 4 个空格宽度的默认制表位
+func (div *Div) SetTabStops(stops ...TabStop) *Div {
+	div.tabStops = sortTabStops(stops)
+	return div
+}
+
+// 设置内容超出固定的高度或最多的行数时的处理方式: 截断, 省略号, 缩小字号, 须在 SetContent 之前调用.
+// 竖排时不起作用
+func (div *Div) SetOverflow(overflow Overflow) *Div {
+	div.overflow = overflow
 	return div
 }
 
@@ -197,8 +292,32 @@ func (div *Div) SetBackColor(color string) *Div {
 	return div
 }
 
+// SetHighlight 设置文本的背景高亮颜色. 下划线/删除线/上划线由字体样式 "U"/"S"/"O" 设置
+func (div *Div) SetHighlight(color string) *Div {
+	util.CheckColor(color)
+	div.highlight = color
+	return div
+}
+
+// SetRenderMode 设置文本的渲染方式: 描边, 填充并描边, 不可见, 裁剪等, 参考 core.TextRenderMode.
+// 裁剪方式下各行合成一个裁剪区域, 在组件写完文本后恢复
+func (div *Div) SetRenderMode(mode core.TextRenderMode) *Div {
+	div.render.mode = mode
+	return div
+}
+
+// SetTextStroke 设置描边的宽度与颜色, 与字体颜色无关. width 为 0 时为字号的 3%, color 为空时为字体颜色
+func (div *Div) SetTextStroke(width float64, color string) *Div {
+	if color != "" {
+		util.CheckColor(color)
+	}
+	div.render.strokeWidth, div.render.strokeColor = width, color
+	return div
+}
+
 func (div *Div) SetFont(font core.Font) *Div {
 	div.font = font
+	div.original = nil
 	// 注册, 启动
 	div.pdf.Font(font.Family, font.Size, font.Style)
 	div.pdf.SetFontWithStyle(font.Family, font.Style, font.Size)
@@ -212,11 +331,39 @@ func (div *Div) SetFontWithColor(font core.Font, color string) *Div {
 }
 
 func (div *Div) SetContent(content string) *Div {
-	convertStr := strings.Replace(content, "\t", "    ", -1)
+	if div.overflow.Mode == OverflowVisible || div.vertical {
+		return div.setContent(content)
+	}
 
+	if div.original == nil {
+		div.original = &textMetrics{size: div.font.Size, lineHeight: div.lineHeight, lineSpace: div.lineSpace}
+	}
+	limit := div.overflow.fit(*div.original, div.border.Top+div.border.Bottom, func(m textMetrics) int {
+		div.font.Size, div.lineHeight, div.lineSpace = m.size, m.lineHeight, m.lineSpace
+		div.contents, div.directions, div.lastLines = nil, nil, nil
+		div.setContent(content)
+		return len(div.contents)
+	})
+	if limit >= 0 && len(div.contents) > limit {
+		div.contents = div.overflow.truncate(div.contents, limit, div.width, tabMeasure(div.pdf, div.tabStops))
+		div.directions = div.directions[:limit]
+		div.lastLines = div.lastLines[:limit]
+		if limit > 0 {
+			div.lastLines[limit-1] = true
+		}
+		div.resetHeight()
+	}
+	if height, ok := div.overflow.fixedHeight(); ok {
+		div.height = height
+	}
+	return div
+}
+
+func (div *Div) setContent(content string) *Div {
 	var (
-		blocks       = strings.Split(convertStr, "\n") // 分行
+		blocks       = strings.Split(content, "\n") // 分行
 		contentWidth = div.width
+		measure      = tabMeasure(div.pdf, div.tabStops)
 	)
 
 	// 必须检查字体
@@ -227,65 +374,107 @@ func (div *Div) SetContent(content string) *Div {
 	// 必须先进行注册, 才能设置
 	div.pdf.Font(div.font.Family, div.font.Size, div.font.Style)
 	div.pdf.SetFontWithStyle(div.font.Family, div.font.Style, div.font.Size)
+	div.richLines = nil
+	if div.vertical {
+		div.setColumns(strings.Split(strings.Replace(content, "\t", "    ", -1), "\n"))
+		return div
+	}
 	if len(blocks) == 1 {
-		if div.pdf.MeasureTextWidth(convertStr) < contentWidth {
-			div.contents = []string{convertStr}
+		if measure(content) < contentWidth {
+			div.contents = []string{content}
+			div.directions = []core.TextDirection{core.ResolveDirection(div.direction, content)}
+			div.lastLines = []bool{true}
 			div.height = math.Abs(div.border.Top) + math.Abs(div.border.Bottom) + div.lineHeight
 			return div
 		}
 	}
 
+	// 每个段落按断行机会(UAX #14, 避头尾)折行, 设置了断词时在单词内断开
 	for i := range blocks {
-		// 单独的一行
-		if div.pdf.MeasureTextWidth(convertStr) < contentWidth {
-			div.contents = append(div.contents, blocks[i])
-			continue
+		dir := core.ResolveDirection(div.direction, blocks[i])
+		lines := core.WrapTextWithHyphenation(blocks[i], contentWidth, measure, div.hyphenation)
+		for j, line := range lines {
+			div.contents = append(div.contents, line)
+			div.directions = append(div.directions, dir)
+			div.lastLines = append(div.lastLines, j == len(lines)-1)
 		}
+	}
 
-		var (
-			line []rune
-		)
-		// 单独的一行需要拆分
-		for _, r := range []rune(blocks[i]) {
-			line = append(line, r)
-			lineLength := div.pdf.MeasureTextWidth(string(line))
-			if lineLength >= contentWidth {
-				if lineLength-contentWidth > 2 {
-					div.contents = append(div.contents, string(line[0:len(line)-1]))
-					line = line[len(line)-1:]
-				} else {
-					div.contents = append(div.contents, string(line))
-					line = []rune{}
-				}
-			}
-		}
+	// 重新计算 div 的高度
+	length := float64(len(div.contents))
+	div.height = div.border.Top + div.lineHeight*length + div.lineSpace*(length-1)
 
-		// 剩余单独成行
-		if len(line) > 0 {
-			div.contents = append(div.contents, string(line))
+	return div
+}
+
+// 设置富文本内容: 字体, 颜色, 下划线, 删除线, 链接, 高亮各不相同的片段, 片段中未设置的字体属性与颜色使用 Div 的设置.
+// 折行跨越片段, 行高随行内最大的字体增加
+func (div *Div) SetRuns(runs []TextRun) *Div {
+	// 必须检查字体
+	if util.IsEmpty(div.font) {
+		panic("there no avliable font")
+	}
+	// 富文本不支持竖排, 按横排排版
+	div.vertical = false
+	checkRuns(runs)
+
+	runs = append([]TextRun(nil), runs...)
+	div.richLines, div.directions, div.lastLines = layoutRuns(div.pdf, runs, div.font, div.width, div.direction, div.hyphenation)
+	div.contents = make([]string, len(div.richLines))
+	for i, line := range div.richLines {
+		for _, segment := range line.segments {
+			div.contents[i] += segment.text
 		}
 	}
 
 	// 重新计算 div 的高度
 	length := float64(len(div.contents))
 	div.height = div.border.Top + div.lineHeight*length + div.lineSpace*(length-1)
+	for i := range div.richLines {
+		div.height += div.richLines[i].height()
+	}
 
 	return div
 }
 
+// setColumns 将段落折成竖排的列
+func (div *Div) setColumns(blocks []string) {
+	if div.columnHeight <= 0 {
+		_, y := div.pdf.GetXY()
+		_, pageEndY := div.pdf.GetPageEndXY()
+		div.columnHeight = pageEndY - y - div.margin.Top - div.border.Top
+	}
+
+	div.contents = wrapColumns(div.pdf, blocks, div.columnHeight)
+	div.directions = make([]core.TextDirection, len(div.contents))
+	div.lastLines = make([]bool, len(div.contents))
+	div.height = div.border.Top + div.columnHeight
+}
+
 // 自动分页
 func (div *Div) GenerateAtomicCell() error {
+	if div.hyphenationErr != nil {
+		return div.hyphenationErr
+	}
 	var (
 		sx, sy      = div.pdf.GetXY()
 		x, y        float64
 		border      core.Scope
 		_, pageEndY = div.pdf.GetPageEndXY()
+		dirs        = directionWriter{pdf: div.pdf}
 	)
 
 	if util.IsEmpty(div.font) {
 		panic("no font")
 	}
 
+	// 竖排的列在当前页放不下时, 从下一页开始
+	if startX, startY := div.pdf.GetPageStartXY(); div.vertical && sy > startY && sy+div.margin.Top+div.height > pageEndY {
+		div.pdf.AddNewPage(false)
+		div.pdf.SetXY(startX, startY)
+		return div.GenerateAtomicCell()
+	}
+
 	switch div.frameType {
 	case DIV_STRAIGHT:
 		div.pdf.LineType("straight", 0.01)
@@ -298,20 +487,29 @@ func (div *Div) GenerateAtomicCell() error {
 	div.drawLine(sx, sy)
 	div.pdf.Font(div.font.Family, div.font.Size, div.font.Style)
 	div.pdf.SetFontWithStyle(div.font.Family, div.font.Style, div.font.Size)
+	div.render.begin(div.pdf)
+	defer div.render.end(div.pdf)
+	if div.vertical {
+		return div.generateColumns(sx, sy)
+	}
 	border = div.border
 	for i := 0; i < len(div.contents); i++ {
+		div.border = border
+
 		// 水平居中, 只是对当前的行设置新的 Border
 		if div.horizontalCentered {
-			width := div.pdf.MeasureTextWidth(div.contents[i])
+			width := div.lineWidth(i)
 			if width < div.width {
 				left := (div.width - width) / 2
 				div.border = core.NewScope(left, border.Top, 0, border.Right)
 			}
 		}
 
-		// 水平居右, 只是对当前的行设置新的 Border
-		if div.rightAlign {
-			width := div.pdf.MeasureTextWidth(div.contents[i])
+		// 水平居右, 只是对当前的行设置新的 Border. RTL 段落默认居右
+		rtl := div.directions[i] == core.DirectionRTL
+		justify := div.justify && !div.lastLines[i]
+		if div.rightAlign || rtl && !div.leftAlign && !div.horizontalCentered && !justify {
+			width := div.lineWidth(i)
 			left := div.width - width
 			div.border = core.NewScope(left, border.Top, 0, border.Right)
 		}
@@ -319,13 +517,19 @@ func (div *Div) GenerateAtomicCell() error {
 		x, y = div.getContentPosition(sx, sy, i)
 
 		// 换页
-		if y+div.lineHeight > pageEndY {
+		if y+div.lineHeight+div.lineExtra(i) > pageEndY {
 			var newX, newY float64
 
 			div.margin = core.NewScope(div.margin.Left, 0, 0, 0)
 			div.border = core.NewScope(border.Left, div.lineHeight, border.Right, border.Bottom)
 			div.contents = div.contents[i:]
+			div.directions = div.directions[i:]
+			div.lastLines = div.lastLines[i:]
+			if div.richLines != nil {
+				div.richLines = div.richLines[i:]
+			}
 			div.resetHeight()
+			dirs.reset()
 
 			newX, newY = div.pdf.GetPageStartXY()
 
@@ -335,19 +539,83 @@ func (div *Div) GenerateAtomicCell() error {
 			return div.GenerateAtomicCell()
 		}
 
+		// 富文本: 各片段写在下移后的同一基线上
+		if div.richLines != nil {
+			line := div.richLines[i]
+			width := 0.0
+			if justify {
+				width = div.width
+			}
+			line.draw(div.pdf, x, y+line.above, width, div.fontColor, div.highlight)
+			continue
+		}
+
 		if !util.IsEmpty(div.fontColor) {
 			div.pdf.TextColor(util.RGB(div.fontColor))
 		}
+		if div.highlight != "" {
+			div.pdf.TextHighlight(util.RGB(div.highlight))
+		}
 		div.pdf.Font(div.font.Family, div.font.Size, div.font.Style) // 添加设置
-		div.pdf.Cell(x, y, div.contents[i])
+		dirs.line(div.directions[i], div.contents[i])
+		if strings.Contains(div.contents[i], "\t") {
+			tabCell(div.pdf, x, y, div.contents[i], div.tabStops)
+		} else if justify {
+			justifyCell(div.pdf, x, y, div.width, div.contents[i])
+		} else {
+			div.pdf.Cell(x, y, div.contents[i])
+		}
 		if !util.IsEmpty(div.fontColor) {
 			div.pdf.TextDefaultColor()
 		}
+		if div.highlight != "" {
+			div.pdf.TextDefaultHighlight()
+		}
+	}
+
+	dirs.reset()
+
+	if div.richLines != nil {
+		div.pdf.Font(div.font.Family, div.font.Size, div.font.Style)
+		div.pdf.SetFontWithStyle(div.font.Family, div.font.Style, div.font.Size)
 	}
 
 	x, _ = div.pdf.GetPageStartXY()
-	div.pdf.SetXY(x, y+div.lineHeight+div.margin.Bottom) // 定格最终的位置
+	div.pdf.SetXY(x, y+div.lineHeight+div.lineExtra(len(div.contents)-1)+div.margin.Bottom) // 定格最终的位置
+
+	return nil
+}
+
+// generateColumns 从右向左写入竖排的列, 列排满宽度后换页
+func (div *Div) generateColumns(sx, sy float64) error {
+	var (
+		count = columnCount(div.width, div.lineHeight, div.lineSpace)
+		right = sx + div.margin.Left + div.border.Left + div.width
+		top   = sy + div.margin.Top + div.border.Top
+	)
 
+	columns := div.contents
+	if len(columns) > count {
+		columns = columns[:count]
+	}
+	drawColumns(div.pdf, columns, right, top, div.columnHeight, div.lineHeight, div.lineSpace,
+		div.horizontalCentered, div.rightAlign, div.fontColor, div.highlight)
+
+	// 换页
+	if len(div.contents) > count {
+		div.margin = core.NewScope(div.margin.Left, 0, 0, 0)
+		div.contents = div.contents[count:]
+		div.directions = div.directions[count:]
+		div.lastLines = div.lastLines[count:]
+
+		newX, newY := div.pdf.GetPageStartXY()
+		div.pdf.AddNewPage(false)
+		div.pdf.SetXY(newX, newY)
+		return div.GenerateAtomicCell()
+	}
+
+	x, _ := div.pdf.GetPageStartXY()
+	div.pdf.SetXY(x, sy+div.margin.Top+div.height+div.margin.Bottom) // 定格最终的位置
 	return nil
 }
 
@@ -396,6 +664,25 @@ func (div *Div) resetHeight() {
 	}
 	length := float64(len(div.contents))
 	div.height = div.lineHeight*length + div.lineSpace*(length-1) + div.border.Top + div.border.Bottom
+	for i := range div.richLines {
+		div.height += div.richLines[i].height()
+	}
+}
+
+// lineWidth 返回第 index 行内容的宽度
+func (div *Div) lineWidth(index int) float64 {
+	if div.richLines != nil {
+		return div.richLines[index].width
+	}
+	return tabMeasure(div.pdf, div.tabStops)(div.contents[index])
+}
+
+// lineExtra 返回第 index 行因富文本中较大的字体而增加的行高, 纯文本为 0
+func (div *Div) lineExtra(index int) float64 {
+	if index < 0 || index >= len(div.richLines) {
+		return 0
+	}
+	return div.richLines[index].height()
 }
 
 func (div *Div) getContentPosition(sx, sy float64, index int) (x, y float64) {
@@ -403,6 +690,9 @@ func (div *Div) getContentPosition(sx, sy float64, index int) (x, y float64) {
 	y = sy + div.margin.Top + div.border.Top
 
 	y += float64(index) * (div.lineHeight + div.lineSpace)
+	for i := 0; i < index; i++ {
+		y += div.lineExtra(i)
+	}
 
 	return x, y
 }
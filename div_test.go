@@ -1,12 +1,117 @@
 package gopdf
 
 import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/tiechui1994/gopdf/core"
 )
 
+// runReport 在 A4 纵向报表的 Detail 中执行 executor, 返回生成的 PDF 与报表的全部记录
+func runReport(t *testing.T, executor func(report *core.Report)) ([]byte, []record) {
+	t.Helper()
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(executor, core.Detail)
+	pdf, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []record
+	for _, cell := range *r.GetAtomicCells() {
+		records = append(records, strings.Split(cell, "|"))
+	}
+	return pdf, records
+}
+
+// record 按 "|" 拆分的一条记录, record[0] 为操作
+type record []string
+
+// float 返回第 i 个字段的数值
+func (r record) float(t *testing.T, i int) float64 {
+	t.Helper()
+	if i >= len(r) {
+		t.Fatalf("record %q without field %d", strings.Join(r, "|"), i)
+	}
+	v, err := strconv.ParseFloat(r[i], 64)
+	if err != nil {
+		t.Fatalf("record %q: %v", strings.Join(r, "|"), err)
+	}
+	return v
+}
+
+// placedText 写入的文本 ("CL", "CR", "CV" 记录), 及其起点和书写方向在页面上的位置 (已应用嵌套的变换)
+type placedText struct {
+	op, text string
+	x, y     float64 // 起点
+	dx, dy   float64 // 书写方向的单位向量, 未变换时为 (1, 0)
+	depth    int     // 所在变换的层数
+	page     int     // 所在的页, 从 1 开始
+}
+
+// placedTexts 返回 records 中写入的全部文本
+func placedTexts(t *testing.T, records []record) []placedText {
+	t.Helper()
+	var texts []placedText
+	walkRecords(t, records, func(r record, m core.Matrix, depth, page int) {
+		switch r[0] {
+		case "CL", "CR", "CV":
+			x, y := m.Apply(r.float(t, 1), r.float(t, 2))
+			ex, ey := m.Apply(r.float(t, 1)+1, r.float(t, 2))
+			text := strings.Join(r[3:], "|")
+			if r[0] == "CR" {
+				text = strings.Join(r[4:], "|")
+			}
+			texts = append(texts, placedText{op: r[0], text: text, x: x, y: y, dx: ex - x, dy: ey - y, depth: depth, page: page})
+		}
+	})
+	return texts
+}
+
+// walkRecords 依次以记录, 记录所在的变换 (各层变换复合后的矩阵, 内层的变换先应用), 变换的层数和页调用 fn
+func walkRecords(t *testing.T, records []record, fn func(r record, m core.Matrix, depth, page int)) {
+	t.Helper()
+	var (
+		stack []core.Matrix
+		page  = 1
+	)
+	for _, r := range records {
+		switch r[0] {
+		case "NP":
+			stack = nil
+			page++
+		case "TP":
+			m := core.Matrix{A: r.float(t, 1), B: r.float(t, 2), C: r.float(t, 3), D: r.float(t, 4), E: r.float(t, 5), F: r.float(t, 6)}
+			if len(stack) > 0 {
+				m = m.Multiply(stack[len(stack)-1])
+			}
+			stack = append(stack, m)
+		case "TK", "TX":
+			stack = append(stack, top(stack))
+		case "TQ":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			fn(r, top(stack), len(stack), page)
+		}
+	}
+}
+
+func top(stack []core.Matrix) core.Matrix {
+	if len(stack) == 0 {
+		return core.IdentityMatrix()
+	}
+	return stack[len(stack)-1]
+}
+
 func DivReport() {
 	r := core.CreateReport()
 	if err := r.SetPage("A4", "P"); err != nil {
@@ -152,3 +257,1080 @@ func TestDivReport(t *testing.T) {
 func TestComplexDivReport(t *testing.T) {
 	ComplexDivReport()
 }
+
+func TestDivFontFamilyStyle(t *testing.T) {
+	r := core.CreateReport()
+	faces := core.DefaultFontMaps()
+	err := r.AddFontFamily(core.FontFamily{Name: "sans-family", Regular: faces[0], Bold: faces[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	if !r.HasFontStyle("sans-family", "B") || r.HasFontStyle("sans-family", "I") {
+		t.Fatal("unexpected faces for sans-family")
+	}
+
+	r.RegisterExecutor(func(report *core.Report) {
+		div := NewDivWithWidth(300, 12, 1, report)
+		div.SetFont(core.Font{Family: "sans-family", Style: "BI", Size: 10})
+		div.SetContent("bold italic falls back to the bold face")
+		div.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fontCollection packs single-face TrueType files into a TrueType collection.
+func fontCollection(faces ...[]byte) []byte {
+	header := 12 + 4*len(faces)
+	out := make([]byte, header)
+	copy(out, "ttcf")
+	binary.BigEndian.PutUint32(out[4:], 0x00010000)
+	binary.BigEndian.PutUint32(out[8:], uint32(len(faces)))
+	for i, face := range faces {
+		base := len(out)
+		binary.BigEndian.PutUint32(out[12+4*i:], uint32(base))
+		face = append([]byte(nil), face...)
+		numTables := int(binary.BigEndian.Uint16(face[4:]))
+		for j := 0; j < numTables; j++ {
+			record := face[12+16*j:]
+			binary.BigEndian.PutUint32(record[8:], binary.BigEndian.Uint32(record[8:])+uint32(base))
+		}
+		out = append(out, face...)
+	}
+	return out
+}
+
+// FontSansBold 是 FontSans 粗体的别名, 粗体的字体文件只嵌入一次
+func TestDivFontSansBoldAlias(t *testing.T) {
+	for _, face := range core.DefaultFontMaps() {
+		if face.FontName != core.FontSans {
+			t.Errorf("default font %q registered besides the %q family", face.FontName, core.FontSans)
+		}
+	}
+
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	if !r.HasFontStyle(core.FontSansBold, "") {
+		t.Errorf("%q not resolved to the bold face of %q", core.FontSansBold, core.FontSans)
+	}
+	var widths [2]float64
+	r.RegisterExecutor(func(report *core.Report) {
+		report.SetFont(core.FontSansBold, 10)
+		widths[0] = report.MeasureTextWidth("bold text")
+		report.Cell(50, 50, "bold text")
+		report.SetFontWithStyle(core.FontSans, "B", 10)
+		widths[1] = report.MeasureTextWidth("bold text")
+		report.Cell(50, 70, "bold text")
+		if font := report.GetCurrentFont(); font.Family != core.FontSans || font.Style != "B" {
+			t.Errorf("current font %+v", font)
+		}
+	}, core.Detail)
+	data, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if widths[0] != widths[1] {
+		t.Errorf("alias measures %v, the bold face %v", widths[0], widths[1])
+	}
+	if n := strings.Count(string(data), "/FontFile2"); n != 2 {
+		t.Errorf("%d embedded font files, want the regular and the bold face once", n)
+	}
+}
+
+func TestDivFontCollection(t *testing.T) {
+	faces := core.DefaultFontMaps()
+	ttc := fontCollection(faces[0].Data, faces[1].Data)
+
+	r := core.CreateReport()
+	err := r.AddFontFamily(core.FontFamily{
+		Name:    "sans-collection",
+		Regular: &core.FontMap{Data: ttc},
+		Bold:    &core.FontMap{Data: ttc, Index: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	r.RegisterExecutor(func(report *core.Report) {
+		div := NewDivWithWidth(300, 12, 1, report)
+		div.SetFont(core.Font{Family: "sans-collection", Style: "B", Size: 10})
+		div.SetContent("second face of a font collection")
+		div.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cffReport writes text with the CFF face of testdata/CFFTest.otf, registering it also under
+// the unused family "cff-unused" when unused is set.
+func cffReport(t *testing.T, unused bool) (width float64, pdf []byte) {
+	data, err := ioutil.ReadFile("testdata/CFFTest.otf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := core.CreateReport()
+	if err := r.AddFontFamily(core.FontFamily{Name: "cff", Regular: &core.FontMap{Data: data}}); err != nil {
+		t.Fatal(err)
+	}
+	if unused {
+		if err := r.AddFontFamily(core.FontFamily{Name: "cff-unused", Regular: &core.FontMap{Data: data}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *core.Report) {
+		report.SetFont("cff", 10)
+		width = report.MeasureTextWidth("01Q")
+		report.Cell(50, 50, "01Q")
+	}, core.Detail)
+	if pdf, err = r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+	return width, pdf
+}
+
+func TestDivFontCFF(t *testing.T) {
+	width, pdf := cffReport(t, false)
+	// hmtx advances of '0', '1' and 'Q': 600, 400 and 1000 units of an em of 1000
+	if math.Abs(width-20) > 0.01 {
+		t.Errorf("width %v, want 20", width)
+	}
+	embedded := strings.Count(string(pdf), "/FontFile2")
+	if embedded == 0 {
+		t.Fatal("CFF face not embedded")
+	}
+
+	// a CFF face is converted and embedded only once it is used
+	if _, pdf := cffReport(t, true); strings.Count(string(pdf), "/FontFile2") != embedded {
+		t.Errorf("unused CFF face embedded")
+	}
+
+	r := core.CreateReport()
+	if err := r.AddFontFamily(core.FontFamily{
+		Name:    "cff-broken",
+		Regular: &core.FontMap{Data: append([]byte("OTTO"), make([]byte, 12)...)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPage("A4", "P"); err == nil {
+		t.Error("broken CFF face registered")
+	}
+}
+
+func TestDivFontAppleTrueType(t *testing.T) {
+	data := append([]byte(nil), core.DefaultFontMaps()[0].Data...)
+	copy(data, "true")
+
+	r := core.CreateReport()
+	if err := r.AddFontFamily(core.FontFamily{Name: "apple", Regular: &core.FontMap{Data: data}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	var widths [2]float64
+	r.RegisterExecutor(func(report *core.Report) {
+		report.SetFont("apple", 10)
+		widths[0] = report.MeasureTextWidth("apple text")
+		report.Cell(50, 50, "apple text")
+		report.SetFont(core.FontSans, 10)
+		widths[1] = report.MeasureTextWidth("apple text")
+	}, core.Detail)
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+	if widths[0] != widths[1] {
+		t.Errorf("'true' font measures %v, the same face tagged TrueType %v", widths[0], widths[1])
+	}
+}
+
+// 表格之外旋转的 cell 的高度为其自身的高度 (90/270 度时为最长的行的宽度), 内容排在 cell 之中
+func TestTextCellRotated(t *testing.T) {
+	f := core.Font{Family: core.FontSans, Size: 10}
+	tests := []struct {
+		degrees int
+		dx, dy  float64 // 书写方向
+	}{
+		{90, 0, -1},
+		{180, -1, 0},
+		{270, 0, 1},
+	}
+	for _, test := range tests {
+		var sx, sy, height, length float64
+		_, records := runReport(t, func(report *core.Report) {
+			sx, sy = report.GetXY()
+			cell := NewTextCell(40, 14, 0, report).SetFont(f).SetRotation(test.degrees).SetContent("rotated")
+			height, length = cell.GetHeight(), report.MeasureTextWidthWithFont("rotated", f)
+			cell.GenerateAtomicCell(height + 500)
+		})
+		texts := placedTexts(t, records)
+		if len(texts) != 1 || texts[0].depth != 1 {
+			t.Fatalf("rotation %d: texts %v", test.degrees, texts)
+		}
+		text := texts[0]
+		if math.Abs(text.dx-test.dx) > 1e-6 || math.Abs(text.dy-test.dy) > 1e-6 {
+			t.Errorf("rotation %d: written in direction (%.2f, %.2f)", test.degrees, text.dx, text.dy)
+		}
+		// 文本的起点与终点都在 cell 之中
+		for _, p := range [][2]float64{{text.x, text.y}, {text.x + text.dx*length, text.y + text.dy*length}} {
+			if p[0] < sx-0.01 || p[0] > sx+40.01 || p[1] < sy-0.01 || p[1] > sy+height+0.01 {
+				t.Errorf("rotation %d: text at (%.2f, %.2f), outside the cell at (%.2f, %.2f) of 40x%.2f",
+					test.degrees, p[0], p[1], sx, sy, height)
+			}
+		}
+	}
+}
+
+func TestDivDirection(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	var startX float64
+	r.RegisterExecutor(func(report *core.Report) {
+		startX, _ = report.GetXY()
+		div := NewDivWithWidth(300, 12, 1, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 10})
+		div.SetDirection(core.DirectionRTL)
+		div.SetContent("abc (1) def\nxyz")
+		div.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+
+	var directions []string
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		switch elements[0] {
+		case "TD":
+			directions = append(directions, elements[1])
+		case "CL":
+			x, err := strconv.ParseFloat(elements[1], 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if x < startX+200 {
+				t.Errorf("rtl line %q is not right aligned: x=%v", elements[3], x)
+			}
+		}
+	}
+	if strings.Join(directions, ",") != "rtl,auto" {
+		t.Errorf("unexpected direction records %v", directions)
+	}
+}
+
+func TestDivLineBreaking(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	r.RegisterExecutor(func(report *core.Report) {
+		div := NewDivWithWidth(80, 12, 1, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 10})
+		div.SetContent("The quick brown fox jumps over the lazy dog.\n「中文标点」，不能出现在行首。句号。逗号，顿号、问号？")
+		div.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+
+	words := map[string]bool{}
+	for _, word := range strings.Fields("The quick brown fox jumps over the lazy dog.") {
+		words[word] = true
+	}
+	var lines int
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		if elements[0] != "CL" {
+			continue
+		}
+		lines++
+		text := elements[3]
+		if runes := []rune(text); len(runes) > 0 && strings.ContainsRune("，。、？」", runes[0]) {
+			t.Errorf("line %q starts with closing punctuation", text)
+		}
+		if strings.HasSuffix(text, "「") {
+			t.Errorf("line %q ends with opening punctuation", text)
+		}
+		for _, word := range strings.Fields(text) {
+			if []rune(word)[0] < 0x80 && !words[word] {
+				t.Errorf("word broken in line %q", text)
+			}
+		}
+	}
+	if lines < 4 {
+		t.Errorf("expected the content to wrap, got %d lines", lines)
+	}
+}
+
+func TestDivHyphenation(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	const content = "Donaudampfschifffahrtsgesellschaft Bundesverfassungsgericht Silbentrennung"
+	r.RegisterExecutor(func(report *core.Report) {
+		div := NewDivWithWidth(90, 12, 1, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 10})
+		div.SetHyphenation(core.Hyphenation{Language: "de-DE"})
+		div.SetContent(content)
+		div.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+
+	var text string
+	var hyphenated int
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		if elements[0] != "CL" {
+			continue
+		}
+		line := elements[3]
+		if strings.HasSuffix(line, "-") {
+			hyphenated++
+			text += strings.TrimSuffix(line, "-")
+		} else {
+			text += line + " "
+		}
+	}
+	if hyphenated == 0 {
+		t.Error("expected hyphenated lines")
+	}
+	if strings.TrimSpace(text) != content {
+		t.Errorf("hyphenated lines do not rebuild the content: %q", text)
+	}
+}
+
+func TestHyphenationUnknownLanguage(t *testing.T) {
+	font := core.Font{Family: core.FontSans, Size: 10}
+	var divErr, cellErr, validErr error
+	_, records := runReport(t, func(report *core.Report) {
+		// 没有断词规则的语言不 panic, 错误由 GenerateAtomicCell 返回
+		div := NewDivWithWidth(90, 12, 1, report)
+		div.SetFont(font)
+		div.SetHyphenation(core.Hyphenation{Language: "xx-XX"})
+		div.SetContent("unknown")
+		divErr = div.GenerateAtomicCell()
+
+		cell := NewTextCell(90, 12, 1, report).SetFont(font)
+		cell.SetHyphenation(core.Hyphenation{Language: "xx-XX"})
+		cell.SetContent("unknown")
+		_, _, cellErr = cell.GenerateAtomicCell(1000)
+
+		// 之后设置有断词规则的语言时不再返回错误
+		div = NewDivWithWidth(90, 12, 1, report)
+		div.SetFont(font)
+		div.SetHyphenation(core.Hyphenation{Language: "xx-XX"}).SetHyphenation(core.Hyphenation{Language: "de-DE"})
+		div.SetContent("valid")
+		validErr = div.GenerateAtomicCell()
+	})
+
+	if divErr == nil || cellErr == nil {
+		t.Errorf("div error %v, cell error %v, want errors for the unknown language", divErr, cellErr)
+	}
+	if validErr != nil {
+		t.Errorf("div with a valid language after an unknown one: %v", validErr)
+	}
+	var texts []string
+	for _, text := range placedTexts(t, records) {
+		texts = append(texts, text.text)
+	}
+	if len(texts) != 1 || texts[0] != "valid" {
+		t.Errorf("texts %q, want only the div with a valid language", texts)
+	}
+}
+
+// 富文本的前缀增量测量: 折行与逐行完整测量一致, 原文中的 '-' 处断行时不再追加连字符
+func TestDivHyphenationRuns(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	const width = 60
+	runs := []TextRun{
+		{Text: "ein well-known "},
+		{Text: "Donaudampfschifffahrts", Font: core.Font{Style: "B"}},
+		{Text: "gesellschaft Silbentrennung"},
+	}
+	base := core.Font{Family: core.FontSans, Size: 10}
+	r.RegisterExecutor(func(report *core.Report) {
+		lines, _, _ := layoutRuns(report, runs, base, width, core.DirectionLTR, core.Hyphenation{Language: "de-DE"})
+
+		var text string
+		for i, line := range lines {
+			var s string
+			full := 0.0
+			for _, segment := range line.segments {
+				s += segment.text
+				full += report.MeasureTextWidthWithFont(segment.text, segment.font)
+			}
+			if line.width > width+0.01 || math.Abs(line.width-full) > 0.01 {
+				t.Errorf("line %d %q: width %.2f, measured %.2f, limit %d", i, s, line.width, full, width)
+			}
+			if strings.HasSuffix(s, "--") {
+				t.Errorf("line %d %q: hyphen appended after a hyphen", i, s)
+			}
+			if strings.HasSuffix(s, "-") && !strings.HasSuffix(s, "well-") {
+				s = strings.TrimSuffix(s, "-")
+			} else if i < len(lines)-1 && !strings.HasSuffix(s, "-") {
+				s += " "
+			}
+			text += s
+		}
+		if want := "ein well-known Donaudampfschifffahrtsgesellschaft Silbentrennung"; text != want {
+			t.Errorf("lines rebuild %q, want %q", text, want)
+		}
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDivJustify(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	const width = 160
+	r.RegisterExecutor(func(report *core.Report) {
+		div := NewDivWithWidth(width, 12, 1, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 10})
+		div.Justify()
+		div.SetContent("The quick brown fox jumps over the lazy dog. The quick brown fox jumps over the lazy dog.\n" +
+			"中文段落两端对齐时，剩余的宽度分摊到每两个字之间，段落的最后一行不对齐。")
+		div.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+	r.SetFontWithStyle(core.FontSans, "", 10)
+
+	var (
+		word, char float64
+		lines      []string
+		justified  []bool
+	)
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		switch elements[0] {
+		case "WS", "CS":
+			v, err := strconv.ParseFloat(elements[1], 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elements[0] == "WS" {
+				word = v
+			} else {
+				char = v
+			}
+		case "CL":
+			line := elements[3]
+			lines = append(lines, line)
+			justified = append(justified, word != 0 || char != 0)
+			if word == 0 && char == 0 {
+				continue
+			}
+			trimmed := strings.Trim(line, " ")
+			w := r.MeasureTextWidth(line) + word*float64(strings.Count(trimmed, " ")) +
+				char*float64(len([]rune(trimmed))-1)
+			if math.Abs(w-width) > 0.1 {
+				t.Errorf("justified line %q is %v wide, want %v", line, w, width)
+			}
+		}
+	}
+
+	if len(lines) < 4 {
+		t.Fatalf("expected both paragraphs to wrap, got %q", lines)
+	}
+	for i, line := range lines {
+		last := i == len(lines)-1 || strings.HasSuffix(line, "dog.") && strings.HasPrefix(lines[i+1], "中")
+		if justified[i] == last {
+			t.Errorf("line %q: justified=%v, last line of paragraph=%v", line, justified[i], last)
+		}
+	}
+}
+
+func TestDivRuns(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	r.RegisterExecutor(func(report *core.Report) {
+		div := NewDivWithWidth(150, 12, 1, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 10})
+		div.SetRuns([]TextRun{
+			{Text: "A sentence with one "},
+			{Text: "bold", Font: core.Font{Style: "B"}},
+			{Text: " word and a red number "},
+			{Text: "42", Color: "255,0,0", Font: core.Font{Size: 20}, Underline: true},
+			{Text: " in it, a link to ", Highlight: "255,255,0"},
+			{Text: "the site", Link: "https://example.com"},
+			{Text: ".\nSecond paragraph."},
+		})
+		div.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		font  string
+		ys    []float64              // 每一行的基线
+		texts = map[string]float64{} // 片段 => 基线
+		fonts = map[string]string{}
+		link  bool
+	)
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		switch elements[0] {
+		case "F":
+			font = cell
+		case "CL", "EL":
+			y, err := strconv.ParseFloat(elements[2], 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := elements[len(elements)-1]
+			if elements[0] == "EL" {
+				text = elements[5]
+				link = elements[6] == "https://example.com"
+			}
+			if len(ys) == 0 || ys[len(ys)-1] != y {
+				ys = append(ys, y)
+			}
+			texts[text] = y
+			fonts[text] = font
+		}
+	}
+
+	if !link {
+		t.Error("the link run is not written as an external link")
+	}
+	if fonts["bold"] != "F|"+core.FontSans+"|B|10" || fonts["42"] != "F|"+core.FontSans+"|U|20" {
+		t.Errorf("runs written with fonts %q and %q", fonts["bold"], fonts["42"])
+	}
+	if len(ys) < 4 {
+		t.Fatalf("expected the runs to wrap into at least 4 lines, got baselines %v", ys)
+	}
+	if texts["bold"] != ys[0] {
+		t.Errorf("run %q is not on the baseline of its line", "bold")
+	}
+	// 含 20 号字的行比其他行高
+	line := 0
+	for i, y := range ys {
+		if y == texts["42"] {
+			line = i
+		}
+	}
+	if line == 0 || line == len(ys)-1 {
+		t.Fatalf("run %q is on line %d of %d", "42", line, len(ys))
+	}
+	plain := ys[len(ys)-1] - ys[len(ys)-2]
+	if ys[line]-ys[line-1] <= plain || ys[line+1]-ys[line] <= plain {
+		t.Errorf("the line with the 20pt run is not taller: baselines %v", ys)
+	}
+}
+
+func TestDivRunsBidi(t *testing.T) {
+	// 每个片段以其左端的 x 排列, 按从左到右的顺序连接
+	visual := func(texts []placedText) string {
+		sort.SliceStable(texts, func(i, j int) bool { return texts[i].x < texts[j].x })
+		var line []string
+		for _, text := range texts {
+			line = append(line, text.text)
+		}
+		return strings.Join(line, "|")
+	}
+
+	tests := []struct {
+		dir  core.TextDirection
+		runs []TextRun
+		want string
+	}{
+		// 跨越片段的 LTR 短语在 RTL 段落中保持自左向右
+		{core.DirectionRTL, []TextRun{{Text: "שלום "}, {Text: "hello", Font: core.Font{Style: "B"}}, {Text: " world"}}, "hello| world|שלום "},
+		{core.DirectionRTL, []TextRun{{Text: "שלום "}, {Text: "hello"}, {Text: " world", Color: "255,0,0"}, {Text: " עולם"}}, " עולם|hello| world|שלום "},
+		// 跨越片段的希伯来文在 LTR 段落中自右向左
+		{core.DirectionLTR, []TextRun{{Text: "abc "}, {Text: "של", Font: core.Font{Style: "B"}}, {Text: "ום"}, {Text: " def"}}, "abc |ום|של| def"},
+		// 希伯来文之后的数字(W7, I1)
+		{core.DirectionLTR, []TextRun{{Text: "abc שלום "}, {Text: "123", Color: "255,0,0"}}, "abc |123|שלום "},
+	}
+	for _, test := range tests {
+		_, records := runReport(t, func(report *core.Report) {
+			div := NewDivWithWidth(300, 12, 1, report)
+			div.SetFont(core.Font{Family: core.FontSans, Size: 10})
+			div.SetDirection(test.dir)
+			div.SetRuns(test.runs)
+			div.GenerateAtomicCell()
+		})
+		if got := visual(placedTexts(t, records)); got != test.want {
+			var runs []string
+			for _, run := range test.runs {
+				runs = append(runs, run.Text)
+			}
+			t.Errorf("%s runs %q drawn as %q, want %q", test.dir, runs, got, test.want)
+		}
+	}
+}
+
+func TestDivDecoration(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	r.RegisterExecutor(func(report *core.Report) {
+		div := NewDivWithWidth(300, 12, 1, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 10, Style: "USO"})
+		div.SetFontColor("255,0,0")
+		div.SetHighlight("255,255,0")
+		div.SetContent("decorated text")
+		div.GenerateAtomicCell()
+	}, core.Detail)
+
+	data, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := pdfContent(data)
+	// 高亮在文本之前填充, 三条装饰线以字体颜色填充
+	highlight := strings.Index(content, "1.000 1.000 0.000 rg")
+	if highlight < 0 {
+		t.Fatal("highlight is not filled")
+	}
+	lines := content[highlight:]
+	lines = lines[strings.Index(lines, "ET"):]
+	if i := strings.Index(lines, "1.000 0.000 0.000 rg"); i < 0 {
+		t.Fatal("decoration lines are not filled in the text color")
+	} else {
+		lines = lines[i:]
+	}
+	if end := strings.Index(lines, "BT"); end >= 0 {
+		lines = lines[:end]
+	}
+	if n := strings.Count(lines, "re f"); n != 3 {
+		t.Errorf("got %d decoration lines, want 3", n)
+	}
+}
+
+func TestDivRunsScript(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+
+	var heights []float64
+	r.RegisterExecutor(func(report *core.Report) {
+		for _, runs := range [][]TextRun{
+			{{Text: "H2O"}},
+			{{Text: "H"}, {Text: "2", Script: core.ScriptSub}, {Text: "O"}},
+			{{Text: "m"}, {Text: "2", Script: core.ScriptSuper, BaselineShift: 6}},
+		} {
+			div := NewDivWithWidth(300, 12, 0, report)
+			div.SetFont(core.Font{Family: core.FontSans, Size: 10})
+			div.SetRuns(runs)
+			heights = append(heights, div.GetHeight())
+			div.GenerateAtomicCell()
+		}
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+
+	var shifts []float64
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		if elements[0] == "BS" && elements[1] != "0.0000" {
+			v, err := strconv.ParseFloat(elements[1], 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shifts = append(shifts, v)
+		}
+	}
+	if len(shifts) != 2 || shifts[0] >= 0 || shifts[1] <= 6 {
+		t.Errorf("got baseline shifts %v, want a subscript and a raised superscript", shifts)
+	}
+	if heights[2] <= heights[0] {
+		t.Errorf("the line with the raised superscript is not taller: heights %v", heights)
+	}
+}
+
+func TestDivVertical(t *testing.T) {
+	content := strings.Repeat("竖排的文字「引用」与 PDF 文本。", 3)
+	var (
+		sx      float64
+		lengths = map[string]float64{}
+	)
+	pdf, records := runReport(t, func(report *core.Report) {
+		sx, _ = report.GetXY()
+		div := NewDivWithWidth(60, 14, 2, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 12})
+		div.SetVertical(100)
+		div.SetContent(content)
+		report.SetFont(core.FontSans, 12)
+		for _, column := range div.contents {
+			lengths[column] = report.MeasureTextHeight(column)
+		}
+		div.GenerateAtomicCell()
+	})
+
+	// 每页 3 列, 从右向左排列, 列间隔为列宽与列间距之和; 列在 div 的宽度内, 自同一高度向下, 不长于列高
+	var (
+		columns []placedText
+		written string
+	)
+	for _, text := range placedTexts(t, records) {
+		if text.op != "CV" {
+			continue
+		}
+		written += text.text
+		if n := len(columns); n > 0 && columns[n-1].page != text.page {
+			columns = nil
+		}
+		if n := len(columns); n > 0 {
+			if last := columns[n-1]; math.Abs(last.x-text.x-16) > 0.01 || text.y != last.y {
+				t.Errorf("column at (%.2f, %.2f) follows the column at (%.2f, %.2f)", text.x, text.y, last.x, last.y)
+			}
+		}
+		if len(columns) == 3 {
+			t.Errorf("more than 3 columns on page %d", text.page)
+		}
+		if text.x < sx || text.x > sx+60 {
+			t.Errorf("column %q at x %.2f, outside the div [%.2f, %.2f]", text.text, text.x, sx, sx+60)
+		}
+		if length := lengths[text.text]; length == 0 || length > 100 {
+			t.Errorf("column %q is %.2f long, want at most 100", text.text, length)
+		}
+		columns = append(columns, text)
+	}
+	if len(columns) == 0 || columns[0].page < 2 {
+		t.Error("the columns are written on one page, want a page break")
+	}
+	if strings.Replace(written, " ", "", -1) != strings.Replace(content, " ", "", -1) {
+		t.Errorf("columns %q, want the content %q", written, content)
+	}
+
+	// 拉丁文顺时针旋转 90 度
+	if !strings.Contains(pdfContent(pdf), "0.00000 -1.00000 1.00000\n 0.00000 ") {
+		t.Error("latin text is not rotated")
+	}
+}
+
+func TestDivVerticalRuns(t *testing.T) {
+	_, records := runReport(t, func(report *core.Report) {
+		div := NewDivWithWidth(200, 14, 2, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 12})
+		div.SetVertical(100)
+		div.SetRuns([]TextRun{{Text: "富文本"}, {Text: " runs", Underline: true}})
+		div.GenerateAtomicCell()
+
+		cell := NewTextCell(200, 14, 2, report).SetFont(core.Font{Family: core.FontSans, Size: 12}).SetVertical()
+		cell.SetRuns([]TextRun{{Text: "单元格"}})
+		cell.GenerateAtomicCell(cell.GetHeight())
+	})
+
+	// 富文本不竖排, 按横排写入
+	var written string
+	for _, text := range placedTexts(t, records) {
+		if text.op == "CV" {
+			t.Errorf("rich text %q written in a column", text.text)
+		}
+		written += text.text
+	}
+	if written != "富文本 runs单元格" {
+		t.Errorf("got %q, want the runs written horizontally", written)
+	}
+}
+
+func TestDivTabStops(t *testing.T) {
+	var (
+		sx     float64
+		widths = map[string]float64{}
+	)
+	_, records := runReport(t, func(report *core.Report) {
+		sx, _ = report.GetXY()
+		div := NewDivWithWidth(300, 12, 2, report)
+		div.SetFont(core.Font{Family: core.FontSans, Size: 10})
+		div.SetTabStops(
+			TabStop{Position: 200, Align: TabRight, Leader: LeaderDot},
+			TabStop{Position: 100, Align: TabDecimal},
+		)
+		div.SetContent("Item\t1.5\t$12.00\nTotal\t123.25\t$7.00\nChapter\t\t7")
+		div.GenerateAtomicCell()
+		report.SetFont(core.FontSans, 10)
+		for _, text := range []string{"1", "123", "$12.00", "$7.00", "7", "Chapter"} {
+			widths[text] = report.MeasureTextWidth(text)
+		}
+	})
+
+	type placed struct{ x, y float64 }
+	cells := map[string]placed{}
+	var leader string
+	for _, text := range placedTexts(t, records) {
+		if text.op != "CL" {
+			continue
+		}
+		cells[text.text] = placed{text.x - sx, text.y}
+		if strings.Trim(text.text, ".") == "" && len(text.text) > 10 {
+			leader = text.text
+		}
+	}
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 0.01 }
+	// 小数点对齐 100, 金额在 200 结束, 各自在所在行的基线上
+	for _, row := range [][]string{{"Item", "1.5", "$12.00"}, {"Total", "123.25", "$7.00"}, {"Chapter", "7"}} {
+		for _, text := range row[1:] {
+			if cells[text].y != cells[row[0]].y {
+				t.Errorf("%s at baseline %.2f, want the baseline %.2f of %s", text, cells[text].y, cells[row[0]].y, row[0])
+			}
+		}
+	}
+	for _, number := range []string{"1.5", "123.25"} {
+		integer := number[:strings.Index(number, ".")]
+		if x, ok := cells[number]; !ok || !near(x.x+widths[integer], 100) {
+			t.Errorf("%s at %v, want its decimal point at 100", number, x.x)
+		}
+	}
+	for _, amount := range []string{"$12.00", "$7.00", "7"} {
+		if x, ok := cells[amount]; !ok || !near(x.x+widths[amount], 200) {
+			t.Errorf("%s at %v, want it to end at 200", amount, x.x)
+		}
+	}
+	// 点前导符填充 "Chapter" 与 "7" 之间的空白
+	if x, ok := cells[leader]; !ok || x.x < widths["Chapter"] || x.y != cells["7"].y {
+		t.Errorf("no dot leader after the chapter: %v", cells)
+	}
+}
+
+func TestDivOverflow(t *testing.T) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 8)
+	font := core.Font{Family: core.FontSans, Size: 10}
+
+	// overflow 返回 generate 写入的行, 写入之前的位置与字号为 10 时测量宽度的函数
+	overflow := func(generate func(report *core.Report)) (lines []placedText, sy float64, measure func(string) float64) {
+		_, records := runReport(t, func(report *core.Report) {
+			_, sy = report.GetXY()
+			generate(report)
+			report.SetFont(core.FontSans, 10)
+			measure = report.MeasureTextWidth
+		})
+		for _, text := range placedTexts(t, records) {
+			lines = append(lines, text)
+		}
+		return lines, sy, measure
+	}
+	// below 检查各行写入的位置在 sy 与 sy+height 之间
+	below := func(name string, lines []placedText, sy, height float64) {
+		for _, line := range lines {
+			if line.y < sy || line.y >= sy+height {
+				t.Errorf("%s: line %q at y %.2f, outside [%.2f, %.2f)", name, line.text, line.y, sy, sy+height)
+			}
+		}
+	}
+
+	lines, sy, _ := overflow(func(report *core.Report) {
+		NewDivWithWidth(120, 12, 2, report).SetFont(font).
+			SetOverflow(Overflow{Mode: OverflowClip, MaxLines: 2}).SetContent(text).GenerateAtomicCell()
+	})
+	if len(lines) != 2 || strings.HasSuffix(lines[1].text, "…") {
+		t.Errorf("clipped lines %v, want 2 lines without ellipsis", lines)
+	}
+	below("clip", lines, sy, 2*12+2)
+
+	// 高 40 可以容纳 (40+2)/(12+2) = 3 行
+	var height float64
+	lines, sy, measure := overflow(func(report *core.Report) {
+		div := NewDivWithWidth(120, 12, 2, report).SetFont(font).
+			SetOverflow(Overflow{Mode: OverflowEllipsis, Height: 40}).SetContent(text)
+		height = div.GetHeight()
+		div.GenerateAtomicCell()
+	})
+	if len(lines) != 3 || !strings.HasSuffix(lines[2].text, "…") || height != 40 {
+		t.Errorf("ellipsis lines %v, height %v", lines, height)
+	} else if w := measure(lines[2].text); w > 120 {
+		t.Errorf("ellipsis line is %v wide, wider than 120", w)
+	}
+	below("ellipsis", lines, sy, 40)
+
+	var shrink *Div
+	lines, sy, _ = overflow(func(report *core.Report) {
+		shrink = NewDivWithWidth(120, 12, 2, report).SetFont(font).
+			SetOverflow(Overflow{Mode: OverflowShrink, Height: 100, MinFontSize: 4}).SetContent(text)
+		shrink.GenerateAtomicCell()
+	})
+	var written string
+	for _, line := range lines {
+		written += line.text + " "
+	}
+	if shrink.font.Size >= 10 || shrink.font.Size < 4 || strings.Fields(written)[0] != "The" ||
+		len(strings.Fields(written)) != len(strings.Fields(text)) || strings.Contains(written, "…") {
+		t.Errorf("shrunk to size %d with lines %q", shrink.font.Size, written)
+	}
+	below("shrink", lines, sy, 100)
+	if copied := shrink.Copy(text); copied.font.Size != shrink.font.Size || copied.original.size != 10 {
+		t.Errorf("copy starts from size %d", copied.original.size)
+	}
+
+	lines, _, _ = overflow(func(report *core.Report) {
+		NewSpanWithWidth(120, 12, 2, report).SetFont(font).
+			SetOverflow(Overflow{Mode: OverflowEllipsis, MaxLines: 1}).SetContent(text).GenerateAtomicCell()
+	})
+	if len(lines) != 1 || !strings.HasSuffix(lines[0].text, "…") {
+		t.Errorf("span lines %v, want one line with ellipsis", lines)
+	}
+
+	// 缩小字号的 span 的高度为缩小后的行的高度, 不保留原来的字号折行的高度
+	var span *Span
+	overflow(func(report *core.Report) {
+		span = NewSpanWithWidth(120, 12, 2, report).SetFont(font).
+			SetOverflow(Overflow{Mode: OverflowShrink, MaxLines: 4, MinFontSize: 4}).SetContent(text)
+	})
+	scale := float64(span.font.Size) / 10
+	if n := float64(len(span.contents)); span.font.Size >= 10 || n > 4 ||
+		math.Abs(span.GetHeight()-(n*12*scale+(n-1)*2*scale)) > 0.01 {
+		t.Errorf("span of %v lines at size %d is %v high", n, span.font.Size, span.GetHeight())
+	}
+	// 较短的内容使 span 变矮
+	if span.SetContent("short"); len(span.contents) != 1 || span.font.Size != 10 || span.GetHeight() != 12 {
+		t.Errorf("span with %q at size %d is %v high, want one line of 12", span.contents, span.font.Size, span.GetHeight())
+	}
+}
+
+func TestDivRenderMode(t *testing.T) {
+	data, records := runReport(t, func(report *core.Report) {
+		font := core.Font{Family: core.FontSans, Size: 20}
+		NewDivWithWidth(300, 24, 1, report).SetFont(font).SetFontColor("0,0,255").
+			SetRenderMode(core.TextRenderFillStroke).SetTextStroke(2, "255,0,0").
+			SetContent("outline").GenerateAtomicCell()
+		NewTextCell(300, 24, 1, report).SetFont(font).
+			SetRenderMode(core.TextRenderStroke).SetContent("hollow").GenerateAtomicCell(24)
+
+		// 裁剪方式的组件: 各行合成一个裁剪区域, 写完后恢复; 没有文本时也恢复
+		NewDivWithWidth(100, 24, 1, report).SetFont(font).
+			SetRenderMode(core.TextRenderFillClip).SetContent("clipping text lines").GenerateAtomicCell()
+		NewTextCell(100, 24, 1, report).SetFont(font).
+			SetRenderMode(core.TextRenderClip).SetContent("").GenerateAtomicCell(24)
+
+		// 文本作为裁剪区域, 之后的矩形只在字形内可见
+		report.SetFontWithStyle(core.FontSans, "", 40)
+		report.TextRenderMode(core.TextRenderClip)
+		report.Cell(50, 400, "KNOCKOUT")
+		report.TextRenderMode(core.TextRenderFill)
+		report.BackgroundColor(50, 360, 300, 50, "0,128,0", "0000")
+		report.PopTransform()
+	})
+
+	// 各段文本写入时的渲染方式, 描边与变换的层数
+	type written struct {
+		text, mode, stroke string
+		depth              int
+	}
+	var (
+		texts  []written
+		mode   = "0"
+		stroke []string
+		depth  int
+	)
+	walkRecords(t, records, func(r record, m core.Matrix, d, page int) {
+		switch r[0] {
+		case "TR":
+			mode = r[1]
+		case "TS", "TW":
+			if len(r) == 1 {
+				stroke = nil
+			} else {
+				stroke = append(stroke, strings.Join(r, "|"))
+			}
+		case "CL":
+			texts = append(texts, written{text: r[3], mode: mode, stroke: strings.Join(stroke, " "), depth: d})
+		}
+		depth = d
+	})
+	want := []written{
+		{"outline", "2", "TS|255|0|0 TW|2.00", 0},
+		{"hollow", "1", "", 0},
+		{"clipping", "4", "", 1},
+		{"text lines", "4", "", 1},
+		{"", "7", "", 1},
+		{"KNOCKOUT", "7", "", 0},
+	}
+	if len(texts) != len(want) {
+		t.Fatalf("texts %+v, want %+v", texts, want)
+	}
+	for i := range want {
+		if texts[i] != want[i] {
+			t.Errorf("text %d: %+v, want %+v", i, texts[i], want[i])
+		}
+	}
+	if mode != "0" || len(stroke) != 0 || depth != 0 {
+		t.Errorf("render mode %q, stroke %q, depth %d after the report", mode, stroke, depth)
+	}
+
+	content := pdfContent(data)
+	// 描边颜色在同一文本之前设置
+	stroked := func(color, style string) bool {
+		i := strings.Index(content, style)
+		if i < 0 {
+			return false
+		}
+		j := strings.LastIndex(content[:i], color)
+		return j >= 0 && !strings.Contains(content[j:i], "BT")
+	}
+	// 描边颜色与宽度与字体颜色无关
+	if !stroked("1.000 0.000 0.000 RG", "2.000 w\n2 Tr") {
+		t.Error("fill and stroke text is not stroked in red with width 2")
+	}
+	// 默认描边为字体颜色(Div 恢复的默认颜色), 宽度为字号的 3%
+	if !stroked("0.004 0.004 0.004 RG", "0.600 w\n1 Tr") {
+		t.Error("stroked text is not stroked in the text color with the default width")
+	}
+
+	// Div 的两行在一个文本对象内裁剪, 空的 TextCell 不裁剪, KNOCKOUT 单独裁剪
+	if n := strings.Count(content, "7 Tr"); n != 2 {
+		t.Fatalf("%d clipping text writes, want 2", n)
+	}
+	clip := strings.Index(content, "7 Tr")
+	lines := content[clip : clip+strings.Index(content[clip:], "ET\n")]
+	if strings.Count(lines, " Tm\n") != 2 || strings.Count(lines, "TJ\n") != 2 {
+		t.Errorf("clipping lines of the div written as %q, want both lines in one text object", lines)
+	}
+	// 裁剪: 之后恢复 0 Tr, 矩形在裁剪区域内填充
+	knockout := strings.LastIndex(content, "7 Tr")
+	rest := content[knockout:]
+	reset := strings.Index(rest, "0 Tr")
+	fill := strings.Index(rest, "0.000 0.502 0.000 rg")
+	if reset < 0 || fill < reset || strings.Count(rest[:fill], "BT") != 1 {
+		t.Error("the rectangle is not filled after the clipping text")
+	}
+}
@@ -4,8 +4,10 @@ go 1.17
 
 require (
 	github.com/dlclark/regexp2 v1.2.0
+	github.com/go-text/typesetting v0.2.1
 	github.com/signintech/gopdf v0.36.0
-	golang.org/x/image v0.0.0-20200801110659-972c09e46d76
+	golang.org/x/image v0.3.0
+	golang.org/x/text v0.9.0
 )
 
 require (
@@ -1,28 +1,159 @@
 package gopdf
 
 import (
-	"os"
-	"path/filepath"
+	"bytes"
 	"fmt"
-	"time"
+	goimage "image"
+	"io"
+	"io/ioutil"
+	"math"
+	"path/filepath"
 
 	"github.com/tiechui1994/gopdf/core"
 )
 
+// ImageFit 图片在指定的宽高(框)内的缩放方式
+type ImageFit int
+
+const (
+	FitContain ImageFit = iota // 等比缩放, 完整地放入框内
+	FitCover                   // 等比缩放, 铺满框, 超出框的部分裁剪
+	FitFill                    // 拉伸铺满框, 不保持宽高比
+	FitNone                    // 原始大小, 超出框的部分裁剪
+)
+
+// HorizontalAlign 水平对齐方式
+type HorizontalAlign int
+
+const (
+	AlignLeft HorizontalAlign = iota
+	AlignCenter
+	AlignRight
+)
+
+// VerticalAlign 垂直对齐方式
+type VerticalAlign int
+
+const (
+	AlignTop VerticalAlign = iota
+	AlignMiddle
+	AlignBottom
+)
+
 type Image struct {
 	pdf           *core.Report
 	autobreak     bool
 	path          string
+	key           string // 图片数据在 Report 中注册的 key
 	width, height float64
 	margin        core.Scope
-	temppath      []string
+
+	naturalWidth, naturalHeight float64 // 图片数据按分辨率换算的大小(pt)
+	orientation                 int     // EXIF 方向(1-8), 绘制时自动旋转
+	rotation                    float64 // 逆时针旋转的角度
+	boxWidth, boxHeight         float64 // 构造时指定的宽高(框), 0 为不限
+
+	err         error // 图片无法读取(MissingImageError)或解码的错误, 由 GenerateAtomicCell 返回
+	placeholder bool  // 图片无法读取, 绘制占位框(MissingImagePlaceholder)
+	skip        bool  // 图片无法读取, 跳过(MissingImageSkip)
+
+	fitted bool // 设置了缩放方式, 图片占据框的大小
+	fit    ImageFit
+	hAlign HorizontalAlign
+	vAlign VerticalAlign
 }
 
 func NewImage(path string, pdf *core.Report) *Image {
 	return NewImageWithWidthAndHeight(path, 0, 0, pdf)
 }
 
+// 图片文件不存在或无法读取时按 Report 的 MissingImagePolicy 处理; 图片数据无法解码时 GenerateAtomicCell 返回错误
 func NewImageWithWidthAndHeight(path string, width, height float64, pdf *core.Report) *Image {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newMissingImage(path, width, height, err, pdf)
+	}
+
+	metadata := readImageMetadata(data)
+	data, err = EncodeImage(data, pdf.GetJPEGQuality())
+	if err == nil {
+		var image *Image
+		if image, err = newImageFromBytes(data, metadata, width, height, pdf); err == nil {
+			image.path = path
+			return image
+		}
+	}
+	return &Image{pdf: pdf, path: path, err: fmt.Errorf("decode image %s: %w", path, err)}
+}
+
+// placeholderSize 没有指定宽高时占位框的大小
+const placeholderSize = 100
+
+// newMissingImage 返回无法读取的图片文件 path 按 Report 的 MissingImagePolicy 处理的 Image
+func newMissingImage(path string, width, height float64, err error, pdf *core.Report) *Image {
+	image := &Image{pdf: pdf, path: path}
+	switch pdf.GetMissingImagePolicy() {
+	case core.MissingImageError:
+		image.err = fmt.Errorf("read image %s: %w", path, err)
+	case core.MissingImageSkip:
+		image.skip = true
+	default:
+		contentWidth, contentHeight := pdf.GetContentWidthAndHeight()
+		if width <= 0 && height <= 0 {
+			width, height = placeholderSize, placeholderSize
+		} else if width <= 0 {
+			width = height
+		} else if height <= 0 {
+			height = width
+		}
+		image.placeholder = true
+		image.width, image.height = math.Min(width, contentWidth), math.Min(height, contentHeight)
+	}
+	return image
+}
+
+// 从 reader 读取图片数据(JPEG, PNG, BMP, WEBP, TIFF), 图片数据保存在内存中, 不写入磁盘.
+// PNG 等无损图片保留透明通道, 参考 EncodeImage
+func NewImageFromReader(reader io.Reader, width, height float64, pdf *core.Report) (*Image, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	metadata := readImageMetadata(data)
+	data, err = EncodeImage(data, pdf.GetJPEGQuality())
+	if err != nil {
+		return nil, err
+	}
+	return newImageFromBytes(data, metadata, width, height, pdf)
+}
+
+// 内存中的 image.Image, 图片数据保存在内存中, 不写入磁盘
+func NewImageFromImage(img goimage.Image, width, height float64, pdf *core.Report) (*Image, error) {
+	data, err := EncodeImageFrom(img, pdf.GetJPEGQuality())
+	if err != nil {
+		return nil, err
+	}
+	return newImageFromBytes(data, imageMetadata{orientation: 1}, width, height, pdf)
+}
+
+// imageMetadata 原图片数据记录的 EXIF 方向与分辨率. 转换成嵌入 PDF 的图片数据之前读取
+type imageMetadata struct {
+	orientation int
+	dpiX, dpiY  float64 // 0 为没有记录, 使用 Report 默认的 DPI
+}
+
+func readImageMetadata(data []byte) imageMetadata {
+	dpiX, dpiY := ImageResolution(data)
+	return imageMetadata{orientation: ImageOrientation(data), dpiX: dpiX, dpiY: dpiY}
+}
+
+// data 为 EncodeImage 转换后的图片数据, metadata 为原图片数据记录的方向与分辨率
+func newImageFromBytes(data []byte, metadata imageMetadata, width, height float64, pdf *core.Report) (*Image, error) {
+	config, _, err := goimage.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
 	contentWidth, contentHeight := pdf.GetContentWidthAndHeight()
 	if width > contentWidth {
 		width = contentWidth
@@ -31,47 +162,116 @@ func NewImageWithWidthAndHeight(path string, width, height float64, pdf *core.Re
 		height = contentHeight
 	}
 
-	var temppath []string
-	if _, err := os.Stat(path); err != nil {
-		path = fmt.Sprintf("/tmp/%v.png", time.Now().Unix())
-		temppath = append(temppath, path)
-		DrawPNG(path)
+	dpiX, dpiY := metadata.dpiX, metadata.dpiY
+	if dpiX <= 0 || dpiY <= 0 {
+		dpiX, dpiY = pdf.GetImageDPI(), pdf.GetImageDPI()
 	}
 
-	dstPath := fmt.Sprintf("/tmp/%v.jpeg", time.Now().UnixNano())
-	srcPath, _ := filepath.Abs(path)
-	Convert2JPEG(srcPath, dstPath)
-	temppath = append(temppath, dstPath)
-
 	image := &Image{
-		pdf:      pdf,
-		path:     dstPath,
-		temppath: temppath,
+		pdf:           pdf,
+		key:           pdf.RegisterImage(data),
+		naturalWidth:  float64(config.Width) * 72 / dpiX,
+		naturalHeight: float64(config.Height) * 72 / dpiY,
+		orientation:   metadata.orientation,
+		boxWidth:      width,
+		boxHeight:     height,
+	}
+	image.layout()
+	return image, nil
+}
+
+// 设置图片在构造时指定的宽高(框)内的缩放方式. 设置之后图片占据框的大小(只指定了宽或高时, 为等比缩放后的
+// 大小), 图片在框内按 SetAlign 对齐. 未设置时图片等比缩放放入框内, 占据缩放后的大小
+func (image *Image) SetFit(fit ImageFit) *Image {
+	image.fitted = true
+	image.fit = fit
+	image.layout()
+	return image
+}
+
+// 设置图片在框内的对齐方式, 默认居左居上
+func (image *Image) SetAlign(horizontal HorizontalAlign, vertical VerticalAlign) *Image {
+	image.hAlign = horizontal
+	image.vAlign = vertical
+	return image
+}
+
+// 设置图片逆时针旋转的角度, 在 EXIF 方向之后旋转. 旋转 90 的奇数倍时图片的宽高互换后放入框内;
+// 其他角度以图片的中心旋转, 不改变占据的大小
+func (image *Image) SetRotation(degrees float64) *Image {
+	image.rotation = math.Mod(degrees, 360)
+	image.layout()
+	return image
+}
+
+// 图片的原始大小: 像素宽高按图片记录的分辨率(没有时为 Report 默认的 DPI)换算成 pt,
+// 按 EXIF 方向与旋转角度旋转之后的宽高
+func (image *Image) NaturalSize() (width, height float64) {
+	if image.swapped() {
+		return image.naturalHeight, image.naturalWidth
 	}
+	return image.naturalWidth, image.naturalHeight
+}
 
-	image.imageWidthHeight(image.path, width, height)
+// swapped 图片旋转之后宽高是否互换
+func (image *Image) swapped() bool {
+	quarter := math.Abs(math.Abs(math.Mod(image.rotation, 180))-90) < 1e-9
+	return (image.orientation >= 5) != quarter
+}
 
-	if dstPath != "" {
-		pdf.AddCallBack(image.delTempImage)
+// matrix 返回以图片的中心为原点, 按 EXIF 方向与旋转角度旋转的变换
+func (image *Image) matrix() core.Matrix {
+	var m core.Matrix
+	switch image.orientation {
+	case 2: // 水平翻转
+		m = core.ScaleMatrix(-1, 1)
+	case 3:
+		m = core.RotateMatrix(180)
+	case 4: // 垂直翻转
+		m = core.ScaleMatrix(1, -1)
+	case 5: // 沿左上-右下的对角线翻转
+		m = core.Matrix{B: 1, C: 1}
+	case 6: // 顺时针旋转 90 度
+		m = core.RotateMatrix(-90)
+	case 7: // 沿右上-左下的对角线翻转
+		m = core.Matrix{B: -1, C: -1}
+	case 8:
+		m = core.RotateMatrix(90)
+	default:
+		m = core.IdentityMatrix()
 	}
+	if image.rotation != 0 {
+		m = m.Multiply(core.RotateMatrix(image.rotation))
+	}
+	return m
+}
 
-	return image
+// layout 计算图片占据的宽高
+func (image *Image) layout() {
+	if image.key == "" {
+		return
+	}
+	w, h := image.NaturalSize()
+	image.scale(w, h, image.boxWidth, image.boxHeight)
+	if image.fitted && image.boxWidth > 0 && image.boxHeight > 0 {
+		image.width, image.height = image.boxWidth, image.boxHeight
+	}
 }
 
-func (image *Image) imageWidthHeight(path string, width, height float64) *Image {
-	w, h := GetImageWidthAndHeight(path)
+// 按图片的原始大小 w, h 缩放到 width, height 之内, 保持宽高比. 都为 0 时为原始大小
+func (image *Image) scale(w, h, width, height float64) *Image {
 	if width > 0 && height > 0 {
-		if float64(h)*width/float64(w) > height {
-			width = float64(w) * height / float64(h)
+		if h*width/w > height {
+			width = w * height / h
 		} else {
-			height = float64(h) * width / float64(w)
+			height = h * width / w
 		}
 	} else if width > 0 {
-		height = float64(h) * width / float64(w)
+		height = h * width / w
 	} else if height > 0 {
-		width = float64(w) * height / float64(h)
+		width = w * height / h
 	} else {
-		width, height = float64(w), float64(h)
+		width, height = w, h
 	}
 
 	image.width = width
@@ -99,6 +299,13 @@ func (image *Image) SetAutoBreak() {
 
 // 自动换行
 func (image *Image) GenerateAtomicCell() (pagebreak, over bool, err error) {
+	if image.err != nil {
+		return false, false, image.err
+	}
+	if image.skip {
+		return false, true, nil
+	}
+
 	var (
 		sx, sy = image.pdf.GetXY()
 	)
@@ -115,7 +322,7 @@ func (image *Image) GenerateAtomicCell() (pagebreak, over bool, err error) {
 	}
 
 draw:
-	image.pdf.Image(image.path, x, y, x+float64(image.width), y+float64(image.height))
+	image.draw(x, y)
 	if x+float64(image.width) >= pageEndX {
 		sx, _ = image.pdf.GetPageStartXY()
 		image.pdf.SetXY(sx, y+float64(image.height)+image.margin.Bottom)
@@ -132,14 +339,104 @@ draw:
 	return false, true, nil
 }
 
-func (image *Image) delTempImage(report *core.Report) {
-	if image.temppath == nil {
+// placement 返回框 (x, y, width, height) 内图片(旋转之后)按缩放方式与对齐方式放置的位置与大小
+func (image *Image) placement(x, y float64) (rx, ry, rw, rh float64) {
+	iw, ih := image.NaturalSize()
+	rw, rh = image.width, image.height
+	if image.fitted {
+		switch image.fit {
+		case FitContain:
+			scale := math.Min(image.width/iw, image.height/ih)
+			rw, rh = iw*scale, ih*scale
+		case FitCover:
+			scale := math.Max(image.width/iw, image.height/ih)
+			rw, rh = iw*scale, ih*scale
+		case FitNone:
+			rw, rh = iw, ih
+		}
+	}
+
+	rx, ry = x, y
+	switch image.hAlign {
+	case AlignCenter:
+		rx += (image.width - rw) / 2
+	case AlignRight:
+		rx += image.width - rw
+	}
+	switch image.vAlign {
+	case AlignMiddle:
+		ry += (image.height - rh) / 2
+	case AlignBottom:
+		ry += image.height - rh
+	}
+
+	return rx, ry, rw, rh
+}
+
+// draw 在框 (x, y, width, height) 内按缩放方式, 对齐方式与旋转绘制图片
+func (image *Image) draw(x, y float64) {
+	if image.placeholder {
+		image.drawPlaceholder(x, y)
 		return
 	}
 
-	for _, path := range image.temppath {
-		if _, err := os.Stat(path); err == nil || os.IsExist(err) {
-			os.Remove(path)
+	rx, ry, rw, rh := image.placement(x, y)
+
+	// 超出框的部分裁剪
+	clip := rw > image.width+1e-6 || rh > image.height+1e-6
+	if clip {
+		image.pdf.ClipRect(x, y, image.width, image.height)
+	}
+
+	// 图片数据按旋转之前的宽高绘制, 以中心旋转
+	dw, dh := rw, rh
+	if image.swapped() {
+		dw, dh = rh, rw
+	}
+	cx, cy := rx+rw/2, ry+rh/2
+	m := image.matrix()
+	transformed := m != core.IdentityMatrix()
+	if transformed {
+		image.pdf.PushTransform(m.Around(cx, cy))
+	}
+	image.pdf.ImageByKey(image.key, cx-dw/2, cy-dh/2, cx+dw/2, cy+dh/2)
+	if transformed {
+		image.pdf.PopTransform()
+	}
+	if clip {
+		image.pdf.PopTransform()
+	}
+}
+
+// drawPlaceholder 在 (x, y) 绘制占位框, 设置了字体时框内居中写文件名
+func (image *Image) drawPlaceholder(x, y float64) {
+	image.pdf.BackgroundColor(x, y, image.width, image.height, "242,242,242", "1111", "160,160,160")
+
+	font := image.pdf.GetCurrentFont()
+	if font.Family == "" || float64(font.Size) > image.height {
+		return
+	}
+	label := filepath.Base(image.path)
+	if image.path == "" {
+		label = "image"
+	}
+	// 宽度超出框时以省略号截断, 两侧各留 2pt
+	if image.pdf.MeasureTextWidth(label) > image.width-4 {
+		runes := []rune(label)
+		for len(runes) > 0 && image.pdf.MeasureTextWidth(string(runes)+overflowEllipsis) > image.width-4 {
+			runes = runes[:len(runes)-1]
 		}
+		if len(runes) == 0 {
+			return
+		}
+		label = string(runes) + overflowEllipsis
 	}
+	width := image.pdf.MeasureTextWidth(label)
+	// 基线使字形的上下沿在框内居中
+	ascender, descender := image.pdf.GetFontMetricsWithStyle(font.Family, font.Style, float64(font.Size))
+
+	red, green, blue := image.pdf.GetTextColor()
+	image.pdf.TextColor(128, 128, 128)
+	image.pdf.Cell(x+(image.width-width)/2, y+(image.height+ascender+descender)/2, label)
+	image.pdf.TextColor(red, green, blue)
 }
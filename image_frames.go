@@ -0,0 +1,247 @@
+package gopdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	goimage "image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"io/ioutil"
+
+	"github.com/tiechui1994/gopdf/core"
+)
+
+// 多帧图片: 多页 TIFF(扫描仪生成的多页文档, 支持 LZW, CCITT G3/G4, Deflate 等压缩)的每一页, GIF 动画的
+// 每一帧(按处置方式合成的完整画面). 其他图片(包括 WEBP, 解码器不支持 WEBP 动画)只有一帧.
+
+// ImageFrames 返回图片数据的各帧(页). TIFF 的各页共享 data, 读取时文件头中第一个 IFD 的偏移为该页的 IFD,
+// 保留该页的分辨率与方向; GIF 的每一帧编码成 PNG
+func ImageFrames(data []byte) ([]*io.SectionReader, error) {
+	_, pictureType, err := goimage.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	switch pictureType {
+	case TIFF:
+		pages, err := tiffPages(data)
+		if err != nil {
+			return nil, err
+		}
+		frames := make([]*io.SectionReader, len(pages))
+		for i, page := range pages {
+			frames[i] = page.reader()
+		}
+		return frames, nil
+	case "gif":
+		gifs, err := gifFrames(data)
+		if err != nil {
+			return nil, err
+		}
+		frames := make([]*io.SectionReader, len(gifs))
+		for i, frame := range gifs {
+			frames[i] = io.NewSectionReader(bytes.NewReader(frame), 0, int64(len(frame)))
+		}
+		return frames, nil
+	default:
+		return []*io.SectionReader{io.NewSectionReader(bytes.NewReader(data), 0, int64(len(data)))}, nil
+	}
+}
+
+// tiffPage TIFF 的一页(IFD). 读取的数据为整个文件, 只有文件头中第一个 IFD 的偏移改为该页的 IFD
+type tiffPage struct {
+	data  []byte
+	order binary.ByteOrder
+	ifd   uint32
+}
+
+func (page tiffPage) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off >= int64(len(page.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, page.data[off:])
+	// 文件头的 4-8 字节为第一个 IFD 的偏移
+	var header [4]byte
+	page.order.PutUint32(header[:], page.ifd)
+	for i, b := range header {
+		if at := int64(4+i) - off; at >= 0 && at < int64(n) {
+			p[at] = b
+		}
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (page tiffPage) reader() *io.SectionReader {
+	return io.NewSectionReader(page, 0, int64(len(page.data)))
+}
+
+// metadata 返回该页记录的方向与分辨率
+func (page tiffPage) metadata() imageMetadata {
+	exif := parseIFD(page.data, page.order, int(page.ifd))
+	if exif == nil {
+		return imageMetadata{orientation: 1}
+	}
+	dpiX, dpiY := exif.resolution()
+	return imageMetadata{orientation: exif.orientation(), dpiX: dpiX, dpiY: dpiY}
+}
+
+// tiffPages 返回 TIFF 的各页, 各页共享 data
+func tiffPages(data []byte) ([]tiffPage, error) {
+	order := tiffOrder(data)
+	if order == nil {
+		return nil, fmt.Errorf("invalid tiff data")
+	}
+
+	var (
+		pages   []tiffPage
+		visited = map[uint32]bool{}
+	)
+	for offset := order.Uint32(data[4:]); offset != 0; {
+		if visited[offset] || int(offset)+2 > len(data) {
+			break
+		}
+		visited[offset] = true
+		pages = append(pages, tiffPage{data: data, order: order, ifd: offset})
+
+		// 下一个 IFD 的偏移在各项之后
+		next := int(offset) + 2 + int(order.Uint16(data[offset:]))*12
+		if next+4 > len(data) {
+			break
+		}
+		offset = order.Uint32(data[next:])
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("tiff without pages")
+	}
+	return pages, nil
+}
+
+// gifFrames 返回 GIF 动画各帧合成的完整画面, 编码成 PNG
+func gifFrames(data []byte) ([][]byte, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := goimage.NewNRGBA(goimage.Rect(0, 0, g.Config.Width, g.Config.Height))
+	var frames [][]byte
+	for i, frame := range g.Image {
+		var previous *goimage.NRGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = goimage.NewNRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		encoded, err := EncodePNG(canvas)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, encoded)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), goimage.Transparent, goimage.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames, nil
+}
+
+// 从 reader 读取图片数据, 创建各帧(页)的 Image, 参考 ImageFrames. width, height 同 NewImageFromReader
+func NewImageFrames(reader io.Reader, width, height float64, pdf *core.Report) ([]*Image, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if _, pictureType, err := goimage.DecodeConfig(bytes.NewReader(data)); err == nil && pictureType == TIFF {
+		return newTIFFPages(data, width, height, pdf)
+	}
+	frames, err := ImageFrames(data)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]*Image, 0, len(frames))
+	for _, frame := range frames {
+		image, err := NewImageFromReader(frame, width, height, pdf)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// newTIFFPages 直接从共享的数据解码 TIFF 的各页, 创建各页的 Image
+func newTIFFPages(data []byte, width, height float64, pdf *core.Report) ([]*Image, error) {
+	pages, err := tiffPages(data)
+	if err != nil {
+		return nil, err
+	}
+	images := make([]*Image, 0, len(pages))
+	for _, page := range pages {
+		decoded, _, err := goimage.Decode(page.reader())
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := EncodeImageFrom(decoded, pdf.GetJPEGQuality())
+		if err != nil {
+			return nil, err
+		}
+		image, err := newImageFromBytes(encoded, page.metadata(), width, height, pdf)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// ImagePages 多帧图片每一帧一页, 在页面的内容区域内等比缩放, 居中放置. 当前页还没有写入内容(位置在页面的
+// 起点)时第一帧写在当前页, 否则新建一页; 之后的每一帧新建一页
+type ImagePages struct {
+	pdf    *core.Report
+	frames []*Image
+}
+
+func NewImagePages(reader io.Reader, pdf *core.Report) (*ImagePages, error) {
+	width, height := pdf.GetContentWidthAndHeight()
+	frames, err := NewImageFrames(reader, width, height, pdf)
+	if err != nil {
+		return nil, err
+	}
+	for _, frame := range frames {
+		frame.SetFit(FitContain).SetAlign(AlignCenter, AlignMiddle)
+	}
+	return &ImagePages{pdf: pdf, frames: frames}, nil
+}
+
+// 各帧的 Image
+func (pages *ImagePages) GetFrames() []*Image {
+	return pages.frames
+}
+
+func (pages *ImagePages) GenerateAtomicCell() (pagebreak, over bool, err error) {
+	startX, startY := pages.pdf.GetPageStartXY()
+	for i, frame := range pages.frames {
+		if x, y := pages.pdf.GetXY(); i > 0 || x != startX || y != startY {
+			pages.pdf.AddNewPage(false)
+		}
+		pages.pdf.SetXY(startX, startY)
+		if _, _, err := frame.GenerateAtomicCell(); err != nil {
+			return false, false, err
+		}
+	}
+	return false, true, nil
+}
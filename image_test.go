@@ -1,10 +1,25 @@
 package gopdf
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/tiechui1994/gopdf/core"
+	"golang.org/x/image/tiff"
 )
 
 func ComplexImageReport() {
@@ -55,3 +70,978 @@ func TestImage(t *testing.T) {
 	}
 	ComplexImageReport()
 }
+
+// placedImage 写入的图片 ("I" 记录), 及其左上角与右下角在页面上的位置 (已应用嵌套的变换)
+type placedImage struct {
+	path           string
+	x1, y1, x2, y2 float64
+	page           int
+}
+
+// placedImages 返回 records 中写入的全部图片
+func placedImages(t *testing.T, records []record) []placedImage {
+	t.Helper()
+	var images []placedImage
+	walkRecords(t, records, func(r record, m core.Matrix, depth, page int) {
+		if r[0] != "I" {
+			return
+		}
+		x1, y1 := m.Apply(r.float(t, 2), r.float(t, 3))
+		x2, y2 := m.Apply(r.float(t, 4), r.float(t, 5))
+		images = append(images, placedImage{path: r[1], x1: x1, y1: y1, x2: x2, y2: y2, page: page})
+	})
+	return images
+}
+
+func TestImageFromReader(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 200, A: 255}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	var sx, sy float64
+	data, records := runReport(t, func(report *core.Report) {
+		sx, sy = report.GetXY()
+		fromReader, err := NewImageFromReader(bytes.NewReader(buf.Bytes()), 80, 0, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromReader.GenerateAtomicCell()
+
+		blue := image.NewRGBA(image.Rect(0, 0, 40, 20))
+		draw.Draw(blue, blue.Bounds(), image.NewUniform(color.RGBA{B: 200, A: 255}), image.Point{}, draw.Src)
+		fromImage, err := NewImageFromImage(blue, 0, 0, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromImage.GenerateAtomicCell()
+	})
+
+	// 宽度 80 时高度按比例为 40; 第二张图片接在第一张的右侧, 保持原来的宽高比
+	images := placedImages(t, records)
+	if len(images) != 2 {
+		t.Fatalf("images %v, want 2", images)
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 0.01 }
+	first, second := images[0], images[1]
+	if !near(first.x1, sx) || !near(first.y1, sy) || !near(first.x2-first.x1, 80) || !near(first.y2-first.y1, 40) {
+		t.Errorf("image from the reader at %v, want 80x40 at (%v, %v)", first, sx, sy)
+	}
+	if !near(second.x1, first.x2) || !near(second.y1, sy) || second.x2 <= second.x1 ||
+		!near((second.x2-second.x1)/(second.y2-second.y1), 2) {
+		t.Errorf("image from the image at %v, want a 2:1 image next to %v", second, first)
+	}
+	for _, image := range images {
+		if !strings.HasPrefix(image.path, core.ImageKeyPrefix) {
+			t.Errorf("image %q does not reference the registry", image.path)
+		}
+	}
+	if n := bytes.Count(data, []byte("/Subtype /Image")); n != 2 {
+		t.Errorf("%d image objects, want 2", n)
+	}
+}
+
+func TestImageTransparency(t *testing.T) {
+	logo := image.NewNRGBA(image.Rect(0, 0, 30, 30))
+	draw.Draw(logo, image.Rect(5, 5, 25, 25), image.NewUniform(color.NRGBA{G: 160, A: 128}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, logo); err != nil {
+		t.Fatal(err)
+	}
+
+	generate := func(quality int) []byte {
+		data, records := runReport(t, func(report *core.Report) {
+			report.SetJPEGQuality(quality)
+			i, err := NewImageFromReader(bytes.NewReader(buf.Bytes()), 60, 60, report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i.GenerateAtomicCell()
+
+			opaque := image.NewRGBA(image.Rect(0, 0, 30, 30))
+			draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.RGBA{R: 90, G: 90, B: 90, A: 255}), image.Point{}, draw.Src)
+			i, err = NewImageFromImage(opaque, 60, 60, report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i.GenerateAtomicCell()
+		})
+		if images := placedImages(t, records); len(images) != 2 || images[0].path == images[1].path {
+			t.Errorf("images %v, want two different images", images)
+		}
+		return data
+	}
+
+	// 默认: 两张图片都使用 Flate 压缩, 透明的图片有 SMask
+	data := generate(0)
+	if n := bytes.Count(data, []byte("/SMask")); n != 1 {
+		t.Errorf("%d soft masks, want one for the transparent PNG", n)
+	}
+	if bytes.Contains(data, []byte("/DCTDecode")) {
+		t.Error("lossless images converted to JPEG")
+	}
+
+	// 设置 JPEG 质量: 不透明的图片重新编码成 JPEG, 透明的图片保留 SMask
+	data = generate(80)
+	if bytes.Count(data, []byte("/SMask")) != 1 || bytes.Count(data, []byte("/DCTDecode")) != 1 {
+		t.Error("want the opaque image as JPEG and the transparent one with a soft mask")
+	}
+}
+
+func TestImageDeduplication(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{R: 30, G: 120, B: 200, A: 255}), image.Point{}, draw.Src)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, logo); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []string
+	data, records := runReport(t, func(report *core.Report) {
+		for page := 0; page < 3; page++ {
+			if page > 0 {
+				report.AddNewPage(false)
+			}
+			for i := 0; i < 2; i++ {
+				img, err := NewImageFromReader(bytes.NewReader(buf.Bytes()), 20, 20, report)
+				if err != nil {
+					t.Fatal(err)
+				}
+				img.GenerateAtomicCell()
+				keys = append(keys, img.key)
+			}
+		}
+	})
+
+	// 每次注册的图片数据相同: 得到同一个 key, 写入 6 次, 每页 2 次, PDF 中只嵌入一个图片对象, 各页都引用它
+	for _, key := range keys {
+		if key != keys[0] {
+			t.Fatalf("image keys %q, want one key", keys)
+		}
+	}
+	images := placedImages(t, records)
+	perPage := map[int]int{}
+	for _, image := range images {
+		perPage[image.page]++
+	}
+	if len(images) != 6 || perPage[1] != 2 || perPage[2] != 2 || perPage[3] != 2 {
+		t.Errorf("images %v, want 2 on each of 3 pages", images)
+	}
+	if n := bytes.Count(data, []byte("/Subtype /Image")); n != 1 {
+		t.Errorf("%d image objects for one image placed 6 times, want 1", n)
+	}
+	if n := strings.Count(pdfContent(data), " Do"); n != 6 {
+		t.Errorf("the image is drawn %d times, want 6", n)
+	}
+}
+
+// jpegWithOrientation 返回带 EXIF 方向的 JPEG
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return withExif(buf.Bytes(), exifIFD(nil, exifEntry{0x0112, 3, 1, uint32(orientation) << 16}))
+}
+
+// exifEntry 大端的 IFD 中的一项, value 为值或值的偏移
+type exifEntry struct {
+	tag, typ     uint16
+	count, value uint32
+}
+
+// exifIFD 返回大端的 TIFF 头与只有 entries 的 IFD0, 之后是 data (偏移为 10 + 12*len(entries) + 4)
+func exifIFD(data []byte, entries ...exifEntry) []byte {
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, byte(len(entries) >> 8), byte(len(entries))}
+	for _, e := range entries {
+		var entry [12]byte
+		binary.BigEndian.PutUint16(entry[0:], e.tag)
+		binary.BigEndian.PutUint16(entry[2:], e.typ)
+		binary.BigEndian.PutUint32(entry[4:], e.count)
+		binary.BigEndian.PutUint32(entry[8:], e.value)
+		tiff = append(tiff, entry[:]...)
+	}
+	return append(append(tiff, 0, 0, 0, 0), data...)
+}
+
+// withExif 在 JPEG 的 SOI 之后插入 tiff 为 EXIF 数据的 APP1 段
+func withExif(data, tiff []byte) []byte {
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, byte((len(segment) + 2) >> 8), byte(len(segment) + 2)}
+	return append(append(append([]byte{}, data[:2]...), append(app1, segment...)...), data[2:]...)
+}
+
+func TestImageFit(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 200, G: 100, A: 255}), image.Point{}, draw.Src)
+	rotated := jpegWithOrientation(t, img, 6)
+	if o := ImageOrientation(rotated); o != 6 {
+		t.Fatalf("orientation %d, want 6", o)
+	}
+
+	type placement struct {
+		name  string
+		image *Image
+		x, y  float64
+	}
+	var placements []placement
+	_, records := runReport(t, func(report *core.Report) {
+		place := func(name string, i *Image) {
+			x, y := report.GetXY()
+			placements = append(placements, placement{name, i, x, y})
+			i.SetAutoBreak()
+			i.GenerateAtomicCell()
+			report.SetXY(x, y+80)
+		}
+		newImage := func() *Image {
+			i, err := NewImageFromImage(img, 60, 60, report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return i
+		}
+		place("contain", newImage().SetFit(FitContain).SetAlign(AlignCenter, AlignMiddle))
+		place("cover", newImage().SetFit(FitCover).SetAlign(AlignCenter, AlignTop))
+		place("fill", newImage().SetFit(FitFill))
+		place("rotate", newImage().SetFit(FitContain).SetRotation(90))
+
+		exif, err := NewImageFromReader(bytes.NewReader(rotated), 0, 0, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		place("exif", exif)
+	})
+
+	images := placedImages(t, records)
+	if len(images) != len(placements) {
+		t.Fatalf("%d images, want %d", len(images), len(placements))
+	}
+	var clips [][]float64
+	for _, r := range records {
+		if r[0] == "TK" {
+			clips = append(clips, []float64{r.float(t, 1), r.float(t, 2), r.float(t, 3), r.float(t, 4)})
+		}
+	}
+
+	// 图片在页面上 (旋转之后) 的矩形相对框的位置
+	want := map[string][4]float64{
+		"contain": {0, 15, 60, 45},
+		"cover":   {-30, 0, 90, 60}, // 超出框的部分被裁剪
+		"fill":    {0, 0, 60, 60},
+		"rotate":  {0, 0, 30, 60}, // 60x30 旋转之后为 30x60, 靠左
+		"exif":    {0, 0, 20, 40}, // 40x20 旋转之后为 20x40
+	}
+	for i, p := range placements {
+		image := images[i]
+		got := [4]float64{
+			math.Min(image.x1, image.x2) - p.x, math.Min(image.y1, image.y2) - p.y,
+			math.Max(image.x1, image.x2) - p.x, math.Max(image.y1, image.y2) - p.y,
+		}
+		for j := range got {
+			if math.Abs(got[j]-want[p.name][j]) > 0.01 {
+				t.Errorf("%s: image rect %v, want %v", p.name, got, want[p.name])
+				break
+			}
+		}
+	}
+	if w, h := placements[4].image.GetWidth(), placements[4].image.GetHeight(); w != 20 || h != 40 {
+		t.Errorf("EXIF rotated image is %vx%v, want 20x40", w, h)
+	}
+	cover := placements[1]
+	if len(clips) != 1 || clips[0][0] != cover.x || clips[0][1] != cover.y || clips[0][2] != 60 || clips[0][3] != 60 {
+		t.Errorf("clips %v, want the 60x60 box of the cover image at (%v, %v)", clips, cover.x, cover.y)
+	}
+}
+
+func TestImageMalformedJPEG(t *testing.T) {
+	// APP0 段的长度为 0, 小于长度字段自身
+	data := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x00, 0xFF, 0xD9}
+	if exif := readExif(data); exif != nil {
+		t.Errorf("EXIF %v read from a malformed JPEG", exif)
+	}
+	if o := ImageOrientation(data); o != 1 {
+		t.Errorf("orientation %d of a malformed JPEG, want 1", o)
+	}
+	// 段的长度超出数据
+	if segment := jpegSegment([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x40, 'E', 'x'}, 0xE1, "Exif\x00\x00"); segment != nil {
+		t.Errorf("segment %q read past the data", segment)
+	}
+	runReport(t, func(report *core.Report) {
+		if _, err := NewImageFromReader(bytes.NewReader(data), 0, 0, report); err == nil {
+			t.Error("malformed JPEG accepted")
+		}
+	})
+}
+
+// withPNGResolution 在 PNG 的 IHDR 之后插入每米 ppm 像素的 pHYs 块
+func withPNGResolution(data []byte, ppm uint32) []byte {
+	chunk := make([]byte, 21)
+	binary.BigEndian.PutUint32(chunk, 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], ppm)
+	binary.BigEndian.PutUint32(chunk[12:], ppm)
+	chunk[16] = 1
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+	ihdr := 8 + 25 // 文件头与 IHDR 块
+	return append(append(append([]byte{}, data[:ihdr]...), chunk...), data[ihdr:]...)
+}
+
+// withJFIFResolution 在 JPEG 的 SOI 之后插入单位为 unit, 密度为 density 的 JFIF 段
+func withJFIFResolution(data []byte, unit byte, density uint16) []byte {
+	segment := []byte{0xFF, 0xE0, 0, 16, 'J', 'F', 'I', 'F', 0, 1, 1, unit,
+		byte(density >> 8), byte(density), byte(density >> 8), byte(density), 0, 0}
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestImageResolution(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 300, 150))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{B: 120, A: 255}), image.Point{}, draw.Src)
+
+	var pngData, jpegData, tiffData bytes.Buffer
+	if err := png.Encode(&pngData, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tiff.Encode(&tiffData, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 3 项的 IFD 之后是 XResolution 与 YResolution 的值 200/1
+	dpi200 := []byte{0, 0, 0, 200, 0, 0, 0, 1, 0, 0, 0, 200, 0, 0, 0, 1}
+	data := 10 + 12*3 + 4
+	exif := exifIFD(dpi200,
+		exifEntry{0x011A, 5, 1, uint32(data)}, exifEntry{0x011B, 5, 1, uint32(data + 8)}, exifEntry{0x0128, 3, 1, 2 << 16})
+	zeroCount := exifIFD(nil, exifEntry{0x011A, 5, 0, 0}, exifEntry{0x011B, 5, 0, 0})
+	truncated := exifIFD(dpi200[:12], exifEntry{0x011A, 5, 1, 10 + 12*2 + 4}, exifEntry{0x011B, 5, 1, 10 + 12*2 + 4 + 8})
+
+	tests := []struct {
+		name string
+		data []byte
+		dpi  float64
+	}{
+		{"png", withPNGResolution(pngData.Bytes(), 11811), 300}, // 11811 像素每米
+		{"jfif", withJFIFResolution(jpegData.Bytes(), 2, 60), 152.4},
+		{"exif", withExif(jpegData.Bytes(), exif), 200},
+		{"exif zero count", withExif(jpegData.Bytes(), zeroCount), 0},
+		{"exif truncated", withExif(jpegData.Bytes(), truncated), 0},
+		{"tiff", tiffData.Bytes(), 72},
+		{"none", pngData.Bytes(), 0},
+	}
+	for _, test := range tests {
+		x, y := ImageResolution(test.data)
+		if math.Abs(x-test.dpi) > 0.1 || math.Abs(y-test.dpi) > 0.1 {
+			t.Errorf("%s: resolution %vx%v, want %v", test.name, x, y, test.dpi)
+		}
+	}
+	// 值的个数为 0 的方向
+	if o := ImageOrientation(withExif(jpegData.Bytes(), exifIFD(nil, exifEntry{0x0112, 3, 0, 6 << 16}))); o != 1 {
+		t.Errorf("orientation %d without a value, want 1", o)
+	}
+
+	// 300 dpi: 300 像素为 1 英寸; 没有分辨率时使用 144 dpi
+	_, records := runReport(t, func(report *core.Report) {
+		report.SetImageDPI(144)
+		for _, data := range [][]byte{tests[0].data, pngData.Bytes()} {
+			i, err := NewImageFromReader(bytes.NewReader(data), 0, 0, report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i.GenerateAtomicCell()
+		}
+	})
+	images := placedImages(t, records)
+	if len(images) != 2 {
+		t.Fatalf("images %v, want 2", images)
+	}
+	if w, h := images[0].x2-images[0].x1, images[0].y2-images[0].y1; math.Abs(w-72) > 0.01 || math.Abs(h-36) > 0.01 {
+		t.Errorf("300 dpi image is %vx%v, want 72x36", w, h)
+	}
+	if w, h := images[1].x2-images[1].x1, images[1].y2-images[1].y1; math.Abs(w-150) > 0.01 || math.Abs(h-75) > 0.01 {
+		t.Errorf("image without resolution is %vx%v, want 150x75 at 144 dpi", w, h)
+	}
+}
+
+// multiPageTIFF 返回未压缩的多页灰度 TIFF
+func multiPageTIFF(pages []*image.Gray) []byte {
+	order := binary.LittleEndian
+	data := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+	link := 4 // 指向下一个 IFD 的偏移的位置
+	for _, page := range pages {
+		w, h := page.Bounds().Dx(), page.Bounds().Dy()
+		pixels := len(data)
+		data = append(data, page.Pix[:w*h]...)
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+
+		order.PutUint32(data[link:], uint32(len(data)))
+		entries := [][3]uint32{ // 标签, 类型, 值
+			{256, 4, uint32(w)}, {257, 4, uint32(h)}, {258, 3, 8}, {259, 3, 1}, {262, 3, 1},
+			{273, 4, uint32(pixels)}, {277, 3, 1}, {278, 4, uint32(h)}, {279, 4, uint32(w * h)},
+		}
+		ifd := make([]byte, 2+len(entries)*12+4)
+		order.PutUint16(ifd, uint16(len(entries)))
+		for i, e := range entries {
+			entry := ifd[2+i*12:]
+			order.PutUint16(entry, uint16(e[0]))
+			order.PutUint16(entry[2:], uint16(e[1]))
+			order.PutUint32(entry[4:], 1)
+			if e[1] == 3 {
+				order.PutUint16(entry[8:], uint16(e[2]))
+			} else {
+				order.PutUint32(entry[8:], e[2])
+			}
+		}
+		link = len(data) + len(ifd) - 4
+		data = append(data, ifd...)
+	}
+	return data
+}
+
+func TestImageFrames(t *testing.T) {
+	var pages []*image.Gray
+	for i, size := range []int{40, 60, 80} {
+		page := image.NewGray(image.Rect(0, 0, size, size/2))
+		draw.Draw(page, page.Bounds(), image.NewUniform(color.Gray{Y: uint8(60 * i)}), image.Point{}, draw.Src)
+		pages = append(pages, page)
+	}
+	scan := multiPageTIFF(pages)
+
+	frames, err := ImageFrames(scan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("%d tiff pages, want 3", len(frames))
+	}
+	for i, frame := range frames {
+		decoded, err := tiff.Decode(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Bounds() != pages[i].Bounds() || decoded.(*image.Gray).GrayAt(0, 0) != pages[i].GrayAt(0, 0) {
+			t.Errorf("tiff page %d decoded as %v", i, decoded.Bounds())
+		}
+	}
+	// 各页共享原来的数据
+	tiffs, err := tiffPages(scan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, page := range tiffs {
+		if &page.data[0] != &scan[0] {
+			t.Errorf("tiff page %d copies the data", i)
+		}
+	}
+
+	// 压缩的多页 TIFF: 第一页为 CCITT G4 压缩的黑白图片, 第二页为 LZW 压缩的彩色图片
+	compressed, err := ioutil.ReadFile("testdata/MultiPage.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err = ImageFrames(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("%d compressed tiff pages, want 2", len(frames))
+	}
+	for i, want := range []image.Rectangle{image.Rect(0, 0, 153, 55), image.Rect(0, 0, 150, 100)} {
+		decoded, err := tiff.Decode(frames[i])
+		if err != nil {
+			t.Fatalf("compressed tiff page %d: %v", i, err)
+		}
+		if decoded.Bounds() != want {
+			t.Errorf("compressed tiff page %d decoded as %v, want %v", i, decoded.Bounds(), want)
+		}
+	}
+
+	// GIF 动画: 第二帧只覆盖左上角, 合成后为完整的画面
+	palette := color.Palette{color.Transparent, color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}}
+	first := image.NewPaletted(image.Rect(0, 0, 20, 20), palette)
+	draw.Draw(first, first.Bounds(), image.NewUniform(palette[1]), image.Point{}, draw.Src)
+	second := image.NewPaletted(image.Rect(0, 0, 10, 10), palette)
+	draw.Draw(second, second.Bounds(), image.NewUniform(palette[2]), image.Point{}, draw.Src)
+	var animation bytes.Buffer
+	err = gif.EncodeAll(&animation, &gif.GIF{Image: []*image.Paletted{first, second}, Delay: []int{10, 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frames, err = ImageFrames(animation.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("%d gif frames, want 2", len(frames))
+	}
+	composed, err := png.Decode(frames[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, b, _ := composed.At(15, 15).RGBA(); r != 0xFFFF || b != 0 {
+		t.Error("second gif frame not composed over the first")
+	}
+	if r, _, b, _ := composed.At(5, 5).RGBA(); r != 0 || b != 0xFFFF {
+		t.Error("second gif frame not drawn")
+	}
+
+	// 每一页一个 PDF 页面, 在内容区域内居中; 当前页已写入内容时从新的一页开始
+	var sx, sy, width, height float64
+	_, records := runReport(t, func(report *core.Report) {
+		sx, sy = report.GetPageStartXY()
+		width, height = report.GetContentWidthAndHeight()
+		for _, data := range [][]byte{scan, compressed} {
+			pages, err := NewImagePages(bytes.NewReader(data), report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages.GenerateAtomicCell()
+		}
+	})
+	images := placedImages(t, records)
+	if len(images) != 5 {
+		t.Fatalf("images %v, want 5", images)
+	}
+	for i, image := range images {
+		cx, cy := (image.x1+image.x2)/2, (image.y1+image.y2)/2
+		if image.page != i+1 || math.Abs(cx-sx-width/2) > 0.01 || math.Abs(cy-sy-height/2) > 0.01 ||
+			image.x1 < sx-0.01 || image.x2 > sx+width+0.01 || image.y1 < sy-0.01 || image.y2 > sy+height+0.01 {
+			t.Errorf("frame %d at %v, want it centered in the content area of page %d", i, image, i+1)
+		}
+	}
+}
+
+func TestImageMissing(t *testing.T) {
+	corrupt := filepath.Join(t.TempDir(), "corrupt.png")
+	if err := ioutil.WriteFile(corrupt, []byte("\x89PNG\r\n\x1a\nnot a png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		image     *Image
+		x, y      float64
+		err       error
+		boxes     []record
+		texts     []placedText
+		images    []placedImage
+		color     [3]int  // 生成之后的字体颜色
+		lastColor string  // 最后一个 TC 记录的颜色
+		ascender  float64 // 字体在 10pt 的上沿
+		descender float64 // 字体在 10pt 的下沿
+	}
+	generate := func(policy core.MissingImagePolicy, path string) result {
+		var res result
+		_, records := runReport(t, func(report *core.Report) {
+			report.SetMissingImagePolicy(policy)
+			report.Font(core.FontSans, 10, "")
+			report.SetFont(core.FontSans, 10)
+			report.TextColor(200, 0, 0)
+			res.x, res.y = report.GetXY()
+			res.ascender, res.descender = report.GetFontMetricsWithStyle(core.FontSans, "", 10)
+			res.image = NewImageWithWidthAndHeight(path, 120, 80, report)
+			_, _, res.err = res.image.GenerateAtomicCell()
+			res.color[0], res.color[1], res.color[2] = report.GetTextColor()
+		})
+		for _, r := range records {
+			switch r[0] {
+			case "BC":
+				res.boxes = append(res.boxes, r)
+			case "TC":
+				res.lastColor = strings.Join(r[1:], ",")
+			}
+		}
+		res.texts, res.images = placedTexts(t, records), placedImages(t, records)
+		return res
+	}
+
+	// 占位: 120x80 的框, 文件名写在框内
+	missing := "example/pictures/missing-logo.png"
+	res := generate(core.MissingImagePlaceholder, missing)
+	if res.err != nil || res.image.GetWidth() != 120 || res.image.GetHeight() != 80 {
+		t.Errorf("placeholder: %v, %vx%v, want a 120x80 box", res.err, res.image.GetWidth(), res.image.GetHeight())
+	}
+	if len(res.boxes) != 1 || len(res.images) != 0 {
+		t.Fatalf("placeholder boxes %v and images %v, want one box", res.boxes, res.images)
+	}
+	box := res.boxes[0]
+	if box.float(t, 1) != res.x || box.float(t, 2) != res.y || box.float(t, 3) != 120 || box.float(t, 4) != 80 {
+		t.Errorf("placeholder box %v, want 120x80 at (%v, %v)", box, res.x, res.y)
+	}
+	if len(res.texts) != 1 || res.texts[0].text != "missing-logo.png" ||
+		res.texts[0].x <= res.x || res.texts[0].x >= res.x+120 || res.texts[0].y <= res.y || res.texts[0].y >= res.y+80 {
+		t.Errorf("placeholder label %v, want the file name inside the box", res.texts)
+	}
+	// 字形的上下沿在框内居中, 之后恢复原来的字体颜色
+	if baseline := res.y + (80+res.ascender+res.descender)/2; len(res.texts) == 1 && math.Abs(res.texts[0].y-baseline) > 0.01 {
+		t.Errorf("placeholder label baseline %v, want %v: the glyphs centered in the box", res.texts[0].y, baseline)
+	}
+	if res.color != [3]int{200, 0, 0} || res.lastColor != "200,0,0" {
+		t.Errorf("text color %v (record %q) after the placeholder, want 200,0,0 restored", res.color, res.lastColor)
+	}
+
+	res = generate(core.MissingImageSkip, missing)
+	if res.err != nil || res.image.GetWidth() != 0 || len(res.boxes)+len(res.images)+len(res.texts) != 0 {
+		t.Errorf("skipped image: %v, width %v, boxes %v, images %v", res.err, res.image.GetWidth(), res.boxes, res.images)
+	}
+
+	if res = generate(core.MissingImageError, missing); res.err == nil {
+		t.Error("missing image with the error policy generated without an error")
+	}
+	if res = generate(core.MissingImagePlaceholder, corrupt); res.err == nil {
+		t.Error("corrupt image generated without an error")
+	}
+
+	// 不存在或者不能解码的文件返回错误
+	for _, path := range []string{missing, corrupt} {
+		if w, h, err := GetImageWidthAndHeight(path); err == nil {
+			t.Errorf("size of %s: %vx%v without an error", path, w, h)
+		}
+	}
+	if w, h, err := GetImageWidthAndHeight("example/pictures/cat.jpg"); err != nil || w <= 0 || h <= 0 {
+		t.Errorf("size of the cat: %vx%v, %v", w, h, err)
+	}
+}
+
+func TestSearchablePage(t *testing.T) {
+	hocr := `<?xml version="1.0" encoding="UTF-8"?>
+<html><body>
+ <div class='ocr_page' id='page_1' title='image "scan.png"; bbox 0 0 1000 1400; ppageno 0'>
+  <p class='ocr_par'><span class='ocr_line' title="bbox 100 100 600 140">
+   <span class='ocrx_word' id='word_1_1' title='bbox 100 100 300 140; x_wconf 96'>Invoice</span>
+   <span class='ocrx_word' id='word_1_2' title='bbox 320 100 600 140; x_wconf 95'><strong>No.&nbsp;42</strong></span>
+  </span></p>
+ </div>
+</body></html>`
+	pages, err := ParseHOCR(strings.NewReader(hocr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || pages[0].Width != 1000 || pages[0].Height != 1400 || len(pages[0].Words) != 2 {
+		t.Fatalf("hocr pages %+v", pages)
+	}
+	if w := pages[0].Words[1]; w.Text != "No. 42" || w.BBox != [4]float64{320, 100, 600, 140} {
+		t.Errorf("hocr word %+v", w)
+	}
+
+	fromJSON, err := ParseOCRJSON(strings.NewReader(`{"width": 1000, "height": 1400, "words": [
+		{"text": "Invoice", "bbox": [100, 100, 300, 140]}, {"text": "No. 42", "bbox": [320, 100, 600, 140]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fromJSON) != 1 || len(fromJSON[0].Words) != 2 || fromJSON[0].Words[0] != pages[0].Words[0] {
+		t.Errorf("json pages %+v", fromJSON)
+	}
+
+	scan := image.NewGray(image.Rect(0, 0, 1000, 1400))
+	draw.Draw(scan, scan.Bounds(), image.White, image.Point{}, draw.Src)
+	widths := map[string]float64{}
+	data, records := runReport(t, func(report *core.Report) {
+		report.Font(core.FontSans, 12, "")
+		for _, word := range fromJSON[0].Words {
+			report.SetFont(core.FontSans, ocrFontSize)
+			widths[word.Text] = report.MeasureTextWidth(word.Text)
+		}
+		report.SetFont(core.FontSans, 12)
+		img, err := NewImageFromImage(scan, 0, 0, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := NewSearchablePage(img, fromJSON[0], report).GenerateAtomicCell(); err != nil {
+			t.Fatal(err)
+		}
+		if font := report.GetCurrentFont(); font.Family != core.FontSans || font.Size != 12 {
+			t.Errorf("font %+v after the page, want %s 12", font, core.FontSans)
+		}
+	})
+
+	// 1000x1400 的图片在 A4(595.28x841.89)内等比缩放, 宽度铺满, 垂直居中
+	near := func(a, b float64) bool { return math.Abs(a-b) < 0.01 }
+	images := placedImages(t, records)
+	if len(images) != 1 {
+		t.Fatalf("images %v, want the scan", images)
+	}
+	scanAt := images[0]
+	if !near(scanAt.x1, 0) || !near(scanAt.x2, 595.28) || !near(scanAt.y1+scanAt.y2, 841.89) {
+		t.Errorf("scan placed at %+v, want the full page width", scanAt)
+	}
+
+	// 词以不可见的文本写入, 写完后恢复填充的模式
+	mode := "0"
+	walkRecords(t, records, func(r record, m core.Matrix, depth, page int) {
+		switch r[0] {
+		case "TR":
+			mode = r[1]
+		case "CL":
+			if mode != "3" {
+				t.Errorf("word %q written in render mode %q", r[3], mode)
+			}
+		}
+	})
+	if mode != "0" {
+		t.Errorf("render mode %q after the page", mode)
+	}
+
+	// 词缩放到图片上的框: 起点在框的左边, 基线在框内, 宽度等于框的宽度
+	texts := placedTexts(t, records)
+	if len(texts) != len(fromJSON[0].Words) {
+		t.Fatalf("texts %+v, want the words %+v", texts, fromJSON[0].Words)
+	}
+	scale := (scanAt.x2 - scanAt.x1) / 1000
+	for i, word := range fromJSON[0].Words {
+		text := texts[i]
+		left, right := scanAt.x1+word.BBox[0]*scale, scanAt.x1+word.BBox[2]*scale
+		top, bottom := scanAt.y1+word.BBox[1]*scale, scanAt.y1+word.BBox[3]*scale
+		if text.text != word.Text || !near(text.x, left) || text.y <= top || text.y > bottom ||
+			!near(text.x+text.dx*widths[word.Text], right) || !near(text.dy, 0) {
+			t.Errorf("word %q placed at %+v, want the box %v-%v x %v-%v", word.Text, text, left, right, top, bottom)
+		}
+	}
+	if !strings.Contains(pdfContent(data), "3 Tr") {
+		t.Error("text layer written without the invisible render mode")
+	}
+}
+
+func TestSVG(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="200" height="100" viewBox="0 0 200 100">
+ <style>
+  /* 优先级依次升高: 属性, 元素名, class, id, style 属性 */
+  rect { stroke: red }
+  .mark, .hot { fill: #0f0 }
+  path.mark { stroke-width: 3 !important }
+  #hot { fill: yellow }
+ </style>
+ <defs>
+  <linearGradient id="fade"><stop offset="0" stop-color="#f00"/><stop offset="100%" stop-color="blue"/></linearGradient>
+  <radialGradient id="glow" xlink:href="#fade" cx="0.5" cy="0.5" r="0.5"/>
+  <path id="mark" class="mark" d="M0 0 h10 v10 z" fill="blue" stroke="black"/>
+ </defs>
+ <rect x="10" y="10" width="80" height="40" rx="5" fill="url(#fade)" stroke="black" stroke-width="2"/>
+ <circle cx="150" cy="30" r="20" style="fill: url(#glow); stroke: none"/>
+ <g transform="translate(100 50) rotate(90)" fill="none" stroke="rgb(0, 128, 0)" stroke-dasharray="4 2">
+  <polyline points="0,0 10,10 20,0"/>
+ </g>
+ <use xlink:href="#mark" x="5" y="80"/>
+ <g opacity="0.5">
+  <rect id="hot" class="hot" x="0" y="0" width="4" height="4" fill-opacity="50%" style="stroke: blue"/>
+ </g>
+ <text x="100" y="90" font-size="12" text-anchor="middle" fill="navy">Hello <tspan font-weight="bold">SVG</tspan></text>
+ <rect width="10" height="10" display="none"/>
+</svg>`
+
+	var (
+		height float64
+		x0, y0 float64
+	)
+	data, records := runReport(t, func(report *core.Report) {
+		report.Font(core.FontSans, 12, "")
+		report.SetFont(core.FontSans, 12)
+		report.TextColor(10, 20, 30)
+		x0, y0 = report.GetXY()
+		svg, err := NewSVGFromReader(strings.NewReader(doc), 300, 0, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		height = svg.GetHeight()
+		if _, _, err := svg.GenerateAtomicCell(); err != nil {
+			t.Fatal(err)
+		}
+		if r, g, b := report.GetTextColor(); r != 10 || g != 20 || b != 30 {
+			t.Errorf("text color (%d, %d, %d) after the svg, want (10, 20, 30)", r, g, b)
+		}
+	})
+	if height != 150 {
+		t.Errorf("height %v, want 150 for the 2:1 svg at 300pt", height)
+	}
+
+	// 路径的起点在页面上的位置, 路径的样式, 以及绘制时的不透明度
+	type placedPath struct {
+		x, y                             float64
+		fill, stroke, width, dash, alpha string
+	}
+	var (
+		paths      []placedPath
+		gradients  int
+		depth      int
+		alpha      = "1.000|1.000"
+		textColors []string
+	)
+	walkRecords(t, records, func(r record, m core.Matrix, d, page int) {
+		depth = d
+		switch r[0] {
+		case "PA":
+			start := strings.Fields(r[10])
+			x, y := m.Apply(record(start).float(t, 1), record(start).float(t, 2))
+			paths = append(paths, placedPath{x: x, y: y, fill: r[1], stroke: r[3], width: r[4], dash: r[8], alpha: alpha})
+		case "GD":
+			gradients++
+		case "TA":
+			alpha = "1.000|1.000"
+			if len(r) == 3 {
+				alpha = r[1] + "|" + r[2]
+			}
+		case "TC":
+			textColors = append(textColors, strings.Join(r[1:], ","))
+		}
+	})
+	if depth != 0 || alpha != "1.000|1.000" {
+		t.Errorf("after the svg: transform depth %d, transparency %s", depth, alpha)
+	}
+	// rect, circle, polyline, use 引用的 path, 半透明的 rect; display:none 的 rect 不绘制
+	if len(paths) != 5 || gradients != 2 {
+		t.Fatalf("%d paths, %d gradients; want 5 and 2", len(paths), gradients)
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+	for i, want := range []placedPath{
+		{x: 15, y: 10, stroke: "255,0,0", width: "2.0000", alpha: "1.000|1.000"},                                   // 元素名的规则覆盖了属性
+		{x: 170, y: 30, stroke: "", width: "0.0000", alpha: "1.000|1.000"},                                         // style 属性覆盖了元素名的规则
+		{x: 100, y: 50, fill: "", stroke: "0,128,0", width: "1.0000", dash: "4.0000 2.0000", alpha: "1.000|1.000"}, // 旋转 90 度
+		{x: 5, y: 80, fill: "0,255,0", stroke: "0,0,0", width: "3.0000", alpha: "1.000|1.000"},                     // class 的规则覆盖了属性
+		{x: 0, y: 0, fill: "255,255,0", stroke: "0,0,255", width: "1.0000", alpha: "0.250|0.500"},                  // id 覆盖 class, opacity 相乘
+	} {
+		got := paths[i]
+		if !near(got.x, x0+want.x*1.5) || !near(got.y, y0+want.y*1.5) {
+			t.Errorf("path %d starts at (%v, %v), want (%v, %v)", i, got.x, got.y, x0+want.x*1.5, y0+want.y*1.5)
+		}
+		if i < 2 {
+			want.fill = got.fill // 渐变的 key, 在下面检查
+		}
+		if got.fill != want.fill || got.stroke != want.stroke || got.width != want.width || got.dash != want.dash || got.alpha != want.alpha {
+			t.Errorf("path %d painted %+v, want %+v", i, got, want)
+		}
+	}
+	if !strings.HasPrefix(paths[0].fill, core.GradientKeyPrefix) || !strings.HasPrefix(paths[1].fill, core.GradientKeyPrefix) {
+		t.Errorf("gradient fills %q and %q", paths[0].fill, paths[1].fill)
+	}
+
+	// 文本以 (100, 90) 居中, 写完后恢复之前的字体颜色
+	texts := placedTexts(t, records)
+	if len(texts) != 2 || texts[0].text+texts[1].text != "Hello SVG" {
+		t.Fatalf("texts %+v", texts)
+	}
+	for _, text := range texts {
+		if !near(text.y, y0+90*1.5) || text.depth == 0 {
+			t.Errorf("%q at y %v depth %d, want the baseline %v inside the viewport", text.text, text.y, text.depth, y0+90*1.5)
+		}
+	}
+	if center := x0 + 100*1.5; !(texts[0].x < center && texts[1].x > center) {
+		t.Errorf("text from %v and %v, want it centered on %v", texts[0].x, texts[1].x, center)
+	}
+	if n := len(textColors); n < 2 || textColors[n-2] != "0,0,128" || textColors[n-1] != "10,20,30" {
+		t.Errorf("text colors %v, want navy and then the previous color", textColors)
+	}
+
+	content := pdfContent(data)
+	for _, want := range []string{"/ShadingType 2", "/ShadingType 3"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("pdf without %q", want)
+		}
+	}
+	if !strings.Contains(content, "[4.0000 2.0000] 0.0000 d") {
+		t.Error("content without the dash of the polyline")
+	}
+
+	for _, doc := range []string{
+		"<html/>",
+		`<svg><style>g rect { fill: red }</style></svg>`,
+		`<svg><style>rect:hover { fill: red }</style></svg>`,
+		`<svg><style>@media print { rect { fill: red } }</style></svg>`,
+		`<svg><style>rect { fill: red</style></svg>`,
+	} {
+		if _, err := NewSVGFromReader(strings.NewReader(doc), 100, 100, core.CreateReport()); err == nil {
+			t.Errorf("no error for %s", doc)
+		}
+	}
+}
+
+func TestSVGUseLimit(t *testing.T) {
+	// 每层引用上一层 4 次, 完全展开时有 4^8 个路径
+	doc := `<svg width="100" height="100"><defs><path id="u0" d="M0 0 h1 v1 z"/>`
+	for i := 1; i <= 8; i++ {
+		doc += fmt.Sprintf(`<g id="u%d">`, i)
+		for j := 0; j < 4; j++ {
+			doc += fmt.Sprintf(`<use xlink:href="#u%d"/>`, i-1)
+		}
+		doc += `</g>`
+	}
+	doc += `</defs><use xlink:href="#u8"/><use xlink:href="#u0"/></svg>`
+
+	_, records := runReport(t, func(report *core.Report) {
+		svg, err := NewSVGFromReader(strings.NewReader(doc), 100, 100, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		svg.Draw(0, 0)
+		svg.Draw(0, 0) // 每次绘制重新计数
+	})
+	var paths, depth int
+	for _, r := range records {
+		switch r[0] {
+		case "PA":
+			paths++
+		case "TP", "TK":
+			depth++
+		case "TQ":
+			depth--
+		}
+	}
+	if paths == 0 || paths > 2*svgMaxUses || depth != 0 {
+		t.Errorf("%d paths at depth %d, want at most %d per draw", paths, depth, svgMaxUses)
+	}
+}
+
+func TestSVGPathData(t *testing.T) {
+	// viewBox 与宽高相同, 用户坐标即报表坐标
+	doc := `<svg width="200" height="100" viewBox="0 0 200 100">
+ <path d="M10 10 l 20 0 a10 10 0 0 1 0 20 Q40 50 30 60 t -10 0 z m5 5 H0" fill="#0a0"/>
+ <path transform="translate(10,20) scale(2) rotate(90)" d="M1 0 h1"/>
+</svg>`
+	var x0, y0 float64
+	_, records := runReport(t, func(report *core.Report) {
+		x0, y0 = report.GetXY()
+		svg, err := NewSVGFromReader(strings.NewReader(doc), 200, 100, report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		svg.Draw(x0, y0)
+	})
+
+	var paths []record
+	var starts [][2]float64
+	walkRecords(t, records, func(r record, m core.Matrix, depth, page int) {
+		if r[0] == "PA" {
+			start := record(strings.Fields(r[10]))
+			x, y := m.Apply(start.float(t, 1), start.float(t, 2))
+			paths = append(paths, r)
+			starts = append(starts, [2]float64{x - x0, y - y0})
+		}
+	})
+	if len(paths) != 2 {
+		t.Fatalf("%d paths, want 2", len(paths))
+	}
+
+	// 圆弧的终点精确, z 之后的相对坐标从子路径的起点开始
+	data := paths[0][10]
+	if want := "M 10.0000 10.0000 L 30.0000 10.0000 C"; !strings.HasPrefix(data, want) {
+		t.Errorf("path %q, want the prefix %q", data, want)
+	}
+	if !strings.Contains(data, " 30.0000 30.0000 ") || !strings.HasSuffix(data, "M 15.0000 15.0000 L 0.0000 15.0000") {
+		t.Errorf("path %q", data)
+	}
+	if paths[0][1] != "0,170,0" {
+		t.Errorf("fill %q, want 0,170,0 for #0a0", paths[0][1])
+	}
+	// 变换: (1, 0) 旋转到 (0, 1), 放大到 (0, 2), 再平移到 (10, 22)
+	if got := starts[1]; math.Abs(got[0]-10) > 1e-6 || math.Abs(got[1]-22) > 1e-6 {
+		t.Errorf("(1, 0) transformed to %v, want (10, 22)", got)
+	}
+
+	// 边界框包含曲线的极值点, 不包含控制点: 控制点为 (20, 70), 曲线的 y 最大为 65
+	if bbox := parseSVGPathData("M30 60 Q20 70 10 60 H40 V10").bbox; bbox[0] != 10 || bbox[1] != 10 || bbox[2] != 40 || bbox[3] != 65 {
+		t.Errorf("bbox %v", bbox)
+	}
+}
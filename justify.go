@@ -0,0 +1,26 @@
+package gopdf
+
+import (
+	"github.com/tiechui1994/gopdf/core"
+)
+
+// 两端对齐: 行的剩余宽度分摊到词间(空格)或字间(含中日文字符的行), 由 core 在写入字形时追加间距.
+// 段落的最后一行不对齐, 按左对齐(RTL 段落按右对齐)写入.
+
+// justifyCell 在 (x, y) 写入 text, 将其撑满 width
+func justifyCell(pdf *core.Report, x, y, width float64, text string) {
+	word, char := core.JustifySpacing(text, width-pdf.MeasureTextWidth(text))
+	if word != 0 {
+		pdf.WordSpacing(word)
+	}
+	if char != 0 {
+		pdf.CharSpacing(char)
+	}
+	pdf.Cell(x, y, text)
+	if word != 0 {
+		pdf.WordSpacing(0)
+	}
+	if char != 0 {
+		pdf.CharSpacing(0)
+	}
+}
@@ -26,8 +26,7 @@ func NewLex() *Lexer {
 func (l *Lexer) Lex(text string) []Token {
 	re_break := MustCompile(`\r\n|\r`, RE2)
 	text, _ = re_break.Replace(text, "", 0, -1)
-	re_blank := MustCompile(`\t`, RE2)
-	text, _ = re_blank.Replace(text, "    ", 0, -1)
+	text = expandTabs(text, 4)
 
 	l.blockTokens(text, &l.tokens, true)
 	l.inline(&l.tokens)
@@ -35,6 +34,33 @@ func (l *Lexer) Lex(text string) []Token {
 	return l.tokens
 }
 
+// expandTabs replaces each tab by the spaces up to the next tab stop, tab stops being every
+// size columns of the line.
+func expandTabs(text string, size int) string {
+	if !strings.Contains(text, "\t") {
+		return text
+	}
+	var (
+		buf    strings.Builder
+		column int
+	)
+	for _, r := range text {
+		switch r {
+		case '\t':
+			n := size - column%size
+			buf.WriteString(strings.Repeat(" ", n))
+			column += n
+		case '\n':
+			buf.WriteRune(r)
+			column = 0
+		default:
+			buf.WriteRune(r)
+			column++
+		}
+	}
+	return buf.String()
+}
+
 func (l *Lexer) blockTokens(content string, tokens *[]Token, top bool) []Token {
 	re_blank := MustCompile(`^ +$`, Multiline)
 	content, _ = re_blank.Replace(content, "", 0, -1)
@@ -348,6 +374,14 @@ func (l *Lexer) inlineTokens(text string, tokens *[]Token, inLink, inRawBlock bo
 			continue
 		}
 
+		// sup, sub
+		if token, _ := script(src); !IsEmpty(token) {
+			src = src[len([]rune(token.Raw)):]
+			token.Tokens = l.inlineTokens(token.Text, &[]Token{}, inLink, inRawBlock, "")
+			*tokens = append(*tokens, token)
+			continue
+		}
+
 		// autolink
 		if token, _ := autoLink(src); !IsEmpty(token) {
 			src = src[len([]rune(token.Raw)):]
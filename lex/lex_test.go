@@ -34,3 +34,20 @@ func TestLex(t *testing.T) {
 | - 4, x | 5 | 6 *ss*  |`
 	t.Log(block["table"].Exec([]rune(text)))
 }
+
+func TestExpandTabs(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"no tabs", "no tabs"},
+		{"\tcode\na\tb\nabcd\te", "    code\na   b\nabcd    e"},
+		{"a\t\tb", "a       b"},
+		{"中文\tx", "中文  x"},
+		{"  - item\n\t- nested", "  - item\n    - nested"},
+	}
+	for _, test := range tests {
+		if got := expandTabs(test.text, 4); got != test.want {
+			t.Errorf("expandTabs(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
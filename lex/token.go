@@ -145,10 +145,15 @@ var (
 	inline_gfm__extended_email = `[A-Za-z0-9._+-]+(@)[a-zA-Z0-9-_]+(?:\.[a-zA-Z0-9-_]*[a-zA-Z0-9])+(?![-_])`
 	inline_gfm_url             = `^((?:ftp|https?):\/\/|www\.)(?:[a-zA-Z0-9\-]+\.?)+[^\s<]*|^email`
 	inline_gfm__backpedal      = `(?:[^?!.,:;*_~()&]+|\([^)]*\)|&(?![a-zA-Z0-9]+;$)|[?!.,:;*_~)]+(?!$))+`
-	inline_gfm_del             = `^~+(?=\S)([\s\S]*?\S)~+`
+	inline_gfm_del             = `^~~(?=\S)([\s\S]*?\S)~~`
+
+	// 上标 ^x^, 下标 ~x~ (内容不含空白), 以及 <sup>x</sup>, <sub>x</sub>
+	inline_gfm_sup    = `^\^((?:\\[\^ ]|[^\s\^\\])+)\^`
+	inline_gfm_sub    = `^~((?:\\[~ ]|[^\s~\\])+)~`
+	inline_gfm_script = `^<(sup|sub)>([\s\S]*?)<\/\1>`
 
 	// ^(`+|[^`])(?:[\s\S]*?(?:(?=[\\<!\[`*~]|\b_|https?:\/\/|ftp:\/\/|www\.|$)|[^ ](?= {2,}\n)|[^a-zA-Z0-9.!#$%&'*+\/=?_`{\|}~-](?=[a-zA-Z0-9.!#$%&'*+\/=?_`{\|}~-]+@))|(?= {2,}\n|[a-zA-Z0-9.!#$%&'*+\/=?_`{\|}~-]+@))
-	inline_gfm_text = `^($1+|[^$1])(?:[\s\S]*?(?:(?=[\<!\[$1*~]|\b_|https?:\/\/|ftp:\/\/|www\.|$)|[^ ](?= {2,}\n)|[^a-zA-Z0-9.!#$%&'*+\/=?_$1{\|}~-](?=[a-zA-Z0-9.!#$%&'*+\/=?_$1{\|}~-]+@))|(?= {2,}\n|[a-zA-Z0-9.!#$%&'*+\/=?_$1{\|}~-]+@))`
+	inline_gfm_text = `^($1+|[^$1])(?:[\s\S]*?(?:(?=[\<!\[$1*~\^]|\b_|https?:\/\/|ftp:\/\/|www\.|$)|[^ ](?= {2,}\n)|[^a-zA-Z0-9.!#$%&'*+\/=?_$1{\|}~-](?=[a-zA-Z0-9.!#$%&'*+\/=?_$1{\|}~-]+@))|(?= {2,}\n|[a-zA-Z0-9.!#$%&'*+\/=?_$1{\|}~-]+@))`
 )
 
 //gfm: true,
@@ -367,6 +372,9 @@ func initInlineGfm(breaks bool) map[string]*Regex {
 		"url":             MustCompile(inline_gfm_url, option),
 		"_backpedal":      MustCompile(inline_gfm__backpedal, option),
 		"del":             MustCompile(inline_gfm_del, option),
+		"sup":             MustCompile(inline_gfm_sup, option),
+		"sub":             MustCompile(inline_gfm_sub, option),
+		"script":          MustCompile(inline_gfm_script, option),
 		"text":            MustCompile(inline_gfm_text, option),
 	})
 
@@ -1151,6 +1159,35 @@ func del(src []rune) (token Token, err error) {
 	}, nil
 }
 
+// script 匹配上标 ^x^ / 下标 ~x~ 以及 <sup>, <sub> 标签, token 的类型为 "sup" 或 "sub"
+func script(src []rune) (token Token, err error) {
+	for _, typ := range []string{"sup", "sub"} {
+		match, err := inline[typ].Exec(src)
+		if err != nil {
+			return token, err
+		}
+		if match != nil {
+			return Token{
+				Type:   typ,
+				Raw:    match.GroupByNumber(0).String(),
+				Text:   match.GroupByNumber(1).String(),
+				Tokens: []Token{},
+			}, nil
+		}
+	}
+
+	match, err := inline["script"].Exec(src)
+	if err != nil || match == nil {
+		return token, err
+	}
+	return Token{
+		Type:   match.GroupByNumber(1).String(),
+		Raw:    match.GroupByNumber(0).String(),
+		Text:   match.GroupByNumber(2).String(),
+		Tokens: []Token{},
+	}, nil
+}
+
 func autoLink(src []rune) (token Token, err error) {
 	match, err := inline["autolink"].Exec(src)
 	if err != nil || match == nil {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"strings"
 	"testing"
 )
 
@@ -105,3 +106,39 @@ func TestReplace(t *testing.T) {
 
 	t.Log(string(row))
 }
+
+// 删除线只由 ~~ 包围; 单个 ~ 包围且不含空白的内容为下标, 含空白时按原文保留
+func TestDelAndScript(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // 段落内 token 的 "类型:文本"
+	}{
+		{"~~del~~", "del:del"},
+		{"~~two words~~", "del:two words"},
+		{"~sub~", "sub:sub"},
+		{"H~2~O", "text:H sub:2 text:O"},
+		{"5 m^3^", "text:5 m sup:3"},
+		{"x<sub>i</sub>", "text:x sub:i"},
+		{"~two words~", "text:~two words~"},
+		{"a ~ b", "text:a ~ b"},
+	}
+	for _, test := range tests {
+		tokens := NewLex().Lex(test.src + "\n")
+		if len(tokens) != 1 || tokens[0].Type != "paragraph" {
+			t.Errorf("%q: tokens %v", test.src, tokens)
+			continue
+		}
+		// 文本可能分为多个相邻的 text token
+		var got []string
+		for i, token := range tokens[0].Tokens {
+			if i > 0 && token.Type == "text" && tokens[0].Tokens[i-1].Type == "text" {
+				got[len(got)-1] += token.Text
+				continue
+			}
+			got = append(got, token.Type+":"+token.Text)
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("%q lexed as %q, want %q", test.src, strings.Join(got, " "), test.want)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"math"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -159,14 +160,101 @@ func (m *MdHardBreak) GenerateAtomicCell() (pagebreak, over bool, err error) {
 }
 
 // MdImage 嵌入图片；本地路径或 http(s)。高度默认一行 bodyLineHeight。
+// 图片的标题全部由 key=value 组成时作为图片的选项, 如 ![logo](logo.png "width=120 height=60 fit=cover align=center"):
+// width/height 框的宽高, fit 缩放方式(contain/cover/fill/none), align 水平对齐(left/center/right),
+// valign 垂直对齐(top/middle/bottom), rotate 逆时针旋转的角度。
 type MdImage struct {
 	ElementBase
 	image  *Image
+	width  float64
 	height float64
+
+	fit      *ImageFit
+	hAlign   HorizontalAlign
+	vAlign   VerticalAlign
+	rotation float64
 }
 
-// SetText 加载路径：支持 http(s) 下载至临时文件或本地路径；首个参数为 URL / 路径。
+// parseOptions 解析图片标题中的选项；标题不全是选项时返回 false，作为普通标题忽略。
+func (i *MdImage) parseOptions(title string) bool {
+	fields := strings.Fields(title)
+	if len(fields) == 0 {
+		return false
+	}
+	options := *i
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return false
+		}
+		key, value := kv[0], strings.ToLower(kv[1])
+		switch key {
+		case "width", "height", "rotate":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false
+			}
+			switch key {
+			case "width":
+				options.width = v
+			case "height":
+				options.height = v
+			default:
+				options.rotation = v
+			}
+		case "fit":
+			fits := map[string]ImageFit{"contain": FitContain, "cover": FitCover, "fill": FitFill, "none": FitNone}
+			fit, ok := fits[value]
+			if !ok {
+				return false
+			}
+			options.fit = &fit
+		case "align":
+			aligns := map[string]HorizontalAlign{"left": AlignLeft, "center": AlignCenter, "right": AlignRight}
+			align, ok := aligns[value]
+			if !ok {
+				return false
+			}
+			options.hAlign = align
+		case "valign":
+			aligns := map[string]VerticalAlign{"top": AlignTop, "middle": AlignMiddle, "bottom": AlignBottom}
+			align, ok := aligns[value]
+			if !ok {
+				return false
+			}
+			options.vAlign = align
+		default:
+			return false
+		}
+	}
+	*i = options
+	return true
+}
+
+// SetFit 设置图片在框内的缩放方式，参考 Image.SetFit；须在 SetText 之前调用。
+func (i *MdImage) SetFit(fit ImageFit) *MdImage {
+	i.fit = &fit
+	return i
+}
+
+// SetAlign 设置图片在框内的对齐方式；须在 SetText 之前调用。
+func (i *MdImage) SetAlign(horizontal HorizontalAlign, vertical VerticalAlign) *MdImage {
+	i.hAlign, i.vAlign = horizontal, vertical
+	return i
+}
+
+// SetRotation 设置图片逆时针旋转的角度；须在 SetText 之前调用。
+func (i *MdImage) SetRotation(degrees float64) *MdImage {
+	i.rotation = degrees
+	return i
+}
+
+// SetText 加载路径：支持 http(s) 下载至临时文件或本地路径；首个参数为 URL / 路径，第二个参数为标题(可选)。
 func (i *MdImage) SetText(_ interface{}, filename ...string) {
+	if len(filename) > 1 {
+		i.parseOptions(filename[1])
+	}
+
 	var filepath string
 	if strings.HasPrefix(filename[0], "http") {
 		response, err := http.DefaultClient.Get(filename[0])
@@ -191,11 +279,15 @@ func (i *MdImage) SetText(_ interface{}, filename ...string) {
 		filepath = filename[0]
 	}
 
-	if i.height == 0 {
+	if i.width == 0 && i.height == 0 {
 		i.height = i.theme.bodyLineHeight()
 	}
 
-	i.image = NewImageWithWidthAndHeight(filepath, 0, i.height, i.pdf)
+	i.image = NewImageWithWidthAndHeight(filepath, i.width, i.height, i.pdf)
+	if i.fit != nil {
+		i.image.SetFit(*i.fit)
+	}
+	i.image.SetAlign(i.hAlign, i.vAlign).SetRotation(i.rotation)
 }
 
 // GenerateAtomicCell 委托底层 Image；加载失败时 image==nil 则跳过。
@@ -64,6 +64,7 @@ func (m *MdMutiText) getabstract(typ string) ElementBase {
 		Margin:                m.Margin,
 		Padding:               m.Padding,
 		theme:                 m.theme,
+		line:                  m.line,
 	}
 }
 
@@ -125,6 +126,8 @@ func (m *MdMutiText) SetToken(t Token) error {
 			mergeInlineBoxModel(m.theme.BoxForInlineToken(TYPE_DEL), &del.ElementBase)
 			del.SetText(m.fonts[FONT_NORMAL], token.Text)
 			m.children = append(m.children, del)
+		case TYPE_SUP, TYPE_SUB:
+			m.children = append(m.children, newMdScript(abs, m.theme, m.fonts[FONT_NORMAL], token))
 		}
 	}
 
@@ -194,7 +197,7 @@ func (h *MdHeader) SetToken(t Token) (err error) {
 	}
 
 	fontsize, lineheight := h.CalFontSizeAndLineHeight(t.Depth)
-	font := core.Font{Family: h.fonts[FONT_BOLD], Size: fontsize}
+	font := core.Font{Family: h.fonts[FONT_BOLD], Size: fontsize, Style: "B"}
 
 	absTop := h.getabstract(TYPE_BR)
 	topBrk := &MdHardBreak{ElementBase: absTop}
@@ -268,6 +271,7 @@ func (p *MdParagraph) getabstract(typ string) ElementBase {
 		Margin:                p.Margin,
 		Padding:               p.Padding,
 		theme:                 p.theme,
+		line:                  p.line,
 	}
 }
 
@@ -278,6 +282,9 @@ func (p *MdParagraph) SetToken(t Token) error {
 	if t.Type != TYPE_PARAGRAPH {
 		return fmt.Errorf("invalid type")
 	}
+	if p.theme.Justify {
+		p.line = &mdLine{pdf: p.pdf}
+	}
 
 	for _, token := range t.Tokens {
 		abs := p.getabstract(token.Type)
@@ -330,13 +337,15 @@ func (p *MdParagraph) SetToken(t Token) error {
 		case TYPE_IMAGE:
 			image := &MdImage{ElementBase: abs}
 			mergeInlineBoxModel(p.theme.BoxForInlineToken(TYPE_IMAGE), &image.ElementBase)
-			image.SetText("", token.Href)
+			image.SetText("", token.Href, token.Title)
 			p.children = append(p.children, image)
 		case TYPE_DEL:
 			del := &MdText{ElementBase: abs}
 			mergeInlineBoxModel(p.theme.BoxForInlineToken(TYPE_DEL), &del.ElementBase)
 			del.SetText(p.fonts[FONT_NORMAL], token.Text)
 			p.children = append(p.children, del)
+		case TYPE_SUP, TYPE_SUB:
+			p.children = append(p.children, newMdScript(abs, p.theme, p.fonts[FONT_NORMAL], token))
 		default:
 			// unknown inline token; skip
 		}
@@ -351,6 +360,9 @@ func (p *MdParagraph) GenerateAtomicCell() (pagebreak, over bool, err error) {
 		p.blockTopApplied = true
 	}
 	pagebreak, over, err = CommonGenerateAtomicCell(&p.children)
+	if p.line != nil {
+		p.line.flush(0) // 段落末行, 或分页前的最后一行
+	}
 	if err != nil || pagebreak {
 		return pagebreak, over, err
 	}
@@ -699,6 +711,8 @@ func (b *MdBlockQuote) SetToken(t Token) error {
 			mergeInlineBoxModel(b.theme.BoxForInlineToken(TYPE_DEL), &del.ElementBase)
 			del.SetText(b.fonts[FONT_NORMAL], token.Text)
 			b.children = append(b.children, del)
+		case TYPE_SUP, TYPE_SUB:
+			b.children = append(b.children, newMdScript(abs, b.theme, b.fonts[FONT_NORMAL], token))
 		}
 	}
 
@@ -783,7 +797,7 @@ func (tb *MdTable) SetToken(t Token) error {
 	table.SetMargin(core.Scope{})
 
 	border := core.NewScope(4.0, 4.0, 4.0, 3.0)
-	f := core.Font{Family: tb.fonts[FONT_BOLD], Size: int(tb.theme.bodyFontSize()), Style: ""}
+	f := core.Font{Family: tb.fonts[FONT_BOLD], Size: int(tb.theme.bodyFontSize()), Style: "B"}
 
 	// header row
 	for j, h := range header {
@@ -29,6 +29,8 @@ const (
 	TYPE_LINK     = "link"
 	TYPE_IMAGE    = "image"
 	TYPE_DEL      = "del"
+	TYPE_SUP      = "sup" // ^sup^, <sup>sup</sup>
+	TYPE_SUB      = "sub" // ~sub~, <sub>sub</sub>
 
 	TYPE_SPACE = "space"
 
@@ -115,7 +117,7 @@ func unorderedListBulletPrefix(nestLevel int) string {
 // listMarkerLeaderType 是否需要在本列表项首个内容 token 前绘制序号/项目符号。
 func listMarkerLeaderType(typ string) bool {
 	switch typ {
-	case TYPE_TEXT, TYPE_STRONG, TYPE_LINK, TYPE_EM, TYPE_CODESPAN, TYPE_DEL,
+	case TYPE_TEXT, TYPE_STRONG, TYPE_LINK, TYPE_EM, TYPE_CODESPAN, TYPE_DEL, TYPE_SUP, TYPE_SUB,
 		TYPE_LIST, TYPE_BLOCKQUOTE, TYPE_CODE:
 		return true
 	default:
@@ -19,7 +19,8 @@ type MarkdownText struct {
 	writedLines int
 }
 
-// NewMarkdownText 创建渲染器：fonts 须包含 FONT_NORMAL / FONT_BOLD / FONT_ITALIC；默认主题为 DefaultMarkdownTheme。
+// NewMarkdownText 创建渲染器：fonts 须包含 FONT_NORMAL，FONT_BOLD / FONT_ITALIC 可省略；默认主题为 DefaultMarkdownTheme。
+// 省略时粗体、斜体使用 FONT_NORMAL 字体族的 "B"/"I" 字形；字体族未注册对应字形时由 Converter 合成（描边加粗、倾斜）。
 // x 为允许的左侧起始坐标下界（小于页左边距时会被抬升到页起点）。
 func NewMarkdownText(pdf *core.Report, x float64, fonts map[string]string) (*MarkdownText, error) {
 	px, _ := pdf.GetPageStartXY()
@@ -27,9 +28,10 @@ func NewMarkdownText(pdf *core.Report, x float64, fonts map[string]string) (*Mar
 		x = px
 	}
 
-	if fonts == nil || fonts[FONT_BOLD] == "" || fonts[FONT_ITALIC] == "" || fonts[FONT_NORMAL] == "" {
+	if fonts == nil || fonts[FONT_NORMAL] == "" {
 		return nil, fmt.Errorf("invalid fonts")
 	}
+	fonts = familyStyleFonts(fonts)
 
 	mt := MarkdownText{
 		pdf:   pdf,
@@ -41,6 +43,23 @@ func NewMarkdownText(pdf *core.Report, x float64, fonts map[string]string) (*Mar
 	return &mt, nil
 }
 
+// familyStyleFonts 复制 fonts，并用 FONT_NORMAL 字体族补齐缺省的 FONT_BOLD / FONT_ITALIC
+// （字体族无 "B"/"I" 字形时由 Converter 合成）。
+func familyStyleFonts(fonts map[string]string) map[string]string {
+	out := make(map[string]string, len(fonts))
+	for k, v := range fonts {
+		out[k] = v
+	}
+	normal := fonts[FONT_NORMAL]
+	if out[FONT_BOLD] == "" {
+		out[FONT_BOLD] = normal
+	}
+	if out[FONT_ITALIC] == "" {
+		out[FONT_ITALIC] = normal
+	}
+	return out
+}
+
 // WithTheme 覆盖文档级 MarkdownTheme（按值拷贝）。
 func (mt *MarkdownText) WithTheme(t MarkdownTheme) *MarkdownText {
 	mt.theme = t
@@ -61,6 +80,7 @@ func (mt *MarkdownText) SetTokens(tokens []Token) {
 	for i := 0; i < len(tokens); i++ {
 		token := tokens[i]
 		abs := mt.getabstract(token.Type)
+		abs.theme.Direction = core.ResolveDirection(mt.theme.Direction, token.Raw)
 
 		if token.Type == TYPE_SPACE && i+1 < len(tokens) && tokens[i+1].Type == TYPE_HR {
 			continue
@@ -140,6 +160,8 @@ func (mt *MarkdownText) SetTokens(tokens []Token) {
 			mergeInlineBoxModel(mt.theme.BoxForInlineToken(TYPE_DEL), &del.ElementBase)
 			del.SetText(mt.fonts[FONT_NORMAL], token.Text)
 			mt.children = append(mt.children, del)
+		case TYPE_SUP, TYPE_SUB:
+			mt.children = append(mt.children, newMdScript(abs, mt.theme, mt.fonts[FONT_NORMAL], token))
 		case TYPE_TABLE:
 			tb := &MdTable{ElementBase: abs, fonts: mt.fonts, blockBox: mt.theme.BoxTable}
 			tb.SetToken(token)
@@ -1,6 +1,10 @@
 package gopdf
 
-import "github.com/tiechui1994/gopdf/core"
+import (
+	"math"
+
+	"github.com/tiechui1994/gopdf/core"
+)
 
 // markdown_layout.go：复合结点子结点队列上的分页与切片策略（CommonGenerateAtomicCell）。
 
@@ -73,3 +77,64 @@ func CommonGenerateAtomicCell(children *[]markdownNode) (pagebreak, over bool, e
 	}
 	return false, true, nil
 }
+
+// mdLine 两端对齐段落中正在排版的一行：MdText 片段先登记到行内，行在折行处结束时按整行剩余宽度
+// 分摊词间（或中日文字间）间距后统一绘制；源文本换行、硬换行与段落末行不对齐。由 MdParagraph 创建并共享给其行内结点。
+type mdLine struct {
+	pdf    *core.Report
+	y      float64
+	pieces []mdLinePiece
+}
+
+// mdLinePiece 行内的一个片段：x 为未对齐时的左缘（逻辑坐标，RTL 由 draw 镜像），draw 在 x 处按宽度 width 绘制。
+type mdLinePiece struct {
+	x, width float64
+	text     string
+	draw     func(x, width float64)
+}
+
+// add 登记基线 y 上的片段；基线变化（硬换行、分页后）时先按不对齐写出上一行。
+func (l *mdLine) add(x, y, width float64, text string, draw func(x, width float64)) {
+	if len(l.pieces) > 0 && math.Abs(l.y-y) > 0.01 {
+		l.flush(0)
+	}
+	l.y = y
+	l.pieces = append(l.pieces, mdLinePiece{x: x, width: width, text: text, draw: draw})
+}
+
+// flush 写出行内片段；right > 0 时将行自首个片段左缘撑满到 right。
+func (l *mdLine) flush(right float64) {
+	if len(l.pieces) == 0 {
+		return
+	}
+	pieces := l.pieces
+	l.pieces = nil
+
+	texts := make([]string, len(pieces))
+	extra := right - pieces[0].x
+	for i, piece := range pieces {
+		texts[i] = piece.text
+		extra -= piece.width
+	}
+	if right <= 0 {
+		extra = 0
+	}
+	word, char, shifts, grows := core.JustifyLine(texts, extra)
+	if word != 0 {
+		l.pdf.WordSpacing(word)
+	}
+	if char != 0 {
+		l.pdf.CharSpacing(char)
+	}
+	x, y := l.pdf.GetXY() // 写入文本会移动光标, 排版位置保持不变
+	for i, piece := range pieces {
+		piece.draw(piece.x+shifts[i], piece.width+grows[i])
+	}
+	l.pdf.SetXY(x, y)
+	if word != 0 {
+		l.pdf.WordSpacing(0)
+	}
+	if char != 0 {
+		l.pdf.CharSpacing(0)
+	}
+}
@@ -2,10 +2,14 @@ package gopdf
 
 import (
 	"bytes"
+	"compress/zlib"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/tiechui1994/gopdf/core"
@@ -99,6 +103,161 @@ func TestMarkdownComplex(t *testing.T) {
 	MarkdownReportComplex()
 }
 
+// pdfContent returns the inflated content of all compressed streams of pdf.
+func pdfContent(pdf []byte) string {
+	var content strings.Builder
+	for {
+		start := bytes.Index(pdf, []byte("stream\n"))
+		if start < 0 {
+			break
+		}
+		pdf = pdf[start+len("stream\n"):]
+		end := bytes.Index(pdf, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		if zr, err := zlib.NewReader(bytes.NewReader(pdf[:end])); err == nil {
+			data, _ := ioutil.ReadAll(zr)
+			content.Write(data)
+		}
+		pdf = pdf[end+len("endstream"):]
+	}
+	return content.String()
+}
+
+func TestMarkdownSyntheticStyles(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *core.Report) {
+		md, err := NewMarkdownText(report, 0, map[string]string{FONT_NORMAL: core.FontSansBold, FONT_MONO: core.FontSans})
+		if err != nil {
+			t.Fatal(err)
+		}
+		md.SetTokens(lex.NewLex().Lex("plain **strong** and *emphasis*\n"))
+		md.GenerateAtomicCell()
+	}, core.Detail)
+
+	pdf, err := r.GetBytesPdf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := pdfContent(pdf)
+	if strings.Contains(content, " Tr") {
+		t.Error("bold face must not be emboldened again")
+	}
+	if !strings.Contains(content, "1 0 0.2126 1 ") {
+		t.Error("missing synthetic oblique for *emphasis*")
+	}
+}
+
+func TestMarkdownDirection(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *core.Report) {
+		md, err := NewMarkdownText(report, 0, map[string]string{FONT_NORMAL: core.FontSans})
+		if err != nil {
+			t.Fatal(err)
+		}
+		theme := DefaultMarkdownTheme()
+		theme.Direction = core.DirectionRTL
+		md.WithTheme(theme)
+		md.SetTokens(lex.NewLex().Lex("first **second** third\n"))
+		md.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		texts []string
+		xs    []float64
+		rtl   bool
+	)
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		switch elements[0] {
+		case "TD":
+			rtl = elements[1] == "rtl"
+		case "CL":
+			if !rtl {
+				t.Errorf("%q written outside the rtl paragraph", elements[3])
+			}
+			x, _ := strconv.ParseFloat(elements[1], 64)
+			texts = append(texts, strings.TrimSpace(elements[3]))
+			xs = append(xs, x)
+		}
+	}
+	if len(xs) < 3 || !(xs[0] > xs[1] && xs[1] > xs[2]) {
+		t.Errorf("fragments %q are not laid out right to left: %v", texts, xs)
+	}
+}
+
+// 列表项的 RTL 段落以文本块 (悬挂缩进之后) 的左右边缘为轴镜像: 首个片段的右缘与栏的右缘对齐, 片段不越过缩进
+func TestMarkdownDirectionBlock(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	type fragment struct {
+		text        string
+		left, right float64
+	}
+	var (
+		fragments    []fragment
+		startX, endX float64
+		measure      func(text string, font core.Font) float64
+	)
+	r.RegisterExecutor(func(report *core.Report) {
+		startX, _ = report.GetPageStartXY()
+		endX, _ = report.GetPageEndXY()
+		measure = report.MeasureTextWidthWithFont
+		md, err := NewMarkdownText(report, 0, map[string]string{FONT_NORMAL: core.FontSans})
+		if err != nil {
+			t.Fatal(err)
+		}
+		theme := DefaultMarkdownTheme()
+		theme.Direction = core.DirectionRTL
+		md.WithTheme(theme)
+		md.SetTokens(lex.NewLex().Lex("- first **second** third\n"))
+		md.GenerateAtomicCell()
+	}, core.Detail)
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+
+	var font core.Font
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		switch elements[0] {
+		case "F":
+			size, _ := strconv.Atoi(elements[3])
+			font = core.Font{Family: elements[1], Style: elements[2], Size: size}
+		case "CL":
+			if strings.TrimSpace(elements[3]) == "" || strings.ContainsAny(elements[3], "•·●") {
+				continue
+			}
+			x, _ := strconv.ParseFloat(elements[1], 64)
+			fragments = append(fragments, fragment{elements[3], x, x + measure(elements[3], font)})
+		}
+	}
+	if len(fragments) < 3 {
+		t.Fatalf("fragments %v", fragments)
+	}
+	if math.Abs(fragments[0].right-endX) > 0.5 {
+		t.Errorf("first fragment %q ends at %.2f, want the right edge %.2f", fragments[0].text, fragments[0].right, endX)
+	}
+	for _, f := range fragments {
+		if f.left < startX+1 || f.right > endX+0.5 {
+			t.Errorf("fragment %q at %.2f..%.2f outside the list item %.2f..%.2f", f.text, f.left, f.right, startX, endX)
+		}
+	}
+}
+
 func TestTokens(t *testing.T) {
 	data, _ := ioutil.ReadFile("./markdown/src/mark.json")
 	var list []Token
@@ -125,3 +284,136 @@ func TestDrawPNG(t *testing.T) {
 	DrawSunLine("./sunline.png")
 	DrawFiveCycle("./fivecycle.png")
 }
+
+func TestMarkdownJustify(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *core.Report) {
+		md, err := NewMarkdownText(report, 0, map[string]string{FONT_NORMAL: core.FontSans, FONT_BOLD: core.FontSans})
+		if err != nil {
+			t.Fatal(err)
+		}
+		theme := DefaultMarkdownTheme()
+		theme.Justify = true
+		md.WithTheme(theme)
+		md.SetTokens(lex.NewLex().Lex(strings.Repeat("lorem ipsum dolor sit amet **bold words** and ", 8) + "end.\n"))
+		md.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+	pageEndX, _ := r.GetPageEndXY()
+
+	var (
+		word      float64
+		lines     int
+		lastRight float64
+	)
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		switch elements[0] {
+		case "F":
+			size, _ := strconv.Atoi(elements[3])
+			r.SetFontWithStyle(elements[1], elements[2], size)
+		case "WS":
+			v, _ := strconv.ParseFloat(elements[1], 64)
+			if v == 0 && word != 0 {
+				lines++
+				if math.Abs(lastRight-pageEndX) > 0.1 {
+					t.Errorf("justified line ends at %v, want %v", lastRight, pageEndX)
+				}
+			}
+			word = v
+		case "CL":
+			x, _ := strconv.ParseFloat(elements[1], 64)
+			text := elements[3]
+			lastRight = x + r.MeasureTextWidth(text) + word*float64(strings.Count(strings.Trim(text, " "), " "))
+			if strings.HasSuffix(text, "end.") && word != 0 {
+				t.Errorf("last line of the paragraph is justified")
+			}
+		}
+	}
+	if lines < 3 {
+		t.Errorf("expected justified lines, got %d", lines)
+	}
+}
+
+func TestMarkdownScript(t *testing.T) {
+	r := core.CreateReport()
+	if err := r.SetPage("A4", "P"); err != nil {
+		t.Fatal(err)
+	}
+	r.RegisterExecutor(func(report *core.Report) {
+		md, err := NewMarkdownText(report, 0, map[string]string{FONT_NORMAL: core.FontSans, FONT_BOLD: core.FontSans})
+		if err != nil {
+			t.Fatal(err)
+		}
+		md.SetTokens(lex.NewLex().Lex("H~2~O covers 70% of the earth, 5 m^3^ and x<sub>i</sub>.\n"))
+		md.GenerateAtomicCell()
+	}, core.Detail)
+
+	if _, err := r.GetBytesPdf(); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		size   int
+		shift  float64
+		shifts = map[string]float64{} // 文本 => 基线偏移
+		sizes  = map[string]int{}
+	)
+	for _, cell := range *r.GetAtomicCells() {
+		elements := strings.Split(cell, "|")
+		switch elements[0] {
+		case "F":
+			size, _ = strconv.Atoi(elements[3])
+		case "BS":
+			shift, _ = strconv.ParseFloat(elements[1], 64)
+		case "CL":
+			shifts[elements[3]] = shift
+			sizes[elements[3]] = size
+		}
+	}
+	for text, want := range map[string]int{"3": 1, "2": -1, "i": -1, "H": 0} {
+		if got := shifts[text]; want > 0 && got <= 0 || want < 0 && got >= 0 || want == 0 && got != 0 {
+			t.Errorf("text %q written with baseline shift %v", text, got)
+		}
+	}
+	if sizes["2"] >= sizes["H"] || sizes["3"] >= sizes["H"] {
+		t.Errorf("scripts written in size %d and %d, text in size %d", sizes["2"], sizes["3"], sizes["H"])
+	}
+}
+
+func TestMarkdownImageOptions(t *testing.T) {
+	_, records := runReport(t, func(report *core.Report) {
+		md, err := NewMarkdownText(report, 0, map[string]string{FONT_NORMAL: core.FontSans, FONT_BOLD: core.FontSans})
+		if err != nil {
+			t.Fatal(err)
+		}
+		md.SetTokens(lex.NewLex().Lex(`![cat](example/pictures/cat.jpg "width=120 height=60 fit=cover align=center")` + "\n"))
+		md.GenerateAtomicCell()
+	})
+
+	var clip []float64
+	for _, r := range records {
+		if r[0] == "TK" {
+			clip = []float64{r.float(t, 1), r.float(t, 2), r.float(t, 3), r.float(t, 4)}
+		}
+	}
+	if len(clip) != 4 || clip[2] != 120 || clip[3] != 60 {
+		t.Fatalf("clip %v, want a 120x60 box", clip)
+	}
+	// cover: 图片铺满 120x60 的框, 在框内水平居中
+	images := placedImages(t, records)
+	if len(images) != 1 {
+		t.Fatalf("images %v, want 1", images)
+	}
+	image := images[0]
+	if math.Abs(image.x1+image.x2-2*clip[0]-120) > 0.01 || image.x1 > clip[0] || image.x2 < clip[0]+120 ||
+		image.y1 > clip[1] || image.y2 < clip[1]+60 {
+		t.Errorf("image %v does not cover the box %v", image, clip)
+	}
+}
@@ -27,12 +27,23 @@ type MdText struct {
 	remain    string
 	link      string
 	newlines  int
+	wrapped   bool // 最近一次 GetSubText 是否因行宽不足在片段中间折行（源文本换行与片段写完均不算）
 
 	offsetx float64
 	offsety float64
+	shift   float64 // 上标/下标基线的偏移, 正值上移
+}
+
+// newMdScript 由 sup/sub token 创建上标/下标片段: 继承 abs 的公共属性, 合并主题中该类型的盒模型, 字体族为 family。
+func newMdScript(abs ElementBase, theme MarkdownTheme, family string, token Token) *MdText {
+	script := &MdText{ElementBase: abs}
+	mergeInlineBoxModel(theme.BoxForInlineToken(token.Type), &script.ElementBase)
+	script.SetText(family, token.Text)
+	return script
 }
 
 // SetText 设置字型与测量基准：font 可为字体族 string 或 core.Font；LINK 需 texts[1]=href。
+// font 为 string 时 STRONG/EM 分别请求 "B"/"I" 字形，字体族未注册该字形时由 core 退回常规字形。
 func (c *MdText) SetText(font interface{}, texts ...string) {
 	if len(texts) == 0 {
 		panic("text is invalid")
@@ -45,14 +56,14 @@ func (c *MdText) SetText(font interface{}, texts ...string) {
 		family := font.(string)
 		switch c.Type {
 		case TYPE_STRONG:
-			c.font = core.Font{Family: family, Size: fs, Style: ""}
+			c.font = core.Font{Family: family, Size: fs, Style: "B"}
 		case TYPE_EM:
-			c.font = core.Font{Family: family, Size: fs, Style: ""}
+			c.font = core.Font{Family: family, Size: fs, Style: "I"}
 		case TYPE_CODESPAN, TYPE_CODE:
 			c.font = core.Font{Family: family, Size: fs, Style: ""}
 		case TYPE_LINK, TYPE_TEXT:
 			c.font = core.Font{Family: family, Size: fs, Style: ""}
-		case TYPE_DEL:
+		case TYPE_DEL, TYPE_SUP, TYPE_SUB:
 			c.font = core.Font{Family: family, Size: fs, Style: ""}
 		}
 	case core.Font:
@@ -61,6 +72,13 @@ func (c *MdText) SetText(font interface{}, texts ...string) {
 		panic(fmt.Sprintf("invalid type: %v", c.Type))
 	}
 
+	// 上标/下标: 按字体的 OS/2 度量缩小字号, 写入时偏移基线
+	if script := mdScript(c.Type); script != core.ScriptBaseline {
+		size, shift := c.pdf.GetScriptMetrics(c.font.Family, c.font.Style, float64(c.font.Size), script)
+		c.font.Size = int(math.Max(1, math.Round(size)))
+		c.shift = shift
+	}
+
 	text := strings.Replace(texts[0], "\t", "    ", -1)
 	c.text = repairText(c.Type, text)
 	c.remain = c.text
@@ -157,13 +175,30 @@ func (c *MdText) GenerateAtomicCell() (pagebreak, over bool, err error) {
 	}
 	text, width, newline := c.GetSubText(tl, tr)
 
+	// RTL 段落: 片段仍按逻辑顺序从左向右排版, 绘制时以文本块的左右边缘 (续行的左缘与 pageEndXEff) 为轴镜像,
+	// 逻辑上的第一个片段位于行的右端
+	rtl := c.theme.Direction == core.DirectionRTL && c.Type != TYPE_CODE
+	blockL := pageStartX + c.hangingIndentPt + hPadL
+	mirror := func(x, w float64) float64 {
+		return blockL + pageEndXEff - x - w
+	}
+	dirs := directionWriter{pdf: c.pdf}
+	defer dirs.reset()
+
 	for !c.stoped {
 		x1 := flowX + hPadL
 		colLeft := flowX
+		drawX := x1
+		if rtl {
+			drawX = mirror(x1, width)
+		}
+		if c.Type != TYPE_CODE && c.line == nil {
+			dirs.line(c.theme.Direction, text)
+		}
 
 		c.noteLayoutStart(x1, y)
 
-		asc, desc := c.pdf.GetFontMetrics(c.font.Family, float64(c.font.Size))
+		asc, desc := c.pdf.GetFontMetricsWithStyle(c.font.Family, c.font.Style, float64(c.font.Size))
 		inlinePad := mdScale(0.35 / 18.0)
 		emH := asc - desc
 		if emH < 1 {
@@ -200,15 +235,9 @@ func (c *MdText) GenerateAtomicCell() (pagebreak, over bool, err error) {
 			}
 		}
 
-		switch c.Type {
-		case TYPE_CODESPAN:
-			bgTop := y - asc - inlinePad
-			bgH := math.Max(emH+2*inlinePad, lineheight-mdScale(0.5/18.0))
-			c.pdf.BackgroundColor(x1, bgTop, width, bgH, color_lightgray, "1111", color_whitesmoke)
-			c.pdf.TextColor(util.RGB(color_pink))
-			c.pdf.Cell(x1, y, text)
-			c.pdf.TextColor(util.RGB(color_black))
-		case TYPE_CODE:
+		// 行中剩余宽度放不下下一个词时 text 为空：本行不绘制，换行后继续（代码块空行仍绘制背景）
+		switch {
+		case c.Type == TYPE_CODE:
 			codePad := codeBlockPad()
 			bgH := emH + 2*codePad
 			if bgH < lineheight+2*codePad {
@@ -233,24 +262,31 @@ func (c *MdText) GenerateAtomicCell() (pagebreak, over bool, err error) {
 			c.pdf.TextColor(util.RGB(color_black))
 			c.pdf.Cell(x1+codePad, y, text)
 			c.pdf.TextColor(util.RGB(color_black))
-
-		case TYPE_LINK:
-			c.pdf.TextColor(util.RGB(color_blue))
-			c.pdf.ExternalLink(x1, y, lineheight, text, c.link)
-			c.pdf.TextColor(util.RGB(color_black))
-		case TYPE_DEL:
-			dAsc, _ := c.pdf.GetFontMetrics(c.font.Family, float64(c.font.Size))
-			if dAsc < 1 {
-				dAsc = lineheight * 0.38
-			}
-			strikeY := y - dAsc*0.28
-			c.pdf.TextColor(util.RGB(color_gray))
-			c.pdf.Cell(x1, y, text)
-			c.pdf.LineType("straight", 0.3)
-			c.pdf.LineH(x1, strikeY, x1+width)
-			c.pdf.TextColor(util.RGB(color_black))
+		case text == "":
+		case c.line != nil:
+			// 两端对齐的段落：片段登记到行内，行结束时按对齐后的位置与宽度绘制
+			lineText, lineY := text, y
+			c.line.add(x1, y, width, text, func(x, w float64) {
+				drawX := x
+				if rtl {
+					drawX = mirror(x, w)
+				}
+				c.pdf.Font(c.font.Family, c.font.Size, c.font.Style)
+				c.pdf.SetFontWithStyle(c.font.Family, c.font.Style, c.font.Size)
+				dirs := directionWriter{pdf: c.pdf}
+				dirs.line(c.theme.Direction, lineText)
+				c.drawInline(drawX, lineY, w, lineText, lineheight)
+				dirs.reset()
+			})
 		default:
-			c.pdf.Cell(x1+c.offsetx, y+c.offsety, text)
+			c.drawInline(drawX, y, width, text, lineheight)
+		}
+		if c.line != nil && newline {
+			right := 0.0 // 源文本换行：不对齐
+			if c.wrapped {
+				right = pageEndXEff
+			}
+			c.line.flush(right)
 		}
 
 		c.noteLayoutExtent(x1+width, y)
@@ -289,9 +325,63 @@ func (c *MdText) GenerateAtomicCell() (pagebreak, over bool, err error) {
 	return false, c.stoped, nil
 }
 
+// drawInline 在 drawX 处绘制行内片段（非代码块）的一行 text，width 为其宽度：按类型绘制背景、颜色、链接或删除线。
+func (c *MdText) drawInline(drawX, y, width float64, text string, lineheight float64) {
+	asc, desc := c.pdf.GetFontMetricsWithStyle(c.font.Family, c.font.Style, float64(c.font.Size))
+	inlinePad := mdScale(0.35 / 18.0)
+	emH := asc - desc
+	if emH < 1 {
+		emH = mdBase * 1.2
+	}
+
+	switch c.Type {
+	case TYPE_CODESPAN:
+		bgTop := y - asc - inlinePad
+		bgH := math.Max(emH+2*inlinePad, lineheight-mdScale(0.5/18.0))
+		c.pdf.BackgroundColor(drawX, bgTop, width, bgH, color_lightgray, "1111", color_whitesmoke)
+		c.pdf.TextColor(util.RGB(color_pink))
+		c.pdf.Cell(drawX, y, text)
+		c.pdf.TextColor(util.RGB(color_black))
+	case TYPE_LINK:
+		// 链接带下划线, 位置与粗细取自字体
+		c.pdf.Font(c.font.Family, c.font.Size, c.font.Style+core.FontStyleUnderline)
+		c.pdf.TextColor(util.RGB(color_blue))
+		c.pdf.ExternalLink(drawX, y, lineheight, text, c.link)
+		c.pdf.TextColor(util.RGB(color_black))
+		c.pdf.Font(c.font.Family, c.font.Size, c.font.Style)
+	case TYPE_DEL:
+		c.pdf.Font(c.font.Family, c.font.Size, c.font.Style+core.FontStyleStrike)
+		c.pdf.TextColor(util.RGB(color_gray))
+		c.pdf.Cell(drawX, y, text)
+		c.pdf.TextColor(util.RGB(color_black))
+		c.pdf.Font(c.font.Family, c.font.Size, c.font.Style)
+	default:
+		if c.shift != 0 {
+			c.pdf.BaselineShift(c.shift)
+		}
+		c.pdf.Cell(drawX+c.offsetx, y+c.offsety, text)
+		if c.shift != 0 {
+			c.pdf.BaselineShift(0)
+		}
+	}
+}
+
+// mdScript 返回 token 类型对应的上标/下标位置
+func mdScript(typ string) core.Script {
+	switch typ {
+	case TYPE_SUP:
+		return core.ScriptSuper
+	case TYPE_SUB:
+		return core.ScriptSub
+	default:
+		return core.ScriptBaseline
+	}
+}
+
 // GetSubText 在 [x1,x2] 可用宽度内取下一段可见文本；必要时按词或按字折断；更新 remain。
 // x1/x2 已为扣除 Margin/Padding 后的内缘坐标；needpadding 分支仍按 flowColumnOffsetPt 在「逻辑行首」补空格。
 func (c *MdText) GetSubText(x1, x2 float64) (text string, width float64, newline bool) {
+	c.wrapped = false
 	if len(c.remain) == 0 {
 		c.stoped = true
 		return "", 0, false
@@ -350,24 +440,22 @@ func (c *MdText) GetSubText(x1, x2 float64) (text string, width float64, newline
 		return line, wline, true
 	}
 
-	maxFit := c.fitPrefixRuneCount(runes, width, c.precision)
-	if maxFit < 1 {
-		maxFit = 1
+	// 按断行机会（UAX #14 与避头尾）取可放下的最长前缀，主题设置了断词时可在单词内断开并补连字符；
+	// 行首放不下一个词时按字素簇折断，行中放不下时本行不取文本（cut 为 0），整体移到下一行。
+	hyphenation := c.theme.Hyphenation
+	if c.Type == TYPE_CODESPAN {
+		hyphenation = core.Hyphenation{}
 	}
-	line, wline, cut := c.applyWordAwareSlice(runes, 0, maxFit, width)
-	if line == "" && len(runes) > 0 {
-		line = string(runes[:1])
-		wline = c.pdf.MeasureTextWidth(line)
-		cut = 1
-	}
-	if cut <= 0 && len(runes) > 0 {
-		line = string(runes[:1])
-		wline = c.pdf.MeasureTextWidth(line)
-		cut = 1
+	atLineLeft := atMarkdownLineLeft(x1-hL, pageX, c.hangingIndentPt)
+	cut, hyphen := core.FitLineWithHyphenation(runes, width+c.precision, c.pdf.MeasureTextWidth, atLineLeft, hyphenation)
+	line := strings.TrimRight(string(runes[:cut]), " ")
+	if hyphen {
+		line += "-"
 	}
 	c.remain = string(runes[cut:]) + suffix
 	c.newlines++
-	return line, wline, true
+	c.wrapped = cut < len(runes)
+	return line, c.pdf.MeasureTextWidth(line), true
 }
 
 // codeFittedRuneIndex 对 TYPE_CODE：按测量宽度二分，取最长前缀 rune 数（不按词断开）。
@@ -403,33 +491,3 @@ func (c *MdText) fitPrefixRuneCount(runes []rune, avail, eps float64) int {
 	}
 	return lo
 }
-
-// applyWordAwareSlice 在 runes[i:end] 内优先在空格处断开，避免英文单词从中劈开。
-func (c *MdText) applyWordAwareSlice(runes []rune, i, end int, avail float64) (line string, w float64, cut int) {
-	cut = end
-	if end <= i || end > len(runes) {
-		return "", 0, i
-	}
-	if end < len(runes) && runes[end-1] != ' ' {
-		lastSpace := -1
-		for k := end - 1; k > i; k-- {
-			if runes[k] == ' ' {
-				lastSpace = k
-				break
-			}
-		}
-		if lastSpace > i {
-			cand := string(runes[i:lastSpace])
-			cw := c.pdf.MeasureTextWidth(cand)
-			if cw <= avail+c.precision && len(cand) > 0 {
-				k := lastSpace + 1
-				for k < len(runes) && runes[k] == ' ' {
-					k++
-				}
-				return cand, cw, k
-			}
-		}
-	}
-	seg := string(runes[i:end])
-	return seg, c.pdf.MeasureTextWidth(seg), end
-}
@@ -38,6 +38,13 @@ type MarkdownTheme struct {
 	LineHeight   float64 // pt，单行步进
 	BreakGap     float64 // pt，类似段间距的垂直空隙
 
+	// Direction 段落方向；DirectionAuto（默认）时每个块按其首个强方向字符决定。RTL 块的行内片段自右向左排列（右对齐）。
+	Direction core.TextDirection
+	// Hyphenation 正文断词设置（语言、断词点两侧最少字符数）；Language 为空时不断词，行内代码始终不断词。
+	Hyphenation core.Hyphenation
+	// Justify 段落正文两端对齐：折行处的行撑满行宽（行尾片段的剩余宽度分摊到词间或中日文字间），段落末行与硬换行前的行不对齐。
+	Justify bool
+
 	BoxParagraph  MdBoxModel
 	BoxHeading    MdBoxModel
 	BoxList       MdBoxModel
@@ -134,6 +141,8 @@ type ElementBase struct {
 
 	theme MarkdownTheme // 文档级主题：正文字号/行距/段间距及各类结点的默认盒模型
 
+	line *mdLine // 两端对齐段落共享的当前行：由 MdParagraph 按 theme.Justify 创建并传给其行内结点，nil 时不对齐（标题、列表等）
+
 	// StartPt/EndPt：本次 GenerateAtomicCell 调用中，本结点（或叶子）绘制范围的近似轴对齐矩形（左上角 StartPt，右下角 EndPt 含义为最大触及的 X/Y）
 	StartPt, EndPt      mdPoint
 	extentStartRecorded bool // 本轮 GenerateAtomicCell 是否已写入包围盒起点 StartPt（noteLayoutStart / noteLayoutExtent 使用）
@@ -241,7 +250,7 @@ func repairText(TYPE, text string) string {
 	switch TYPE {
 	case TYPE_CODE:
 		return text
-	case TYPE_TEXT, TYPE_STRONG, TYPE_EM, TYPE_CODESPAN, TYPE_LINK, TYPE_DEL:
+	case TYPE_TEXT, TYPE_STRONG, TYPE_EM, TYPE_CODESPAN, TYPE_LINK, TYPE_DEL, TYPE_SUP, TYPE_SUB:
 		return text
 	default:
 		return text
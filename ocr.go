@@ -0,0 +1,218 @@
+package gopdf
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tiechui1994/gopdf/core"
+)
+
+// 可搜索的扫描页: 扫描的图片铺满整页, OCR 识别的每个词以不可见的文本(TextRenderInvisible)覆盖在图片中
+// 该词的位置, 缩放到词的框的大小. 文本不可见, 但可以选择, 复制与搜索. OCR 的结果可以是 hOCR
+// (ocr_page 与 ocrx_word 的 bbox) 或 JSON.
+
+// OCRWord OCR 识别的一个词
+type OCRWord struct {
+	Text string     `json:"text"`
+	BBox [4]float64 `json:"bbox"` // 词的框 x0, y0, x1, y1, 图片的像素坐标, 原点在左上角
+}
+
+// OCRPage OCR 识别的一页. JSON 格式: {"width": 2480, "height": 3508, "words": [{"text": "...", "bbox": [x0, y0, x1, y1]}]}
+type OCRPage struct {
+	Width  float64   `json:"width"` // 识别的图片的像素宽高
+	Height float64   `json:"height"`
+	Words  []OCRWord `json:"words"`
+}
+
+// ocrFontSize 写入词时使用的字号, 之后缩放到词的框的大小
+const ocrFontSize = 10
+
+// 解析 JSON 格式的 OCR 结果, 一页(对象)或多页(数组)
+func ParseOCRJSON(reader io.Reader) ([]OCRPage, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var pages []OCRPage
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &pages)
+	} else {
+		var page OCRPage
+		err = json.Unmarshal(data, &page)
+		pages = []OCRPage{page}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+var (
+	hocrTag   = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*?)(/?)>`)
+	hocrClass = regexp.MustCompile(`class\s*=\s*(?:'([^']*)'|"([^"]*)")`)
+	hocrTitle = regexp.MustCompile(`title\s*=\s*(?:'([^']*)'|"([^"]*)")`)
+	hocrBBox  = regexp.MustCompile(`bbox\s+(-?\d+)\s+(-?\d+)\s+(-?\d+)\s+(-?\d+)`)
+)
+
+// 解析 hOCR 格式的 OCR 结果: 每个 ocr_page 为一页, 页的 bbox 为图片的大小; 每个 ocrx_word 为一个词
+func ParseHOCR(reader io.Reader) ([]OCRPage, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	doc := string(data)
+
+	var (
+		pages []OCRPage
+		word  *OCRWord
+		text  strings.Builder
+		depth int // 词内嵌套的元素的层数
+		last  int // 上一个标签的结束位置
+	)
+	for _, match := range hocrTag.FindAllStringSubmatchIndex(doc, -1) {
+		if word != nil {
+			text.WriteString(doc[last:match[0]])
+		}
+		last = match[1]
+
+		closing, selfClosing := doc[match[2]:match[3]] == "/", doc[match[8]:match[9]] == "/"
+		if word != nil {
+			switch {
+			case closing && depth == 0:
+				word.Text = strings.TrimSpace(html.UnescapeString(text.String()))
+				if word.Text != "" && len(pages) > 0 {
+					pages[len(pages)-1].Words = append(pages[len(pages)-1].Words, *word)
+				}
+				word = nil
+			case closing:
+				depth--
+			case !selfClosing:
+				depth++
+			}
+			continue
+		}
+		if closing {
+			continue
+		}
+
+		attributes := doc[match[6]:match[7]]
+		class := hocrClass.FindStringSubmatch(attributes)
+		if class == nil {
+			continue
+		}
+		bbox, err := parseHOCRBBox(attributes)
+		classes := strings.Fields(class[1] + class[2])
+		switch {
+		case contains(classes, "ocr_page"):
+			if err != nil {
+				return nil, fmt.Errorf("ocr_page without bbox: %w", err)
+			}
+			pages = append(pages, OCRPage{Width: bbox[2] - bbox[0], Height: bbox[3] - bbox[1]})
+		case contains(classes, "ocrx_word") && !selfClosing:
+			if err != nil {
+				return nil, fmt.Errorf("ocrx_word without bbox: %w", err)
+			}
+			word, depth = &OCRWord{BBox: bbox}, 0
+			text.Reset()
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("hocr without ocr_page")
+	}
+	return pages, nil
+}
+
+// parseHOCRBBox 解析 hOCR 元素的 title 属性中的 bbox
+func parseHOCRBBox(attributes string) ([4]float64, error) {
+	var bbox [4]float64
+	title := hocrTitle.FindStringSubmatch(attributes)
+	if title == nil {
+		return bbox, fmt.Errorf("no title in %q", attributes)
+	}
+	match := hocrBBox.FindStringSubmatch(title[1] + title[2])
+	if match == nil {
+		return bbox, fmt.Errorf("no bbox in %q", attributes)
+	}
+	for i := range bbox {
+		v, err := strconv.ParseFloat(match[i+1], 64)
+		if err != nil {
+			return bbox, err
+		}
+		bbox[i] = v
+	}
+	return bbox, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchablePage 可搜索的扫描页: 图片在整页(不留页边距)内等比缩放, 居中放置, OCR 识别的词以不可见的文本覆盖.
+// 词使用当前的字体写入
+type SearchablePage struct {
+	pdf   *core.Report
+	image *Image
+	page  OCRPage
+}
+
+func NewSearchablePage(image *Image, page OCRPage, pdf *core.Report) *SearchablePage {
+	return &SearchablePage{pdf: pdf, image: image, page: page}
+}
+
+func (s *SearchablePage) GenerateAtomicCell() (pagebreak, over bool, err error) {
+	if s.image.err != nil {
+		return false, false, s.image.err
+	}
+	if s.page.Width <= 0 || s.page.Height <= 0 {
+		return false, false, fmt.Errorf("ocr page without the size of the image")
+	}
+	font := s.pdf.GetCurrentFont()
+	if font.Family == "" {
+		return false, false, fmt.Errorf("searchable page needs a font for the text layer")
+	}
+
+	// 图片铺满整页
+	image := s.image
+	image.boxWidth, image.boxHeight = s.pdf.GetPageWidthAndHeight()
+	image.SetFit(FitContain).SetAlign(AlignCenter, AlignMiddle)
+	image.draw(0, 0)
+
+	// 词的框从图片的像素坐标换算成页面的坐标
+	rx, ry, rw, rh := image.placement(0, 0)
+	scaleX, scaleY := rw/s.page.Width, rh/s.page.Height
+
+	s.pdf.SetFontWithStyle(font.Family, font.Style, ocrFontSize)
+	ascender, descender := s.pdf.GetFontMetricsWithStyle(font.Family, font.Style, ocrFontSize)
+	s.pdf.TextRenderMode(core.TextRenderInvisible)
+	for _, word := range s.page.Words {
+		width := s.pdf.MeasureTextWidth(word.Text)
+		if word.Text == "" || width <= 0 {
+			continue
+		}
+		x, bottom := rx+word.BBox[0]*scaleX, ry+word.BBox[3]*scaleY
+		w, h := (word.BBox[2]-word.BBox[0])*scaleX, (word.BBox[3]-word.BBox[1])*scaleY
+		if w <= 0 || h <= 0 {
+			continue
+		}
+
+		// 以框的左下角缩放, 使文本的宽度与高度(上伸部到下伸部)等于框的宽高
+		s.pdf.PushTransform(core.ScaleMatrix(w/width, h/(ascender-descender)).Around(x, bottom))
+		s.pdf.Cell(x, bottom+descender, word.Text)
+		s.pdf.PopTransform()
+	}
+	s.pdf.TextRenderMode(core.TextRenderFill)
+	s.pdf.SetFontWithStyle(font.Family, font.Style, font.Size)
+
+	return false, true, nil
+}
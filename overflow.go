@@ -0,0 +1,103 @@
+package gopdf
+
+import "strings"
+
+// 内容超出: 组件的内容超过固定的高度(Overflow.Height)或最多的行数(Overflow.MaxLines)时, 超出的行不写入,
+// 最后一行可以以省略号结尾; 或者逐步缩小字号(行高与行间距按比例缩小)直到内容放入, 到最小字号仍放不下时
+// 按省略号截断. 只作用于 SetContent 设置的纯文本内容.
+
+// OverflowMode 内容超出时的处理方式
+type OverflowMode int
+
+const (
+	OverflowVisible  OverflowMode = iota // 写入全部内容, 高度随内容增加(默认)
+	OverflowClip                         // 超出的行不写入
+	OverflowEllipsis                     // 超出的行不写入, 最后一行以省略号结尾
+	OverflowShrink                       // 缩小字号直到内容放入, 不小于 MinFontSize
+)
+
+// overflowEllipsis 截断的行末尾的省略号
+const overflowEllipsis = "…"
+
+// defaultMinFontSize OverflowShrink 默认的最小字号
+const defaultMinFontSize = 6
+
+// Overflow 内容超出的设置. Height 与 MaxLines 都设置时取可以写入的行数较少的一个
+type Overflow struct {
+	Mode        OverflowMode
+	Height      float64 // 固定的高度(含上下内边距), 0 为不固定, 组件的高度为 Height
+	MaxLines    int     // 最多写入的行数, 0 为不限
+	MinFontSize int     // OverflowShrink 的最小字号, 默认 6
+}
+
+// textMetrics 缩小字号之前的字号, 行高与行间距
+type textMetrics struct {
+	size       int
+	lineHeight float64
+	lineSpace  float64
+}
+
+// fixedHeight 返回固定的高度, 不固定时返回 false
+func (o Overflow) fixedHeight() (float64, bool) {
+	return o.Height, o.Mode != OverflowVisible && o.Height > 0
+}
+
+// maxLines 返回行高 lineHeight, 行间距 lineSpace, 上下内边距 padding 时可以写入的行数, -1 为不限
+func (o Overflow) maxLines(lineHeight, lineSpace, padding float64) int {
+	if o.Mode == OverflowVisible {
+		return -1
+	}
+	limit := -1
+	if o.Height > 0 {
+		limit = int((o.Height-padding+lineSpace)/(lineHeight+lineSpace) + 1e-9)
+		if limit < 0 {
+			limit = 0
+		}
+	}
+	if o.MaxLines > 0 && (limit < 0 || o.MaxLines < limit) {
+		limit = o.MaxLines
+	}
+	return limit
+}
+
+// minFontSize 返回 OverflowShrink 的最小字号
+func (o Overflow) minFontSize() int {
+	if o.MinFontSize > 0 {
+		return o.MinFontSize
+	}
+	return defaultMinFontSize
+}
+
+// fit 返回内容按 wrap 折行后可以写入的行数, -1 为不限. wrap 以 metrics 的字号, 行高与行间距折行, 返回行数.
+// OverflowShrink 时从 original 开始逐步缩小字号, 行高与行间距按比例缩小, 直到行数不超过可以写入的行数
+func (o Overflow) fit(original textMetrics, padding float64, wrap func(metrics textMetrics) int) int {
+	m := original
+	lines := wrap(m)
+	limit := o.maxLines(m.lineHeight, m.lineSpace, padding)
+	for o.Mode == OverflowShrink && limit >= 0 && lines > limit && m.size > o.minFontSize() {
+		m.size--
+		scale := float64(m.size) / float64(original.size)
+		m.lineHeight, m.lineSpace = original.lineHeight*scale, original.lineSpace*scale
+		lines = wrap(m)
+		limit = o.maxLines(m.lineHeight, m.lineSpace, padding)
+	}
+	return limit
+}
+
+// truncate 将 lines 截断为 limit 行. 除 OverflowClip 外, 最后一行以省略号结尾, 宽度不超过 width
+func (o Overflow) truncate(lines []string, limit int, width float64, measure func(string) float64) []string {
+	if limit < 0 || len(lines) <= limit {
+		return lines
+	}
+	lines = append([]string(nil), lines[:limit]...)
+	if o.Mode == OverflowClip || limit == 0 {
+		return lines
+	}
+
+	last := []rune(strings.TrimRight(lines[limit-1], " \t"))
+	for len(last) > 0 && measure(string(last)+overflowEllipsis) > width {
+		last = []rune(strings.TrimRight(string(last[:len(last)-1]), " \t"))
+	}
+	lines[limit-1] = string(last) + overflowEllipsis
+	return lines
+}
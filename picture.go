@@ -1,6 +1,8 @@
 package gopdf
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"image"
 	"image/color"
@@ -58,7 +60,7 @@ func Convert2JPEG(srcPath string, dstPath string) error {
 	case WEBP:
 		return ConvertWEBP2JPEG(srcPath, dstPath)
 	case BMP:
-		return ConvertWEBP2JPEG(srcPath, dstPath)
+		return ConvertBMP2JPEG(srcPath, dstPath)
 	case TIFF:
 		return ConvertTIFF2JPEG(srcPath, dstPath)
 	default:
@@ -66,24 +68,310 @@ func Convert2JPEG(srcPath string, dstPath string) error {
 	}
 }
 
-func GetImageWidthAndHeight(picturePath string) (w, h int) {
-	var err error
-	_, err = os.Stat(picturePath)
+// 转换成嵌入 PDF 的图片数据. JPEG 原样嵌入; PNG, BMP, WEBP, TIFF 等无损图片编码成 8 位不隔行的 PNG,
+// 嵌入时使用 Flate 压缩, 透明通道作为 SMask 软蒙版. quality 大于 0 时, JPEG 与不透明的图片按该质量重新
+// 编码成 JPEG(1-100), 有透明通道的图片仍然使用 PNG
+func EncodeImage(data []byte, quality int) ([]byte, error) {
+	_, pictureType, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if quality <= 0 && (pictureType == JPEG || pictureType == PNG && embeddablePNG(data)) {
+		return data, nil
+	}
+
+	srcImage, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
-		panic("the image path: " + picturePath + " not exist")
+		return nil, err
+	}
+	return EncodeImageFrom(srcImage, quality)
+}
+
+// image.Image 转换成嵌入 PDF 的图片数据, quality 同 EncodeImage
+func EncodeImageFrom(srcImage image.Image, quality int) ([]byte, error) {
+	if quality > 0 && opaque(srcImage) {
+		return EncodeJPEG(srcImage, quality)
+	}
+	return EncodePNG(srcImage)
+}
+
+// image.Image 按质量 quality(1-100, 0 为默认质量) 编码成 JPEG
+func EncodeJPEG(srcImage image.Image, quality int) ([]byte, error) {
+	dstImage := image.NewRGBA(srcImage.Bounds())
+	draw.Draw(dstImage, dstImage.Bounds(), srcImage, srcImage.Bounds().Min, draw.Src)
+
+	var options *jpeg.Options
+	if quality > 0 {
+		options = &jpeg.Options{Quality: quality}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dstImage, options); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// image.Image 编码成 8 位不隔行的 PNG, 保留透明通道
+func EncodePNG(srcImage image.Image) ([]byte, error) {
+	var dstImage image.Image
+	switch srcImage.(type) {
+	case *image.Gray, *image.RGBA, *image.NRGBA:
+		dstImage = srcImage
+	default:
+		nrgba := image.NewNRGBA(srcImage.Bounds())
+		draw.Draw(nrgba, nrgba.Bounds(), srcImage, srcImage.Bounds().Min, draw.Src)
+		dstImage = nrgba
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dstImage); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 图片是否不透明
+func opaque(srcImage image.Image) bool {
+	if o, ok := srcImage.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// PNG 是否可以原样嵌入: 位深不超过 8, 不隔行, 调色板图片没有透明度表(tRNS)
+func embeddablePNG(data []byte) bool {
+	// 8 字节的文件头, 之后是 IHDR 块: 长度, 类型, 宽, 高, 位深, 颜色类型, 压缩, 过滤, 隔行
+	if len(data) < 33 || string(data[12:16]) != "IHDR" {
+		return false
+	}
+	depth, colorType, interlace := data[24], data[25], data[28]
+	if depth > 8 || interlace != 0 {
+		return false
+	}
+	return colorType != 3 || !bytes.Contains(data, []byte("tRNS"))
+}
+
+// exifData EXIF(TIFF 格式) IFD0 中的各项, key 为标签
+type exifData struct {
+	order   binary.ByteOrder
+	entries map[uint16][]byte // 项的值
+	types   map[uint16]uint16 // 项的类型
+}
+
+// exifTypeSizes EXIF 各类型的值的字节数
+var exifTypeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// readExif 读取图片的 EXIF 数据: JPEG 的 APP1 段或 TIFF 文件的 IFD0, 没有时返回 nil
+func readExif(data []byte) *exifData {
+	if len(data) > 4 && (string(data[:4]) == "II*\x00" || string(data[:4]) == "MM\x00*") {
+		return parseExif(data)
+	}
+	if segment := jpegSegment(data, 0xE1, "Exif\x00\x00"); segment != nil {
+		return parseExif(segment)
 	}
+	return nil
+}
 
+// jpegSegment 返回 JPEG 中第一个类型为 marker, 以 prefix 开头的段去掉 prefix 之后的数据, 没有时返回 nil
+func jpegSegment(data []byte, marker byte, prefix string) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		if data[i+1] == 0xD9 || data[i+1] == 0xDA { // EOI, SOS
+			break
+		}
+		// 段的长度包含长度字段自身的 2 个字节
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if data[i+1] == marker && bytes.HasPrefix(segment, []byte(prefix)) {
+			return segment[len(prefix):]
+		}
+		i += 2 + length
+	}
+	return nil
+}
+
+// tiffOrder 返回 TIFF 格式的数据的字节序, 不是 TIFF 格式时返回 nil
+func tiffOrder(tiff []byte) binary.ByteOrder {
+	if len(tiff) < 8 {
+		return nil
+	}
+	switch string(tiff[:2]) {
+	case "II":
+		return binary.LittleEndian
+	case "MM":
+		return binary.BigEndian
+	}
+	return nil
+}
+
+// parseExif 解析 TIFF 格式的 EXIF 数据的 IFD0
+func parseExif(tiff []byte) *exifData {
+	order := tiffOrder(tiff)
+	if order == nil {
+		return nil
+	}
+	return parseIFD(tiff, order, int(order.Uint32(tiff[4:])))
+}
+
+// parseIFD 解析 TIFF 格式的数据中偏移为 offset 的 IFD
+func parseIFD(tiff []byte, order binary.ByteOrder, offset int) *exifData {
+	if offset < 0 || offset+2 > len(tiff) {
+		return nil
+	}
+	exif := &exifData{order: order, entries: map[uint16][]byte{}, types: map[uint16]uint16{}}
+	count := int(exif.order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		tag, typ := exif.order.Uint16(tiff[entry:]), exif.order.Uint16(tiff[entry+2:])
+		// 值的个数为 0 或类型未知的项没有值
+		size := exifTypeSizes[typ] * int(exif.order.Uint32(tiff[entry+4:]))
+		if size == 0 {
+			continue
+		}
+		value := tiff[entry+8 : entry+12]
+		if size < 4 {
+			value = value[:size]
+		} else if size > 4 {
+			// 超过 4 字节的值保存在偏移处
+			start := int(exif.order.Uint32(value))
+			if start < 0 || start+size > len(tiff) {
+				continue
+			}
+			value = tiff[start : start+size]
+		}
+		exif.entries[tag], exif.types[tag] = value, typ
+	}
+	return exif
+}
+
+// uint 返回 SHORT 或 LONG 类型的项的(第一个)值
+func (exif *exifData) uint(tag uint16) (uint32, bool) {
+	value, ok := exif.entries[tag]
+	if !ok {
+		return 0, false
+	}
+	switch {
+	case exif.types[tag] == 3 && len(value) >= 2:
+		return uint32(exif.order.Uint16(value)), true
+	case exif.types[tag] == 4 && len(value) >= 4:
+		return exif.order.Uint32(value), true
+	}
+	return 0, false
+}
+
+// rational 返回 RATIONAL 类型的项的值
+func (exif *exifData) rational(tag uint16) (float64, bool) {
+	value, ok := exif.entries[tag]
+	if !ok || exif.types[tag] != 5 || len(value) < 8 {
+		return 0, false
+	}
+	numerator, denominator := exif.order.Uint32(value), exif.order.Uint32(value[4:])
+	if denominator == 0 {
+		return 0, false
+	}
+	return float64(numerator) / float64(denominator), true
+}
+
+// 图片的 EXIF 方向(1-8), 没有时为 1
+func ImageOrientation(data []byte) int {
+	exif := readExif(data)
+	if exif == nil {
+		return 1
+	}
+	return exif.orientation()
+}
+
+// orientation 返回 Orientation 标签记录的方向(1-8), 没有时为 1
+func (exif *exifData) orientation() int {
+	orientation, ok := exif.uint(0x0112)
+	if !ok || orientation < 1 || orientation > 8 {
+		return 1
+	}
+	return int(orientation)
+}
+
+// 图片记录的水平与垂直分辨率(DPI): PNG 的 pHYs 块, JPEG 的 JFIF 或 EXIF, TIFF 的分辨率标签.
+// 没有记录时返回 0, 0
+func ImageResolution(data []byte) (dpiX, dpiY float64) {
+	const inch = 0.0254 // 米
+
+	// PNG: pHYs 块, 单位为 1 时为每米的像素数
+	if bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		for i := 8; i+8 <= len(data); {
+			length := int(binary.BigEndian.Uint32(data[i:]))
+			typ := string(data[i+4 : i+8])
+			if typ == "IDAT" || typ == "IEND" || i+8+length > len(data) {
+				break
+			}
+			if typ == "pHYs" && length == 9 && data[i+16] == 1 {
+				chunk := data[i+8:]
+				return float64(binary.BigEndian.Uint32(chunk)) * inch, float64(binary.BigEndian.Uint32(chunk[4:])) * inch
+			}
+			i += 12 + length
+		}
+		return 0, 0
+	}
+
+	// JPEG: JFIF 的单位为 1 时为每英寸的像素数, 为 2 时为每厘米的像素数
+	if jfif := jpegSegment(data, 0xE0, "JFIF\x00"); len(jfif) >= 7 && (jfif[2] == 1 || jfif[2] == 2) {
+		x, y := float64(binary.BigEndian.Uint16(jfif[3:])), float64(binary.BigEndian.Uint16(jfif[5:]))
+		if jfif[2] == 2 {
+			x, y = x*2.54, y*2.54
+		}
+		if x > 0 && y > 0 {
+			return x, y
+		}
+	}
+
+	// JPEG 的 EXIF, TIFF
+	exif := readExif(data)
+	if exif == nil {
+		return 0, 0
+	}
+	return exif.resolution()
+}
+
+// resolution 返回 XResolution, YResolution, ResolutionUnit(2 为英寸, 默认; 3 为厘米)标签记录的分辨率(DPI),
+// 没有时返回 0, 0
+func (exif *exifData) resolution() (dpiX, dpiY float64) {
+	x, okX := exif.rational(0x011A)
+	y, okY := exif.rational(0x011B)
+	if !okX || !okY || x <= 0 || y <= 0 {
+		return 0, 0
+	}
+	if unit, ok := exif.uint(0x0128); ok {
+		switch unit {
+		case 1: // 没有单位
+			return 0, 0
+		case 3:
+			x, y = x*2.54, y*2.54
+		}
+	}
+	return x, y
+}
+
+// 图片文件的像素宽高, 文件不存在或者不能解码时返回错误
+func GetImageWidthAndHeight(picturePath string) (w, h int, err error) {
 	fd, err := os.Open(picturePath)
 	if err != nil {
-		panic("open image error")
+		return 0, 0, err
 	}
+	defer fd.Close()
 
 	config, _, err := image.DecodeConfig(fd)
 	if err != nil {
-		panic("decode image error")
+		return 0, 0, fmt.Errorf("decode image %s: %w", picturePath, err)
 	}
 
-	return config.Width, config.Height
+	return config.Width, config.Height, nil
 }
 
 func ConvertPNG2JPEG(srcPath, dstPath string) (err error) {
@@ -0,0 +1,53 @@
+package gopdf
+
+import (
+	"github.com/tiechui1994/gopdf/core"
+	"github.com/tiechui1994/gopdf/util"
+)
+
+// 文本的渲染方式: 组件(Div, TextCell)的文本可以描边(空心字), 填充并描边, 不可见或者作为裁剪区域, 描边的
+// 颜色与宽度与字体颜色无关. 参考 core.Report.TextRenderMode
+//
+// 裁剪方式下组件压入文本的裁剪区域(core.Report.PushTextClip), 各行合成一个裁剪区域, 写完后恢复, 裁剪的范围
+// 为组件的文本本身
+
+// textRender 组件的文本渲染方式与描边
+type textRender struct {
+	mode        core.TextRenderMode
+	strokeWidth float64 // 描边的宽度, 0 为默认(字号的 3%)
+	strokeColor string  // 描边的颜色, 默认为字体颜色
+}
+
+// begin 设置之后写入的文本的渲染方式与描边, 裁剪方式压入文本的裁剪区域
+func (r textRender) begin(pdf *core.Report) {
+	if r.clips() {
+		pdf.PushTextClip()
+	}
+	if r.mode != core.TextRenderFill {
+		pdf.TextRenderMode(r.mode)
+	}
+	if r.strokeColor != "" {
+		pdf.TextStrokeColor(util.RGB(r.strokeColor))
+	}
+	if r.strokeWidth > 0 {
+		pdf.TextStrokeWidth(r.strokeWidth)
+	}
+}
+
+// end 恢复默认的渲染方式与描边, 裁剪方式恢复 begin 压入的裁剪区域
+func (r textRender) end(pdf *core.Report) {
+	if r.mode != core.TextRenderFill {
+		pdf.TextRenderMode(core.TextRenderFill)
+	}
+	if r.strokeWidth > 0 || r.strokeColor != "" {
+		pdf.TextDefaultStroke()
+	}
+	if r.clips() {
+		pdf.PopTransform()
+	}
+}
+
+// clips 文本是否作为裁剪区域
+func (r textRender) clips() bool {
+	return r.mode >= core.TextRenderFillClip
+}
@@ -0,0 +1,313 @@
+package gopdf
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tiechui1994/gopdf/core"
+	"github.com/tiechui1994/gopdf/util"
+)
+
+// 富文本: 内容由多个样式不同的片段(TextRun)组成. 片段首尾相接成段落("\n" 分段), 折行的断行机会跨越片段;
+// 每一行按行内最大的字体增加行高, 各片段在同一基线上写入. 双向文本按整行确定嵌入层级(UBA), 片段在层级变化处
+// 拆开, 按视觉顺序自左向右排列.
+
+// TextRun 富文本中样式相同的一段文字
+type TextRun struct {
+	Text string
+
+	// 字体, Family 为空时使用组件字体的 Family, Size 为 0 时使用组件字体的 Size, Style 按原样使用
+	Font core.Font
+
+	Color     string // 字体颜色, 为空时使用组件的字体颜色
+	Underline bool   // 下划线, 同字体样式 "U"
+	Strike    bool   // 删除线, 同字体样式 "S"
+	Overline  bool   // 上划线, 同字体样式 "O"
+	Link      string // 外部链接
+	Highlight string // 背景高亮颜色, 为空时使用组件的高亮颜色
+
+	Script        core.Script // 上标/下标, 字号按字体的 OS/2 度量缩小, 基线随之上移/下移
+	BaselineShift float64     // 基线额外的偏移, 正值上移
+}
+
+// richSegment 一行中属于同一个 TextRun, 嵌入层级相同的文字
+type richSegment struct {
+	run   *TextRun
+	font  core.Font
+	text  string
+	width float64
+	level int // 嵌入层级, 奇数自右向左
+
+	ascent, descent float64 // 字体的上伸/下伸, descent 为正
+	shift           float64 // 基线的偏移, 正值上移
+}
+
+// richLine 富文本折行后的一行
+type richLine struct {
+	segments []richSegment
+	width    float64
+
+	// 行内字体的上伸/下伸超出组件字体的部分: 基线下移 above, 行高增加 above+below
+	above, below float64
+}
+
+// height 返回行比组件字体的行多出的高度
+func (line richLine) height() float64 {
+	return line.above + line.below
+}
+
+// resolveFont 返回片段写入时的字体和基线的偏移
+func (run TextRun) resolveFont(pdf *core.Report, base core.Font) (core.Font, float64) {
+	font := run.Font
+	if font.Family == "" {
+		font.Family = base.Family
+	}
+	if font.Size == 0 {
+		font.Size = base.Size
+	}
+	shift := run.BaselineShift
+	if run.Script != core.ScriptBaseline {
+		size, scriptShift := pdf.GetScriptMetrics(font.Family, font.Style, float64(font.Size), run.Script)
+		font.Size = int(math.Max(1, math.Round(size)))
+		shift += scriptShift
+	}
+	if run.Underline {
+		font.Style += core.FontStyleUnderline
+	}
+	if run.Strike {
+		font.Style += core.FontStyleStrike
+	}
+	if run.Overline {
+		font.Style += core.FontStyleOverline
+	}
+	return font, shift
+}
+
+func checkRuns(runs []TextRun) {
+	for _, run := range runs {
+		if run.Color != "" {
+			util.CheckColor(run.Color)
+		}
+		if run.Highlight != "" {
+			util.CheckColor(run.Highlight)
+		}
+	}
+}
+
+// layoutRuns 将 runs 按 width 折行, 返回每一行及其所在段落的方向和是否为段落的最后一行.
+// 段落按断行机会(UAX #14, 避头尾)折行, 设置了断词时在单词内断开并在行尾添加连字符.
+func layoutRuns(pdf *core.Report, runs []TextRun, base core.Font, width float64, direction core.TextDirection,
+	hyphenation core.Hyphenation) (lines []richLine, directions []core.TextDirection, lastLines []bool) {
+	fonts := make([]core.Font, len(runs))
+	shifts := make([]float64, len(runs))
+	for i := range runs {
+		fonts[i], shifts[i] = runs[i].resolveFont(pdf, base)
+	}
+
+	measure := func(font core.Font, text string) float64 {
+		return pdf.MeasureTextWidthWithFont(text, font)
+	}
+	baseAscent, baseDescent := pdf.GetFontMetricsWithStyle(base.Family, base.Style, float64(base.Size))
+
+	// newLine 由 text[i] 属于 runs[owner[i]], 嵌入层级为 levels[i] 的文字组成一行, 只测量宽度时 levels 为 nil
+	newLine := func(text []rune, owner, levels []int) richLine {
+		var line richLine
+		level := func(i int) int {
+			if levels == nil {
+				return 0
+			}
+			return levels[i]
+		}
+		for i := 0; i < len(text); {
+			j := i + 1
+			for j < len(text) && owner[j] == owner[i] && level(j) == level(i) {
+				j++
+			}
+			font := fonts[owner[i]]
+			shift := shifts[owner[i]]
+			segment := richSegment{run: &runs[owner[i]], font: font, text: string(text[i:j]), level: level(i), shift: shift}
+			segment.width = measure(font, segment.text)
+			ascent, descent := pdf.GetFontMetricsWithStyle(font.Family, font.Style, float64(font.Size))
+			segment.ascent, segment.descent = ascent, -descent
+			line.segments = append(line.segments, segment)
+			line.width += segment.width
+			// 上标/下标的行按偏移后的上伸/下伸增高
+			if ascent+shift-baseAscent > line.above {
+				line.above = ascent + shift - baseAscent
+			}
+			if baseDescent-descent-shift > line.below {
+				line.below = baseDescent - descent - shift
+			}
+			i = j
+		}
+		return line
+	}
+
+	layout := func(text []rune, owner []int) {
+		dir := core.ResolveDirection(direction, string(text))
+		if len(text) == 0 {
+			lines = append(lines, richLine{})
+			directions = append(directions, dir)
+			lastLines = append(lastLines, true)
+			return
+		}
+
+		for start := 0; start < len(text); {
+			rest := text[start:]
+			// 测量 rest 的前缀, 断词时前缀之后追加了连字符 (连字符点总在字母之间, 原文此处不是 '-').
+			// 前缀按断行机会递增测量: 宽度为最近的已测前缀的宽度加上其后新增部分的宽度, 每个字符只测量一次
+			measured, widths := []int{0}, map[int]float64{0: 0}
+			prefixWidth := func(s string) float64 {
+				n := utf8.RuneCountInString(s)
+				last, _ := utf8.DecodeLastRuneInString(s)
+				hyphen := last == '-' && (n > len(rest) || rest[n-1] != '-')
+				if hyphen {
+					n--
+				}
+				w, ok := widths[n]
+				if !ok {
+					i := sort.SearchInts(measured, n)
+					m := measured[i-1]
+					w = widths[m] + newLine(rest[m:n], owner[start+m:start+n], nil).width
+					widths[n] = w
+					measured = append(measured[:i], append([]int{n}, measured[i:]...)...)
+				}
+				if hyphen && n > 0 {
+					w += measure(fonts[owner[start+n-1]], "-")
+				}
+				return w
+			}
+			n, hyphen := core.FitLineWithHyphenation(rest, width, prefixWidth, true, hyphenation)
+
+			end := start + n
+			for end > start && (text[end-1] == ' ' || text[end-1] == '\t') {
+				end--
+			}
+			line := newLine(text[start:end], owner[start:end], core.BidiLevels(dir, string(text[start:end])))
+			if hyphen && len(line.segments) > 0 {
+				segment := &line.segments[len(line.segments)-1]
+				hyphenWidth := measure(segment.font, "-")
+				segment.text += "-"
+				segment.width += hyphenWidth
+				line.width += hyphenWidth
+			}
+			lines = append(lines, line)
+			directions = append(directions, dir)
+			lastLines = append(lastLines, start+n == len(text))
+			start += n
+		}
+	}
+
+	var (
+		text  []rune
+		owner []int
+	)
+	for i := range runs {
+		parts := strings.Split(strings.Replace(runs[i].Text, "\t", "    ", -1), "\n")
+		for j, part := range parts {
+			if j > 0 {
+				layout(text, owner)
+				text, owner = nil, nil
+			}
+			for _, r := range part {
+				text = append(text, r)
+				owner = append(owner, i)
+			}
+		}
+	}
+	layout(text, owner)
+
+	return lines, directions, lastLines
+}
+
+// draw 写入一行: x 为行的左缘, baseline 为基线; justify > 0 时两端对齐到该宽度.
+// color, highlight 为组件的字体颜色和高亮颜色. 片段按嵌入层级的视觉顺序自左向右排列, 以其层级的方向写入.
+func (line richLine) draw(pdf *core.Report, x, baseline, justify float64, color, highlight string) {
+	texts := make([]string, len(line.segments))
+	for i, segment := range line.segments {
+		texts[i] = segment.text
+	}
+	extra := 0.0
+	if justify > 0 {
+		extra = justify - line.width
+	}
+	word, char, shifts, _ := core.JustifyLine(texts, extra)
+	width := line.width
+	if word != 0 || char != 0 {
+		width = justify
+	}
+
+	if word != 0 {
+		pdf.WordSpacing(word)
+	}
+	if char != 0 {
+		pdf.CharSpacing(char)
+	}
+	// 片段占据的宽度包括其后的对齐间距, 按视觉顺序依次排列
+	levels := make([]int, len(line.segments))
+	spans := make([]float64, len(line.segments))
+	for i, segment := range line.segments {
+		levels[i] = segment.level
+		spans[i] = segment.width + width - line.width - shifts[i]
+		if i+1 < len(line.segments) {
+			spans[i] = segment.width + shifts[i+1] - shifts[i]
+		}
+	}
+
+	dirs := directionWriter{pdf: pdf}
+	left := x
+	for _, i := range core.VisualOrder(levels) {
+		segment := line.segments[i]
+		sx := left
+		left += spans[i]
+		dir := core.DirectionLTR
+		if segment.level%2 == 1 {
+			dir = core.DirectionRTL
+		}
+
+		font := segment.font
+		textHighlight := highlight
+		if segment.run.Highlight != "" {
+			textHighlight = segment.run.Highlight
+		}
+		if textHighlight != "" {
+			pdf.TextHighlight(util.RGB(textHighlight))
+		}
+
+		textColor := color
+		if segment.run.Color != "" {
+			textColor = segment.run.Color
+		}
+		if textColor != "" {
+			pdf.TextColor(util.RGB(textColor))
+		}
+		pdf.SetFontWithStyle(font.Family, font.Style, font.Size)
+		dirs.line(dir, segment.text)
+		if segment.shift != 0 {
+			pdf.BaselineShift(segment.shift)
+		}
+		if segment.run.Link != "" {
+			pdf.ExternalLink(sx, baseline, segment.ascent+segment.descent, segment.text, segment.run.Link)
+		} else {
+			pdf.Cell(sx, baseline, segment.text)
+		}
+		if segment.shift != 0 {
+			pdf.BaselineShift(0)
+		}
+		if textColor != "" {
+			pdf.TextDefaultColor()
+		}
+		if textHighlight != "" {
+			pdf.TextDefaultHighlight()
+		}
+	}
+	dirs.reset()
+	if word != 0 {
+		pdf.WordSpacing(0)
+	}
+	if char != 0 {
+		pdf.CharSpacing(0)
+	}
+}
@@ -25,6 +25,9 @@ type Span struct {
 	horizontalCentered bool
 	verticalCentered   bool
 	rightAlign         bool
+
+	overflow Overflow     // 内容超出时的处理方式, 默认写入全部内容
+	original *textMetrics // 缩小字号之前的字号, 行高与行间距, 未缩小时为 nil
 }
 
 func NewSpan(lineHeight, lineSpce float64, pdf *core.Report) *Span {
@@ -68,16 +71,21 @@ func NewSpanWithWidth(width float64, lineHeight, lineSpce float64, pdf *core.Rep
 }
 
 func (span *Span) Copy(content string) *Span {
+	font, lineHeight, lineSpace := span.font, span.lineHeight, span.lineSpace
+	if span.original != nil {
+		font.Size, lineHeight, lineSpace = span.original.size, span.original.lineHeight, span.original.lineSpace
+	}
 	f := &Span{
 		pdf:        span.pdf,
 		width:      span.width,
-		lineHeight: span.lineHeight,
-		lineSpace:  span.lineSpace,
+		lineHeight: lineHeight,
+		lineSpace:  lineSpace,
 		fontColor:  span.fontColor,
+		overflow:   span.overflow,
 	}
 
 	f.SetBorder(span.border)
-	f.SetFont(span.font)
+	f.SetFont(font)
 	f.SetContent(content)
 
 	return f
@@ -178,6 +186,7 @@ func (span *Span) SetFontColor(color string) *Span {
 }
 func (span *Span) SetFont(font core.Font) *Span {
 	span.font = font
+	span.original = nil
 	// 注册, 启动
 	span.pdf.Font(font.Family, font.Size, font.Style)
 	span.pdf.SetFontWithStyle(font.Family, font.Style, font.Size)
@@ -190,7 +199,40 @@ func (span *Span) SetFontWithColor(font core.Font, color string) *Span {
 	return span
 }
 
+// 设置内容超出固定的高度或最多的行数时的处理方式: 截断, 省略号, 缩小字号, 须在 SetContent 之前调用.
+// 设置了固定的高度时, span 的高度为该高度
+func (span *Span) SetOverflow(overflow Overflow) *Span {
+	span.overflow = overflow
+	return span
+}
+
 func (span *Span) SetContent(content string) *Span {
+	if span.overflow.Mode == OverflowVisible {
+		return span.setContent(content)
+	}
+
+	if span.original == nil {
+		span.original = &textMetrics{size: span.font.Size, lineHeight: span.lineHeight, lineSpace: span.lineSpace}
+	}
+	// 高度按写入的内容计算, 不保留之前的内容或较大的字号的高度
+	limit := span.overflow.fit(*span.original, span.border.Top+span.border.Bottom, func(m textMetrics) int {
+		span.font.Size, span.lineHeight, span.lineSpace = m.size, m.lineHeight, m.lineSpace
+		span.contents = nil
+		span.height = 0
+		span.setContent(content)
+		return len(span.contents)
+	})
+	span.contents = span.overflow.truncate(span.contents, limit, span.width, span.pdf.MeasureTextWidth)
+	if fixed, ok := span.overflow.fixedHeight(); ok {
+		span.height = fixed
+	} else {
+		length := math.Max(1, float64(len(span.contents)))
+		span.height = span.border.Top + span.border.Bottom + span.lineHeight*length + span.lineSpace*(length-1)
+	}
+	return span
+}
+
+func (span *Span) setContent(content string) *Span {
 	convertStr := strings.Replace(content, "\t", "    ", -1)
 
 	var (
@@ -217,35 +259,9 @@ func (span *Span) SetContent(content string) *Span {
 		}
 	}
 
+	// 每个段落按断行机会(UAX #14, 避头尾)折行
 	for i := range blocks {
-		// 单独的一行
-		if span.pdf.MeasureTextWidth(convertStr) < contentWidth {
-			span.contents = append(span.contents, blocks[i])
-			continue
-		}
-
-		var (
-			line []rune
-		)
-		// 单独的一行需要拆分
-		for _, r := range []rune(blocks[i]) {
-			line = append(line, r)
-			lineLength := span.pdf.MeasureTextWidth(string(line))
-			if lineLength >= contentWidth {
-				if lineLength-contentWidth > 2 {
-					span.contents = append(span.contents, string(line[0:len(line)-1]))
-					line = line[len(line)-1:]
-				} else {
-					span.contents = append(span.contents, string(line))
-					line = []rune{}
-				}
-			}
-		}
-
-		// 剩余单独成行
-		if len(line) > 0 {
-			span.contents = append(span.contents, string(line))
-		}
+		span.contents = append(span.contents, span.pdf.WrapText(blocks[i], contentWidth)...)
 	}
 
 	// 重新计算 span 的高度
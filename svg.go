@@ -0,0 +1,1199 @@
+package gopdf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tiechui1994/gopdf/core"
+	"github.com/tiechui1994/gopdf/util"
+)
+
+// SVG 矢量图: 解析 SVG 的常用子集, 转换为 Report 的路径(Path), 渐变(RegisterGradient)与文本, 在 PDF 中仍为
+// 矢量图形, 缩放与打印时不失真. 支持的子集:
+//   - 元素: svg, g, a, use, symbol, defs, path, rect, circle, ellipse, line, polyline, polygon, text, tspan,
+//     linearGradient, radialGradient, stop
+//   - 属性(属性或 style 中的声明): transform, fill, fill-rule, stroke, stroke-width, stroke-linecap,
+//     stroke-linejoin, stroke-miterlimit, stroke-dasharray, stroke-dashoffset, color, display, visibility,
+//     opacity, fill-opacity, stroke-opacity, font-family, font-size, font-weight, font-style, text-anchor
+//   - 颜色: #rgb, #rrggbb, rgb(), 常用的颜色名, currentColor, 以及 url(#id) 引用的渐变
+//   - style 元素中的样式表: 简单选择器(*, 元素名, .class, #id 及其组合, 以逗号分隔), 优先级同 CSS, 低于
+//     style 属性. 其它的选择器(后代, 子元素, 伪类, 属性等)与 @ 规则返回错误
+//
+// 文本使用 Report 中注册的字体: font-family 中第一个注册了的字体, 都没有注册时使用当前字体. opacity 不创建
+// 透明组, 与 fill-opacity, stroke-opacity 相乘后作用于各个图形与文字, 文字的描边使用填充的不透明度. 不支持的
+// 内容(裁剪路径, 蒙版, 滤镜, 图案, 标记, 图片)被忽略, 描边的渐变以渐变的第一个颜色描边.
+
+const (
+	svgPixel       = 0.75 // 1 像素(SVG 的用户单位)为 0.75 pt
+	svgFontSize    = 16   // 默认字号, 像素
+	svgMiterLimit  = 4    // 默认的斜接限制
+	svgMaxUseDepth = 16   // use 引用的最大嵌套深度
+	svgMaxUses     = 4096 // 一次绘制中展开的 use 元素的最大个数, 之后的 use 不绘制
+)
+
+// svgElement SVG 的元素. 属性包括样式表与 style 中的声明(依次优先于同名的属性), 文本内容为 name 为空的子元素
+type svgElement struct {
+	name     string
+	attrs    map[string]string
+	children []*svgElement
+	text     string
+}
+
+// SVG 矢量图组件, 在指定的宽高内按 viewBox 与 preserveAspectRatio 绘制
+type SVG struct {
+	pdf           *core.Report
+	root          *svgElement
+	ids           map[string]*svgElement // 有 id 的元素
+	width, height float64                // 在页面中的宽高
+	uses          int                    // 绘制中已展开的 use 元素的个数
+}
+
+// 读取 SVG 文件, 参考 NewSVGFromReader
+func NewSVG(path string, width, height float64, pdf *core.Report) (*SVG, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewSVGFromReader(file, width, height, pdf)
+}
+
+// 从 reader 读取 SVG. width, height 为在页面中的宽高: 都为 0 时为 SVG 的宽高(1 像素为 0.75 pt), 只指定了一个时
+// 另一个按 SVG 的宽高比例计算. 宽高不超过页面的内容区域
+func NewSVGFromReader(reader io.Reader, width, height float64, pdf *core.Report) (*SVG, error) {
+	root, ids, err := parseSVG(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	contentWidth, contentHeight := pdf.GetContentWidthAndHeight()
+	if width > contentWidth {
+		width = contentWidth
+	}
+	if height > contentHeight {
+		height = contentHeight
+	}
+	w, h := root.size()
+	switch {
+	case width > 0 && height > 0:
+	case width > 0:
+		height = h * width / w
+	case height > 0:
+		width = w * height / h
+	default:
+		width, height = w, h
+	}
+	return &SVG{pdf: pdf, root: root, ids: ids, width: width, height: height}, nil
+}
+
+// parseSVG 解析 SVG 文档, 返回根元素与有 id 的元素
+func parseSVG(reader io.Reader) (*svgElement, map[string]*svgElement, error) {
+	decoder := xml.NewDecoder(reader)
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var (
+		root     *svgElement
+		stack    []*svgElement
+		ids      = map[string]*svgElement{}
+		elements []*svgElement                   // 文档顺序的全部元素
+		inline   = map[*svgElement][][2]string{} // style 属性中的声明
+		sheets   []string                        // style 元素的内容
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse svg: %w", err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			el := &svgElement{name: token.Name.Local, attrs: map[string]string{}}
+			for _, attr := range token.Attr {
+				el.attrs[attr.Name.Local] = strings.TrimSpace(attr.Value)
+			}
+			inline[el] = parseSVGDeclarations(el.attrs["style"])
+			elements = append(elements, el)
+			if id := el.attrs["id"]; id != "" {
+				ids[id] = el
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, el)
+			} else if root == nil {
+				root = el
+			}
+			stack = append(stack, el)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				if parent.name == "style" {
+					sheets = append(sheets, string(token))
+					continue
+				}
+				parent.children = append(parent.children, &svgElement{text: string(token)})
+			}
+		}
+	}
+	if root == nil || root.name != "svg" {
+		return nil, nil, fmt.Errorf("parse svg: no svg element")
+	}
+
+	// 样式表的声明覆盖属性, style 属性的声明覆盖样式表
+	rules, err := parseSVGStyleSheet(strings.Join(sheets, "\n"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse svg: %w", err)
+	}
+	for _, el := range elements {
+		for _, rule := range rules {
+			if rule.matches(el) {
+				for _, d := range rule.declarations {
+					el.attrs[d[0]] = d[1]
+				}
+			}
+		}
+		for _, d := range inline[el] {
+			el.attrs[d[0]] = d[1]
+		}
+	}
+	return root, ids, nil
+}
+
+// svgRule 样式表的规则: 一个简单选择器与其声明
+type svgRule struct {
+	name, id     string // 元素名与 id, 为空时不限
+	classes      []string
+	specificity  int
+	declarations [][2]string
+}
+
+// parseSVGStyleSheet 解析样式表, 返回按优先级(相同时按出现的顺序)排列的规则. 不支持的选择器与 @ 规则返回错误
+func parseSVGStyleSheet(sheet string) ([]svgRule, error) {
+	// 去掉注释, 以及 CDATA 之外的 HTML 注释标记
+	for {
+		start := strings.Index(sheet, "/*")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(sheet[start+2:], "*/")
+		if end < 0 {
+			sheet = sheet[:start]
+			break
+		}
+		sheet = sheet[:start] + " " + sheet[start+2+end+2:]
+	}
+	sheet = strings.NewReplacer("<!--", " ", "-->", " ").Replace(sheet)
+
+	var rules []svgRule
+	for {
+		open := strings.IndexByte(sheet, '{')
+		if open < 0 {
+			if strings.TrimSpace(sheet) != "" {
+				return nil, fmt.Errorf("style sheet without a rule block: %q", strings.TrimSpace(sheet))
+			}
+			break
+		}
+		end := strings.IndexByte(sheet[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("style sheet with an unclosed rule block")
+		}
+		selectors, body := strings.TrimSpace(sheet[:open]), sheet[open+1:open+end]
+		sheet = sheet[open+end+1:]
+		if strings.HasPrefix(selectors, "@") {
+			return nil, fmt.Errorf("unsupported style sheet rule %q", selectors)
+		}
+
+		declarations := parseSVGDeclarations(body)
+		for _, selector := range strings.Split(selectors, ",") {
+			rule, err := parseSVGSelector(strings.TrimSpace(selector))
+			if err != nil {
+				return nil, err
+			}
+			rule.declarations = declarations
+			rules = append(rules, rule)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].specificity < rules[j].specificity })
+	return rules, nil
+}
+
+// parseSVGSelector 解析简单选择器: *, 元素名, .class 与 #id 的组合
+func parseSVGSelector(selector string) (svgRule, error) {
+	var rule svgRule
+	if selector == "" || strings.ContainsAny(selector, " \t\r\n>+~:[]()") {
+		return rule, fmt.Errorf("unsupported style sheet selector %q", selector)
+	}
+	rest := strings.TrimPrefix(selector, "*")
+	end := strings.IndexAny(rest, ".#")
+	if end < 0 {
+		end = len(rest)
+	}
+	if rule.name = rest[:end]; rule.name != "" {
+		rule.specificity++
+	}
+	rest = rest[end:]
+	for rest != "" {
+		end := strings.IndexAny(rest[1:], ".#") + 1
+		if end == 0 {
+			end = len(rest)
+		}
+		part := rest[1:end]
+		if part == "" {
+			return rule, fmt.Errorf("unsupported style sheet selector %q", selector)
+		}
+		if rest[0] == '#' {
+			rule.id = part
+			rule.specificity += 100
+		} else {
+			rule.classes = append(rule.classes, part)
+			rule.specificity += 10
+		}
+		rest = rest[end:]
+	}
+	return rule, nil
+}
+
+// matches 元素 el 是否匹配规则的选择器
+func (rule svgRule) matches(el *svgElement) bool {
+	if rule.name != "" && rule.name != el.name || rule.id != "" && rule.id != el.attrs["id"] {
+		return false
+	}
+	classes := strings.Fields(el.attrs["class"])
+	for _, class := range rule.classes {
+		found := false
+		for _, c := range classes {
+			found = found || c == class
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSVGDeclarations 解析 style 属性或者规则中的声明, 忽略 !important
+func parseSVGDeclarations(style string) [][2]string {
+	var declarations [][2]string
+	for _, declaration := range strings.Split(style, ";") {
+		if parts := strings.SplitN(declaration, ":", 2); len(parts) == 2 {
+			value := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(parts[1]), "!important"))
+			declarations = append(declarations, [2]string{strings.TrimSpace(parts[0]), value})
+		}
+	}
+	return declarations
+}
+
+// size 返回 svg 元素的宽高(pt): width 与 height 属性, 没有时按 viewBox 的宽高比例, 都没有时为 300x150 像素(同浏览器)
+func (el *svgElement) size() (width, height float64) {
+	style := defaultSVGStyle()
+	w, okw := style.length(el.attrs["width"], 0)
+	h, okh := style.length(el.attrs["height"], 0)
+	if strings.HasSuffix(el.attrs["width"], "%") || w <= 0 {
+		okw = false
+	}
+	if strings.HasSuffix(el.attrs["height"], "%") || h <= 0 {
+		okh = false
+	}
+
+	vw, vh := 300.0, 150.0
+	if vb := svgNumbers(el.attrs["viewBox"]); len(vb) == 4 && vb[2] > 0 && vb[3] > 0 {
+		vw, vh = vb[2], vb[3]
+	}
+	switch {
+	case okw && okh:
+	case okw:
+		h = w * vh / vw
+	case okh:
+		w = h * vw / vh
+	default:
+		w, h = vw, vh
+	}
+	return w * svgPixel, h * svgPixel
+}
+
+func (svg *SVG) GetWidth() float64 {
+	return svg.width
+}
+func (svg *SVG) GetHeight() float64 {
+	return svg.height
+}
+
+// 在当前位置绘制, 当前页放不下时从下一页开始. 之后的位置在 SVG 的下方
+func (svg *SVG) GenerateAtomicCell() (pagebreak, over bool, err error) {
+	x, y := svg.pdf.GetXY()
+	startX, startY := svg.pdf.GetPageStartXY()
+	if _, pageEndY := svg.pdf.GetPageEndXY(); y > startY && y+svg.height > pageEndY {
+		svg.pdf.AddNewPage(false)
+		x, y = startX, startY
+	}
+
+	svg.Draw(x, y)
+	svg.pdf.SetXY(startX, y+svg.height)
+	return false, true, nil
+}
+
+// Draw 以 (x, y) 为左上角绘制, 超出 SVG 视口的内容被裁剪. 不改变当前位置
+func (svg *SVG) Draw(x, y float64) {
+	sx, sy := svg.pdf.GetXY()
+	font := svg.pdf.GetCurrentFont()
+	svg.uses = 0
+
+	// 没有 viewBox 时, SVG 的宽高(像素)缩放到指定的宽高
+	root := svg.root
+	if _, ok := root.attrs["viewBox"]; !ok {
+		w, h := root.size()
+		root = &svgElement{name: root.name, attrs: map[string]string{}, children: root.children}
+		for k, v := range svg.root.attrs {
+			root.attrs[k] = v
+		}
+		root.attrs["viewBox"] = fmt.Sprintf("0 0 %f %f", w/svgPixel, h/svgPixel)
+	}
+	svg.viewport(root, x, y, svg.width, svg.height, defaultSVGStyle().inherit(root))
+
+	if font.Family != "" {
+		svg.pdf.SetFontWithStyle(font.Family, font.Style, font.Size)
+	}
+	svg.pdf.SetXY(sx, sy)
+}
+
+// viewport 将 svg 或 symbol 元素 el 的 viewBox 按 preserveAspectRatio 映射到视口 (x, y, width, height),
+// 在视口内绘制其子元素
+func (svg *SVG) viewport(el *svgElement, x, y, width, height float64, style svgStyle) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	svg.pdf.ClipRect(x, y, width, height)
+	defer svg.pdf.PopTransform()
+
+	m := core.TranslateMatrix(x, y)
+	style.viewportWidth, style.viewportHeight = width, height
+	if vb := svgNumbers(el.attrs["viewBox"]); len(vb) == 4 && vb[2] > 0 && vb[3] > 0 {
+		m = viewBoxMatrix(vb, width, height, el.attrs["preserveAspectRatio"]).Multiply(m)
+		style.viewportWidth, style.viewportHeight = vb[2], vb[3]
+	}
+	svg.pdf.PushTransform(m)
+	defer svg.pdf.PopTransform()
+
+	for _, child := range el.children {
+		svg.render(child, style)
+	}
+}
+
+// viewBoxMatrix 返回 viewBox vb 映射到原点处宽高为 width, height 的视口的变换
+func viewBoxMatrix(vb []float64, width, height float64, aspect string) core.Matrix {
+	sx, sy := width/vb[2], height/vb[3]
+	fields := strings.Fields(aspect)
+	if len(fields) > 0 && fields[0] == "defer" {
+		fields = fields[1:]
+	}
+	align := "xMidYMid"
+	if len(fields) > 0 {
+		align = fields[0]
+	}
+	if align == "none" {
+		return core.TranslateMatrix(-vb[0], -vb[1]).Multiply(core.ScaleMatrix(sx, sy))
+	}
+
+	s := math.Min(sx, sy)
+	if len(fields) > 1 && fields[1] == "slice" {
+		s = math.Max(sx, sy)
+	}
+	tx, ty := -vb[0]*s, -vb[1]*s
+	switch {
+	case strings.Contains(align, "xMid"):
+		tx += (width - vb[2]*s) / 2
+	case strings.Contains(align, "xMax"):
+		tx += width - vb[2]*s
+	}
+	switch {
+	case strings.Contains(align, "YMid"):
+		ty += (height - vb[3]*s) / 2
+	case strings.Contains(align, "YMax"):
+		ty += height - vb[3]*s
+	}
+	return core.ScaleMatrix(s, s).Multiply(core.TranslateMatrix(tx, ty))
+}
+
+// render 绘制元素 el, style 为继承的样式
+func (svg *SVG) render(el *svgElement, style svgStyle) {
+	if el.name == "" || el.attrs["display"] == "none" {
+		return
+	}
+	style = style.inherit(el)
+	if transform, ok := el.attrs["transform"]; ok {
+		svg.pdf.PushTransform(parseSVGTransform(transform))
+		defer svg.pdf.PopTransform()
+	}
+
+	switch el.name {
+	case "g", "a":
+		for _, child := range el.children {
+			svg.render(child, style)
+		}
+	case "svg":
+		x, _ := style.length(el.attrs["x"], style.viewportWidth)
+		y, _ := style.length(el.attrs["y"], style.viewportHeight)
+		width, height := style.viewportSize(el.attrs["width"], el.attrs["height"])
+		svg.viewport(el, x, y, width, height, style)
+	case "use":
+		svg.use(el, style)
+	case "path":
+		svg.paint(parseSVGPathData(el.attrs["d"]), style)
+	case "rect", "circle", "ellipse", "line", "polyline", "polygon":
+		svg.paint(svg.shape(el, style), style)
+	case "text":
+		svg.text(el, style)
+	}
+}
+
+// use 绘制 use 元素引用的元素, 平移到 (x, y). 引用 symbol 时 symbol 的 viewBox 映射到 use 的宽高.
+// 嵌套的深度与展开的个数有上限, 防止相互引用的 use 指数级地展开
+func (svg *SVG) use(el *svgElement, style svgStyle) {
+	target := svg.ids[strings.TrimPrefix(el.attrs["href"], "#")]
+	if target == nil || style.depth >= svgMaxUseDepth || svg.uses >= svgMaxUses {
+		return
+	}
+	style.depth++
+	svg.uses++
+
+	x, _ := style.length(el.attrs["x"], style.viewportWidth)
+	y, _ := style.length(el.attrs["y"], style.viewportHeight)
+	svg.pdf.PushTransform(core.TranslateMatrix(x, y))
+	defer svg.pdf.PopTransform()
+
+	if target.name == "symbol" {
+		if target.attrs["display"] == "none" {
+			return
+		}
+		width, height := style.viewportSize(el.attrs["width"], el.attrs["height"])
+		svg.viewport(target, 0, 0, width, height, style.inherit(target))
+		return
+	}
+	svg.render(target, style)
+}
+
+// shape 返回基本形状的路径
+func (svg *SVG) shape(el *svgElement, style svgStyle) *svgPath {
+	var (
+		p    = &svgPath{}
+		w, h = style.viewportWidth, style.viewportHeight
+		diag = math.Sqrt((w*w + h*h) / 2)
+	)
+	value := func(name string, reference float64) float64 {
+		v, _ := style.length(el.attrs[name], reference)
+		return v
+	}
+
+	switch el.name {
+	case "rect":
+		x, y, width, height := value("x", w), value("y", h), value("width", w), value("height", h)
+		if width <= 0 || height <= 0 {
+			return p
+		}
+		rx, okx := style.length(el.attrs["rx"], w)
+		ry, oky := style.length(el.attrs["ry"], h)
+		if !okx {
+			rx = ry
+		}
+		if !oky {
+			ry = rx
+		}
+		rx, ry = math.Min(math.Max(rx, 0), width/2), math.Min(math.Max(ry, 0), height/2)
+		if rx == 0 || ry == 0 {
+			p.moveTo(x, y)
+			p.lineTo(x+width, y)
+			p.lineTo(x+width, y+height)
+			p.lineTo(x, y+height)
+			p.close()
+			return p
+		}
+		p.moveTo(x+rx, y)
+		p.lineTo(x+width-rx, y)
+		p.arcTo(rx, ry, 0, false, true, x+width, y+ry)
+		p.lineTo(x+width, y+height-ry)
+		p.arcTo(rx, ry, 0, false, true, x+width-rx, y+height)
+		p.lineTo(x+rx, y+height)
+		p.arcTo(rx, ry, 0, false, true, x, y+height-ry)
+		p.lineTo(x, y+ry)
+		p.arcTo(rx, ry, 0, false, true, x+rx, y)
+		p.close()
+	case "circle":
+		if r := value("r", diag); r > 0 {
+			p.ellipse(value("cx", w), value("cy", h), r, r)
+		}
+	case "ellipse":
+		if rx, ry := value("rx", w), value("ry", h); rx > 0 && ry > 0 {
+			p.ellipse(value("cx", w), value("cy", h), rx, ry)
+		}
+	case "line":
+		p.moveTo(value("x1", w), value("y1", h))
+		p.lineTo(value("x2", w), value("y2", h))
+	case "polyline", "polygon":
+		points := svgNumbers(el.attrs["points"])
+		for i := 0; i+1 < len(points); i += 2 {
+			if i == 0 {
+				p.moveTo(points[i], points[i+1])
+			} else {
+				p.lineTo(points[i], points[i+1])
+			}
+		}
+		if el.name == "polygon" && !p.path.Empty() {
+			p.close()
+		}
+	}
+	return p
+}
+
+// paint 以样式的填充与描边绘制路径
+func (svg *SVG) paint(p *svgPath, style svgStyle) {
+	if p.path.Empty() || style.hidden {
+		return
+	}
+
+	paint := core.PathStyle{EvenOdd: style.evenOdd}
+	paint.Fill = svg.fill(style.fill, p.bbox, style)
+	if stroke := svg.color(style.stroke); stroke != "" && style.strokeWidth > 0 {
+		paint.Stroke, paint.StrokeWidth = stroke, style.strokeWidth
+		paint.LineCap, paint.LineJoin, paint.MiterLimit = style.lineCap, style.lineJoin, style.miterLimit
+		paint.Dash, paint.DashPhase = style.dash, style.dashOffset
+	}
+	if paint.Fill == "" && paint.Stroke == "" {
+		return
+	}
+	defer svg.transparency(style)()
+	svg.pdf.Path(&p.path, paint)
+}
+
+// transparency 以样式的不透明度绘制之后的内容(与当前的不透明度相乘), 返回恢复当前的不透明度的函数
+func (svg *SVG) transparency(style svgStyle) (restore func()) {
+	fill, stroke := style.opacity*style.fillOpacity, style.opacity*style.strokeOpacity
+	if fill == 1 && stroke == 1 {
+		return func() {}
+	}
+	currentFill, currentStroke := svg.pdf.GetTransparency()
+	svg.pdf.SetTransparency(fill*currentFill, stroke*currentStroke)
+	return func() {
+		if currentFill == 1 && currentStroke == 1 {
+			svg.pdf.ClearTransparency()
+		} else {
+			svg.pdf.SetTransparency(currentFill, currentStroke)
+		}
+	}
+}
+
+// fill 返回填充的颜色或注册的渐变的 key, bbox 为路径的边界框, 不填充时为空
+func (svg *SVG) fill(paint svgPaint, bbox [4]float64, style svgStyle) string {
+	if paint.gradient == "" {
+		return paint.color
+	}
+	el, attrs, stops := svg.gradient(paint.gradient)
+	if el == nil {
+		return paint.color
+	}
+	switch len(stops) {
+	case 0:
+		return ""
+	case 1:
+		return stops[0].Color
+	}
+
+	userSpace := attrs["gradientUnits"] == "userSpaceOnUse"
+	w, h := style.viewportWidth, style.viewportHeight
+	coord := func(name, value string, reference float64) float64 {
+		if v, ok := attrs[name]; ok {
+			value = v
+		}
+		if userSpace {
+			v, _ := style.length(value, reference)
+			return v
+		}
+		// objectBoundingBox: 边界框的比例
+		if strings.HasSuffix(value, "%") {
+			v, _ := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			return v / 100
+		}
+		v, _ := strconv.ParseFloat(value, 64)
+		return v
+	}
+
+	gradient := core.Gradient{Stops: stops, Radial: el.name == "radialGradient"}
+	last := stops[len(stops)-1].Color
+	if gradient.Radial {
+		gradient.CX, gradient.CY = coord("cx", "50%", w), coord("cy", "50%", h)
+		gradient.R = coord("r", "50%", math.Sqrt((w*w+h*h)/2))
+		gradient.FX, gradient.FY = coord("fx", attrs["cx"], w), coord("fy", attrs["cy"], h)
+		if _, ok := attrs["fx"]; !ok {
+			gradient.FX = gradient.CX
+		}
+		if _, ok := attrs["fy"]; !ok {
+			gradient.FY = gradient.CY
+		}
+		if gradient.R <= 0 {
+			return last
+		}
+	} else {
+		gradient.X1, gradient.Y1 = coord("x1", "0%", w), coord("y1", "0%", h)
+		gradient.X2, gradient.Y2 = coord("x2", "100%", w), coord("y2", "0%", h)
+		if gradient.X1 == gradient.X2 && gradient.Y1 == gradient.Y2 {
+			return last
+		}
+	}
+
+	gradient.Transform = parseSVGTransform(attrs["gradientTransform"])
+	if !userSpace {
+		width, height := bbox[2]-bbox[0], bbox[3]-bbox[1]
+		if width <= 0 || height <= 0 {
+			return ""
+		}
+		gradient.Transform = gradient.Transform.Multiply(core.Matrix{A: width, D: height, E: bbox[0], F: bbox[1]})
+	}
+	return svg.pdf.RegisterGradient(gradient)
+}
+
+// gradient 返回 id 引用的渐变元素, 以及沿 href 合并的属性与颜色. 不是渐变时返回 nil
+func (svg *SVG) gradient(id string) (*svgElement, map[string]string, []core.GradientStop) {
+	el := svg.ids[id]
+	if el == nil || el.name != "linearGradient" && el.name != "radialGradient" {
+		return nil, nil, nil
+	}
+
+	var (
+		attrs = map[string]string{}
+		stops []core.GradientStop
+		found bool
+	)
+	for g, depth := el, 0; g != nil && depth < svgMaxUseDepth; depth++ {
+		for k, v := range g.attrs {
+			if _, ok := attrs[k]; !ok {
+				attrs[k] = v
+			}
+		}
+		for _, child := range g.children {
+			if child.name != "stop" || found && len(stops) == 0 {
+				continue
+			}
+			offset := 0.0
+			if value := child.attrs["offset"]; strings.HasSuffix(value, "%") {
+				offset, _ = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+				offset /= 100
+			} else {
+				offset, _ = strconv.ParseFloat(value, 64)
+			}
+			color := "0,0,0"
+			if value, ok := child.attrs["stop-color"]; ok {
+				if c, ok := parseSVGColor(value, color); ok {
+					color = c
+				}
+			}
+			stops = append(stops, core.GradientStop{Offset: offset, Color: color})
+		}
+		// 只使用第一个有颜色的渐变的颜色
+		found = found || len(stops) > 0
+		g = svg.ids[strings.TrimPrefix(g.attrs["href"], "#")]
+		if g != nil && g.name != "linearGradient" && g.name != "radialGradient" {
+			break
+		}
+	}
+	return el, attrs, stops
+}
+
+// color 返回描边的颜色, 渐变以渐变的第一个颜色代替
+func (svg *SVG) color(paint svgPaint) string {
+	if paint.gradient != "" {
+		if el, _, stops := svg.gradient(paint.gradient); el != nil {
+			if len(stops) == 0 {
+				return ""
+			}
+			return stops[0].Color
+		}
+	}
+	return paint.color
+}
+
+// svgRun text 元素中一段样式相同的文字
+type svgRun struct {
+	text   string
+	style  svgStyle
+	dx, dy float64 // 文字之前的偏移
+}
+
+// svgChunk text 元素中从指定的位置开始排列的文字, 按 text-anchor 对齐
+type svgChunk struct {
+	x, y float64
+	runs []svgRun
+}
+
+// text 写入 text 元素: 文本与 tspan 的各段文字依次排列, 指定了 x 或 y 的 tspan 从新的位置开始
+func (svg *SVG) text(el *svgElement, style svgStyle) {
+	first := func(e *svgElement, name string, reference float64) (float64, bool) {
+		values := strings.Fields(strings.Replace(e.attrs[name], ",", " ", -1))
+		if len(values) == 0 {
+			return 0, false
+		}
+		return style.length(values[0], reference)
+	}
+	x, _ := first(el, "x", style.viewportWidth)
+	y, _ := first(el, "y", style.viewportHeight)
+
+	chunks := []*svgChunk{{x: x, y: y}}
+	var walk func(e *svgElement, style svgStyle)
+	walk = func(e *svgElement, style svgStyle) {
+		var dx, dy float64
+		if e != el {
+			x, okx := first(e, "x", style.viewportWidth)
+			y, oky := first(e, "y", style.viewportHeight)
+			if okx || oky {
+				chunk := chunks[len(chunks)-1]
+				if !okx {
+					x = chunk.x
+				}
+				if !oky {
+					y = chunk.y
+				}
+				chunks = append(chunks, &svgChunk{x: x, y: y})
+			}
+		}
+		dx, _ = first(e, "dx", style.viewportWidth)
+		dy, _ = first(e, "dy", style.viewportHeight)
+
+		for _, child := range e.children {
+			switch {
+			case child.name == "":
+				chunk := chunks[len(chunks)-1]
+				chunk.runs = append(chunk.runs, svgRun{text: child.text, style: style, dx: dx, dy: dy})
+				dx, dy = 0, 0
+			case (child.name == "tspan" || child.name == "a") && child.attrs["display"] != "none":
+				walk(child, style.inherit(child))
+			}
+		}
+	}
+	walk(el, style)
+
+	// 空白合并为一个空格, 去掉开头与结尾的空白
+	var runs []*svgRun
+	for _, chunk := range chunks {
+		for i := range chunk.runs {
+			runs = append(runs, &chunk.runs[i])
+		}
+	}
+	space := true
+	for _, run := range runs {
+		text := strings.Join(strings.Fields(run.text), " ")
+		if text != "" && strings.IndexByte(" \t\r\n", run.text[0]) >= 0 && !space {
+			text = " " + text
+		}
+		if text != "" && strings.IndexByte(" \t\r\n", run.text[len(run.text)-1]) >= 0 {
+			text += " "
+		}
+		if text != "" {
+			space = strings.HasSuffix(text, " ")
+		}
+		run.text = text
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].text = strings.TrimRight(runs[i].text, " "); runs[i].text != "" {
+			break
+		}
+	}
+
+	for _, chunk := range chunks {
+		svg.chunk(chunk)
+	}
+}
+
+// chunk 写入一块文字
+func (svg *SVG) chunk(chunk *svgChunk) {
+	if len(chunk.runs) == 0 {
+		return
+	}
+	width := 0.0
+	for _, run := range chunk.runs {
+		width += run.dx + svg.measure(run)
+	}
+	x, y := chunk.x, chunk.y
+	switch chunk.runs[0].style.anchor {
+	case "middle":
+		x -= width / 2
+	case "end":
+		x -= width
+	}
+	for _, run := range chunk.runs {
+		x, y = x+run.dx, y+run.dy
+		svg.write(run, x, y)
+		x += svg.measure(run)
+	}
+}
+
+// font 返回样式的字体, 整数的字号与字体缩放的比例
+func (svg *SVG) font(style svgStyle) (core.Font, float64) {
+	font := core.Font{Family: svg.pdf.GetCurrentFont().Family}
+	if font.Family == "" {
+		font.Family = core.FontSans
+	}
+	for _, family := range strings.Split(style.fontFamily, ",") {
+		if family = strings.Trim(strings.TrimSpace(family), `"'`); svg.pdf.HasFontStyle(family, "") {
+			font.Family = family
+			break
+		}
+	}
+	if style.bold {
+		font.Style += core.FontStyleBold
+	}
+	if style.italic {
+		font.Style += core.FontStyleItalic
+	}
+	font.Size = int(math.Max(1, math.Round(style.fontSize)))
+	return font, style.fontSize / float64(font.Size)
+}
+
+// measure 返回一段文字的宽度
+func (svg *SVG) measure(run svgRun) float64 {
+	if run.text == "" {
+		return 0
+	}
+	font, scale := svg.font(run.style)
+	svg.pdf.SetFontWithStyle(font.Family, font.Style, font.Size)
+	return svg.pdf.MeasureTextWidth(run.text) * scale
+}
+
+// write 以基线的起点 (x, y) 写入一段文字. 字体按整数的字号写入, 再缩放到样式的字号. 写完后恢复之前的字体颜色
+func (svg *SVG) write(run svgRun, x, y float64) {
+	fill, stroke := svg.color(run.style.fill), svg.color(run.style.stroke)
+	if run.style.strokeWidth <= 0 {
+		stroke = ""
+	}
+	if run.text == "" || run.style.hidden || fill == "" && stroke == "" {
+		return
+	}
+
+	font, scale := svg.font(run.style)
+	svg.pdf.SetFontWithStyle(font.Family, font.Style, font.Size)
+	defer svg.transparency(run.style)()
+	red, green, blue := svg.pdf.GetTextColor()
+	if fill != "" {
+		svg.pdf.TextColor(util.RGB(fill))
+	}
+	if stroke != "" {
+		mode := core.TextRenderFillStroke
+		if fill == "" {
+			mode = core.TextRenderStroke
+		}
+		svg.pdf.TextRenderMode(mode)
+		svg.pdf.TextStrokeColor(util.RGB(stroke))
+		svg.pdf.TextStrokeWidth(run.style.strokeWidth / scale)
+	}
+
+	svg.pdf.PushTransform(core.ScaleMatrix(scale, scale).Multiply(core.TranslateMatrix(x, y)))
+	svg.pdf.Cell(0, 0, run.text)
+	svg.pdf.PopTransform()
+
+	if fill != "" {
+		svg.pdf.TextColor(red, green, blue)
+	}
+	if stroke != "" {
+		svg.pdf.TextRenderMode(core.TextRenderFill)
+		svg.pdf.TextDefaultStroke()
+	}
+}
+
+// svgPaint 填充或描边: 颜色 "r,g,b" 或引用的渐变的 id, 都为空时不绘制
+type svgPaint struct {
+	color    string
+	gradient string // 渐变无效时使用 color
+}
+
+// svgStyle 继承的样式, 以及视口的宽高(百分比长度的参考)与 use 的嵌套深度
+type svgStyle struct {
+	fill, stroke      svgPaint
+	evenOdd           bool
+	strokeWidth       float64
+	lineCap, lineJoin int
+	miterLimit        float64
+	dash              []float64
+	dashOffset        float64
+	color             string // currentColor 的颜色
+
+	opacity                    float64 // 元素及其祖先的 opacity 之积
+	fillOpacity, strokeOpacity float64
+
+	fontFamily   string
+	fontSize     float64
+	bold, italic bool
+	anchor       string // text-anchor: start, middle, end
+	hidden       bool   // visibility: hidden
+
+	viewportWidth, viewportHeight float64
+	depth                         int
+}
+
+func defaultSVGStyle() svgStyle {
+	return svgStyle{
+		fill:           svgPaint{color: "0,0,0"},
+		strokeWidth:    1,
+		miterLimit:     svgMiterLimit,
+		color:          "0,0,0",
+		opacity:        1,
+		fillOpacity:    1,
+		strokeOpacity:  1,
+		fontSize:       svgFontSize,
+		anchor:         "start",
+		viewportWidth:  300,
+		viewportHeight: 150,
+	}
+}
+
+// inherit 返回元素 el 的样式: 继承 style, 以 el 的属性覆盖
+func (style svgStyle) inherit(el *svgElement) svgStyle {
+	attr := func(name string) (string, bool) {
+		v, ok := el.attrs[name]
+		return v, ok && v != "" && v != "inherit"
+	}
+	number := func(name string) (float64, bool) {
+		v, ok := attr(name)
+		if !ok {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	diag := math.Sqrt((style.viewportWidth*style.viewportWidth + style.viewportHeight*style.viewportHeight) / 2)
+
+	if v, ok := attr("color"); ok {
+		if c, ok := parseSVGColor(v, style.color); ok {
+			style.color = c
+		}
+	}
+	if v, ok := attr("fill"); ok {
+		style.fill = parseSVGPaint(v, style.color, style.fill)
+	}
+	if v, ok := attr("stroke"); ok {
+		style.stroke = parseSVGPaint(v, style.color, style.stroke)
+	}
+	if v, ok := attr("fill-rule"); ok {
+		style.evenOdd = v == "evenodd"
+	}
+	if v, ok := attr("stroke-width"); ok {
+		if w, ok := style.length(v, diag); ok && w >= 0 {
+			style.strokeWidth = w
+		}
+	}
+	if v, ok := attr("stroke-linecap"); ok {
+		style.lineCap = map[string]int{"butt": 0, "round": 1, "square": 2}[v]
+	}
+	if v, ok := attr("stroke-linejoin"); ok {
+		style.lineJoin = map[string]int{"miter": 0, "round": 1, "bevel": 2}[v]
+	}
+	if v, ok := number("stroke-miterlimit"); ok && v >= 1 {
+		style.miterLimit = v
+	}
+	if v, ok := attr("stroke-dasharray"); ok {
+		style.dash = nil
+		dash := svgNumbers(v)
+		total := 0.0
+		for _, d := range dash {
+			if d < 0 {
+				total = 0
+				break
+			}
+			total += d
+		}
+		if total > 0 && v != "none" {
+			if len(dash)%2 == 1 {
+				dash = append(dash, dash...)
+			}
+			style.dash = dash
+		}
+	}
+	if v, ok := attr("stroke-dashoffset"); ok {
+		style.dashOffset, _ = style.length(v, diag)
+	}
+	if v, ok := attr("font-family"); ok {
+		style.fontFamily = v
+	}
+	if v, ok := attr("font-size"); ok {
+		if size, ok := style.length(v, style.fontSize); ok && size > 0 {
+			style.fontSize = size
+		}
+	}
+	if v, ok := attr("font-weight"); ok {
+		weight, err := strconv.Atoi(v)
+		style.bold = v == "bold" || v == "bolder" || err == nil && weight >= 600
+	}
+	if v, ok := attr("font-style"); ok {
+		style.italic = v == "italic" || v == "oblique"
+	}
+	if v, ok := attr("text-anchor"); ok {
+		style.anchor = v
+	}
+	if v, ok := attr("visibility"); ok {
+		style.hidden = v == "hidden" || v == "collapse"
+	}
+	if v, ok := svgOpacity(el.attrs["opacity"]); ok {
+		style.opacity *= v
+	}
+	if v, ok := attr("fill-opacity"); ok {
+		style.fillOpacity, _ = svgOpacity(v)
+	}
+	if v, ok := attr("stroke-opacity"); ok {
+		style.strokeOpacity, _ = svgOpacity(v)
+	}
+	return style
+}
+
+// svgOpacity 解析不透明度: 数值或百分比, 限制在 0 到 1 之间. 无效的值为 1
+func svgOpacity(value string) (float64, bool) {
+	scale := 1.0
+	if strings.HasSuffix(value, "%") {
+		value, scale = strings.TrimSuffix(value, "%"), 0.01
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 1, false
+	}
+	return math.Max(0, math.Min(1, v*scale)), true
+}
+
+// length 解析长度(用户单位, 即像素). 百分比相对于 reference, em 相对于字号
+func (style svgStyle) length(value string, reference float64) (float64, bool) {
+	value = strings.TrimSpace(value)
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"%", reference / 100}, {"px", 1}, {"pt", 1 / svgPixel}, {"pc", 12 / svgPixel},
+		{"mm", 72 / 25.4 / svgPixel}, {"cm", 72 / 2.54 / svgPixel}, {"in", 72 / svgPixel},
+		{"em", style.fontSize}, {"ex", style.fontSize / 2},
+	}
+	scale := 1.0
+	for _, unit := range units {
+		if strings.HasSuffix(value, unit.suffix) {
+			value, scale = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix)), unit.scale
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * scale, true
+}
+
+// viewportSize 返回嵌套的 svg 或 use 引用的 symbol 的视口宽高, 默认为当前视口的宽高
+func (style svgStyle) viewportSize(width, height string) (float64, float64) {
+	w, ok := style.length(width, style.viewportWidth)
+	if !ok {
+		w = style.viewportWidth
+	}
+	h, ok := style.length(height, style.viewportHeight)
+	if !ok {
+		h = style.viewportHeight
+	}
+	return w, h
+}
+
+// parseSVGPaint 解析 fill 或 stroke 的值. 无法识别的值保持 inherited
+func parseSVGPaint(value, current string, inherited svgPaint) svgPaint {
+	if strings.HasPrefix(value, "url(") {
+		end := strings.IndexByte(value, ')')
+		if end < 0 {
+			return inherited
+		}
+		paint := svgPaint{gradient: strings.Trim(strings.TrimSpace(value[4:end]), `"'#`)}
+		if fallback := strings.TrimSpace(value[end+1:]); fallback != "" && fallback != "none" {
+			paint.color, _ = parseSVGColor(fallback, current)
+		}
+		return paint
+	}
+	if value == "none" || value == "transparent" {
+		return svgPaint{}
+	}
+	if color, ok := parseSVGColor(value, current); ok {
+		return svgPaint{color: color}
+	}
+	return inherited
+}
+
+// parseSVGColor 解析颜色为 "r,g,b". current 为 currentColor 的颜色
+func parseSVGColor(value, current string) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case value == "currentcolor":
+		return current, true
+	case strings.HasPrefix(value, "#"):
+		hex := value[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 && len(hex) != 8 {
+			return "", false
+		}
+		rgb, err := strconv.ParseUint(hex[:6], 16, 32)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("%d,%d,%d", rgb>>16, rgb>>8&0xff, rgb&0xff), true
+	case strings.HasPrefix(value, "rgb(") || strings.HasPrefix(value, "rgba("):
+		start, end := strings.IndexByte(value, '('), strings.IndexByte(value, ')')
+		if end < start {
+			return "", false
+		}
+		parts := strings.FieldsFunc(value[start+1:end], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '/'
+		})
+		if len(parts) < 3 {
+			return "", false
+		}
+		var rgb [3]int
+		for i := range rgb {
+			part := parts[i]
+			scale := 1.0
+			if strings.HasSuffix(part, "%") {
+				part, scale = strings.TrimSuffix(part, "%"), 2.55
+			}
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return "", false
+			}
+			rgb[i] = int(math.Max(0, math.Min(255, math.Round(v*scale))))
+		}
+		return fmt.Sprintf("%d,%d,%d", rgb[0], rgb[1], rgb[2]), true
+	}
+	if rgb, ok := svgColorNames[value]; ok {
+		return rgb, true
+	}
+	return "", false
+}
+
+// svgColorNames 常用的颜色名
+var svgColorNames = map[string]string{
+	"black": "0,0,0", "white": "255,255,255", "red": "255,0,0", "lime": "0,255,0", "blue": "0,0,255",
+	"yellow": "255,255,0", "cyan": "0,255,255", "aqua": "0,255,255", "magenta": "255,0,255",
+	"fuchsia": "255,0,255", "silver": "192,192,192", "gray": "128,128,128", "grey": "128,128,128",
+	"maroon": "128,0,0", "olive": "128,128,0", "green": "0,128,0", "purple": "128,0,128", "teal": "0,128,128",
+	"navy": "0,0,128", "orange": "255,165,0", "pink": "255,192,203", "brown": "165,42,42",
+	"gold": "255,215,0", "indigo": "75,0,130", "violet": "238,130,238", "coral": "255,127,80",
+	"salmon": "250,128,114", "tomato": "255,99,71", "crimson": "220,20,60", "orchid": "218,112,214",
+	"khaki": "240,230,140", "beige": "245,245,220", "ivory": "255,255,240", "tan": "210,180,140",
+	"chocolate": "210,105,30", "skyblue": "135,206,235", "steelblue": "70,130,180",
+	"royalblue": "65,105,225", "dodgerblue": "30,144,255", "darkblue": "0,0,139", "darkgreen": "0,100,0",
+	"darkred": "139,0,0", "darkgray": "169,169,169", "darkgrey": "169,169,169",
+	"lightgray": "211,211,211", "lightgrey": "211,211,211", "lightblue": "173,216,230",
+	"lightgreen": "144,238,144", "whitesmoke": "245,245,245", "gainsboro": "220,220,220",
+	"dimgray": "105,105,105", "dimgrey": "105,105,105", "slategray": "112,128,144",
+	"slategrey": "112,128,144", "forestgreen": "34,139,34", "seagreen": "46,139,87",
+	"limegreen": "50,205,50", "turquoise": "64,224,208", "firebrick": "178,34,34",
+	"darkorange": "255,140,0", "orangered": "255,69,0", "goldenrod": "218,165,32",
+}
@@ -0,0 +1,411 @@
+package gopdf
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tiechui1994/gopdf/core"
+)
+
+// SVG 的路径数据与变换: path 元素的 d 属性的全部命令(M, L, H, V, C, S, Q, T, A, Z 及其相对坐标形式)转换为
+// 直线与三次贝塞尔曲线, 二次曲线升阶, 椭圆弧按不超过 90 度分段近似. transform 属性转换为 core.Matrix.
+
+// svgPath SVG 用户坐标中的路径, 记录当前点, 子路径的起点与边界框
+type svgPath struct {
+	path           core.Path
+	x, y           float64    // 当前点
+	startX, startY float64    // 当前子路径的起点
+	bbox           [4]float64 // 边界框 minX, minY, maxX, maxY
+}
+
+// extend 边界框扩展到包含各点
+func (p *svgPath) extend(points ...float64) {
+	for i := 0; i+1 < len(points); i += 2 {
+		x, y := points[i], points[i+1]
+		if p.path.Empty() && i == 0 {
+			p.bbox = [4]float64{x, y, x, y}
+			continue
+		}
+		p.bbox = [4]float64{math.Min(p.bbox[0], x), math.Min(p.bbox[1], y), math.Max(p.bbox[2], x), math.Max(p.bbox[3], y)}
+	}
+}
+
+func (p *svgPath) moveTo(x, y float64) {
+	p.extend(x, y)
+	p.path.MoveTo(x, y)
+	p.x, p.y, p.startX, p.startY = x, y, x, y
+}
+
+func (p *svgPath) lineTo(x, y float64) {
+	p.extend(x, y)
+	p.path.LineTo(x, y)
+	p.x, p.y = x, y
+}
+
+func (p *svgPath) curveTo(x1, y1, x2, y2, x, y float64) {
+	// 边界框包含曲线的端点与极值点, 不包含控制点
+	for _, t := range append(cubicExtrema(p.x, x1, x2, x), cubicExtrema(p.y, y1, y2, y)...) {
+		p.extend(cubicAt(p.x, x1, x2, x, t), cubicAt(p.y, y1, y2, y, t))
+	}
+	p.extend(x, y)
+	p.path.CurveTo(x1, y1, x2, y2, x, y)
+	p.x, p.y = x, y
+}
+
+// cubicAt 返回三次贝塞尔曲线的一个坐标在参数 t 处的值
+func cubicAt(p0, p1, p2, p3, t float64) float64 {
+	u := 1 - t
+	return u*u*u*p0 + 3*u*u*t*p1 + 3*u*t*t*p2 + t*t*t*p3
+}
+
+// cubicExtrema 返回三次贝塞尔曲线的一个坐标取极值的参数 t (0 < t < 1)
+func cubicExtrema(p0, p1, p2, p3 float64) []float64 {
+	// 导数 a t^2 + b t + c 的根
+	a := 3 * (-p0 + 3*p1 - 3*p2 + p3)
+	b := 6 * (p0 - 2*p1 + p2)
+	c := 3 * (p1 - p0)
+
+	var roots []float64
+	if math.Abs(a) < 1e-12 {
+		if b != 0 {
+			roots = append(roots, -c/b)
+		}
+	} else if d := b*b - 4*a*c; d >= 0 {
+		d = math.Sqrt(d)
+		roots = append(roots, (-b+d)/(2*a), (-b-d)/(2*a))
+	}
+
+	var ts []float64
+	for _, t := range roots {
+		if t > 0 && t < 1 {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// quadTo 二次贝塞尔曲线, 升阶为三次曲线
+func (p *svgPath) quadTo(qx, qy, x, y float64) {
+	p.curveTo(p.x+(qx-p.x)*2/3, p.y+(qy-p.y)*2/3, x+(qx-x)*2/3, y+(qy-y)*2/3, x, y)
+}
+
+// arcTo 椭圆弧, 参数同 SVG 的 A 命令. 半径不足以连接两点时等比放大(同 SVG)
+func (p *svgPath) arcTo(rx, ry, rotation float64, large, sweep bool, x, y float64) {
+	x0, y0 := p.x, p.y
+	if x0 == x && y0 == y {
+		return
+	}
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if rx == 0 || ry == 0 {
+		p.lineTo(x, y)
+		return
+	}
+
+	// 端点参数转换为中心参数
+	sin, cos := math.Sincos(rotation * math.Pi / 180)
+	dx, dy := (x0-x)/2, (y0-y)/2
+	x1, y1 := cos*dx+sin*dy, -sin*dx+cos*dy
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	coef := 0.0
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	if den := rx*rx*y1*y1 + ry*ry*x1*x1; num > 0 && den > 0 {
+		coef = math.Sqrt(num / den)
+	}
+	if large == sweep {
+		coef = -coef
+	}
+	cx1, cy1 := coef*rx*y1/ry, -coef*ry*x1/rx
+	cx, cy := cos*cx1-sin*cy1+(x0+x)/2, sin*cx1+cos*cy1+(y0+y)/2
+
+	angle := func(ux, uy, vx, vy float64) float64 {
+		return math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	}
+	theta := angle(1, 0, (x1-cx1)/rx, (y1-cy1)/ry)
+	delta := angle((x1-cx1)/rx, (y1-cy1)/ry, (-x1-cx1)/rx, (-y1-cy1)/ry)
+	if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	} else if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	}
+
+	// 每段不超过 90 度, 以端点的切线确定控制点
+	n := int(math.Ceil(math.Abs(delta)/(math.Pi/2) - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	point := func(t float64) (px, py, tx, ty float64) {
+		st, ct := math.Sincos(t)
+		ex, ey, ux, uy := rx*ct, ry*st, -rx*st, ry*ct
+		return cx + cos*ex - sin*ey, cy + sin*ex + cos*ey, cos*ux - sin*uy, sin*ux + cos*uy
+	}
+	for i, t := 0, theta; i < n; i, t = i+1, t+step {
+		ax, ay, atx, aty := point(t)
+		bx, by, btx, bty := point(t + step)
+		if i == n-1 {
+			bx, by = x, y
+		}
+		p.curveTo(ax+k*atx, ay+k*aty, bx-k*btx, by-k*bty, bx, by)
+	}
+}
+
+func (p *svgPath) close() {
+	p.path.Close()
+	p.x, p.y = p.startX, p.startY
+}
+
+// ellipse 以 (cx, cy) 为中心的椭圆, 由四段弧组成
+func (p *svgPath) ellipse(cx, cy, rx, ry float64) {
+	p.moveTo(cx+rx, cy)
+	p.arcTo(rx, ry, 0, false, true, cx, cy+ry)
+	p.arcTo(rx, ry, 0, false, true, cx-rx, cy)
+	p.arcTo(rx, ry, 0, false, true, cx, cy-ry)
+	p.arcTo(rx, ry, 0, false, true, cx+rx, cy)
+	p.close()
+}
+
+// svgScanner 读取路径数据与数字列表中的数字与命令, 数字之间的空白与逗号可以省略
+type svgScanner struct {
+	s string
+	i int
+}
+
+func (sc *svgScanner) skip() {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) >= 0 {
+		sc.i++
+	}
+}
+
+// command 读取命令字母
+func (sc *svgScanner) command() (byte, bool) {
+	sc.skip()
+	if sc.i < len(sc.s) {
+		if c := sc.s[sc.i]; c != 'e' && c != 'E' && (c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			sc.i++
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+func (sc *svgScanner) number() (float64, bool) {
+	sc.skip()
+	s, start := sc.s, sc.i
+	i := start
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := false
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		digits = true
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			digits = true
+		}
+	}
+	if !digits {
+		return 0, false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			for i = j; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+			}
+		}
+	}
+	v, err := strconv.ParseFloat(s[start:i], 64)
+	if err != nil {
+		return 0, false
+	}
+	sc.i = i
+	return v, true
+}
+
+// numbers 读取 n 个数字
+func (sc *svgScanner) numbers(n int) ([]float64, bool) {
+	values := make([]float64, n)
+	for i := range values {
+		v, ok := sc.number()
+		if !ok {
+			return nil, false
+		}
+		values[i] = v
+	}
+	return values, true
+}
+
+// flag 读取椭圆弧的标志, 只有一位 0 或 1
+func (sc *svgScanner) flag() (bool, bool) {
+	sc.skip()
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '0' || sc.s[sc.i] == '1') {
+		sc.i++
+		return sc.s[sc.i-1] == '1', true
+	}
+	return false, false
+}
+
+// svgNumbers 解析以空白或逗号分隔的数字列表, 在第一个不是数字的位置停止
+func svgNumbers(s string) []float64 {
+	var (
+		sc     = &svgScanner{s: s}
+		values []float64
+	)
+	for {
+		v, ok := sc.number()
+		if !ok {
+			return values
+		}
+		values = append(values, v)
+	}
+}
+
+// parseSVGPathData 解析 path 元素的 d 属性. 数据有误时在出错处停止, 保留之前的部分(同 SVG)
+func parseSVGPathData(d string) *svgPath {
+	var (
+		p            = &svgPath{}
+		sc           = &svgScanner{s: d}
+		command      byte
+		last         byte    // 上一段的命令(大写)
+		ctrlX, ctrlY float64 // 上一段曲线的最后一个控制点, S 与 T 以当前点为中心反射
+	)
+	for {
+		if c, ok := sc.command(); ok {
+			command = c
+		} else if sc.skip(); sc.i >= len(sc.s) || command == 0 {
+			return p
+		}
+
+		upper := command &^ 0x20
+		if upper != 'M' && p.path.Empty() {
+			return p
+		}
+		ox, oy := 0.0, 0.0
+		if command != upper {
+			ox, oy = p.x, p.y
+		}
+
+		var (
+			v  []float64
+			ok = true
+		)
+		switch upper {
+		case 'Z':
+			p.close()
+			command = 0
+		case 'M':
+			if v, ok = sc.numbers(2); ok {
+				p.moveTo(ox+v[0], oy+v[1])
+				// 之后的坐标对为直线
+				if command == 'M' {
+					command = 'L'
+				} else {
+					command = 'l'
+				}
+			}
+		case 'L':
+			if v, ok = sc.numbers(2); ok {
+				p.lineTo(ox+v[0], oy+v[1])
+			}
+		case 'H':
+			if v, ok = sc.numbers(1); ok {
+				p.lineTo(ox+v[0], p.y)
+			}
+		case 'V':
+			if v, ok = sc.numbers(1); ok {
+				p.lineTo(p.x, oy+v[0])
+			}
+		case 'C':
+			if v, ok = sc.numbers(6); ok {
+				ctrlX, ctrlY = ox+v[2], oy+v[3]
+				p.curveTo(ox+v[0], oy+v[1], ctrlX, ctrlY, ox+v[4], oy+v[5])
+			}
+		case 'S':
+			if v, ok = sc.numbers(4); ok {
+				x1, y1 := p.x, p.y
+				if last == 'C' || last == 'S' {
+					x1, y1 = 2*p.x-ctrlX, 2*p.y-ctrlY
+				}
+				ctrlX, ctrlY = ox+v[0], oy+v[1]
+				p.curveTo(x1, y1, ctrlX, ctrlY, ox+v[2], oy+v[3])
+			}
+		case 'Q':
+			if v, ok = sc.numbers(4); ok {
+				ctrlX, ctrlY = ox+v[0], oy+v[1]
+				p.quadTo(ctrlX, ctrlY, ox+v[2], oy+v[3])
+			}
+		case 'T':
+			if v, ok = sc.numbers(2); ok {
+				if last == 'Q' || last == 'T' {
+					ctrlX, ctrlY = 2*p.x-ctrlX, 2*p.y-ctrlY
+				} else {
+					ctrlX, ctrlY = p.x, p.y
+				}
+				p.quadTo(ctrlX, ctrlY, ox+v[0], oy+v[1])
+			}
+		case 'A':
+			var large, sweep bool
+			if v, ok = sc.numbers(3); ok {
+				if large, ok = sc.flag(); ok {
+					if sweep, ok = sc.flag(); ok {
+						var end []float64
+						if end, ok = sc.numbers(2); ok {
+							p.arcTo(v[0], v[1], v[2], large, sweep, ox+end[0], oy+end[1])
+						}
+					}
+				}
+			}
+		default:
+			ok = false
+		}
+		if !ok {
+			return p
+		}
+		last = upper
+	}
+}
+
+var svgTransformPattern = regexp.MustCompile(`([a-zA-Z]+)\s*\(([^)]*)\)`)
+
+// parseSVGTransform 解析 transform 属性. 列表中的变换从后向前作用(同 SVG), 无法识别的变换被忽略
+func parseSVGTransform(s string) core.Matrix {
+	m := core.IdentityMatrix()
+	for _, match := range svgTransformPattern.FindAllStringSubmatch(s, -1) {
+		v := svgNumbers(match[2])
+		arg := func(i int, def float64) float64 {
+			if i < len(v) {
+				return v[i]
+			}
+			return def
+		}
+
+		var t core.Matrix
+		switch {
+		case match[1] == "matrix" && len(v) == 6:
+			t = core.Matrix{A: v[0], B: v[1], C: v[2], D: v[3], E: v[4], F: v[5]}
+		case match[1] == "translate" && len(v) > 0:
+			t = core.TranslateMatrix(v[0], arg(1, 0))
+		case match[1] == "scale" && len(v) > 0:
+			t = core.ScaleMatrix(v[0], arg(1, v[0]))
+		case match[1] == "rotate" && len(v) > 0:
+			// SVG 的 y 轴向下, 正的角度顺时针旋转
+			t = core.RotateMatrix(-v[0]).Around(arg(1, 0), arg(2, 0))
+		case match[1] == "skewX" && len(v) > 0:
+			t = core.SkewMatrix(v[0], 0)
+		case match[1] == "skewY" && len(v) > 0:
+			t = core.SkewMatrix(0, v[0])
+		default:
+			continue
+		}
+		m = t.Multiply(m)
+	}
+	return m
+}
@@ -0,0 +1,137 @@
+package gopdf
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tiechui1994/gopdf/core"
+)
+
+// 制表位: 行内的制表符("\t")将其后的文字移到下一个制表位, 按制表位的对齐方式排列, 其前的空白可以用前导符
+// (点, 短横线, 下划线)填充. 制表位的位置从内容的左边算起; 超出最后一个制表位之后使用默认制表位, 间隔为 4 个
+// 空格的宽度. 含制表符的行按从左向右排列, 不两端对齐.
+
+// TabAlign 制表位的对齐方式
+type TabAlign int
+
+const (
+	TabLeft    TabAlign = iota // 文字从制表位开始
+	TabRight                   // 文字在制表位结束
+	TabCenter                  // 文字以制表位居中
+	TabDecimal                 // 文字的小数点对齐制表位, 没有小数点时同 TabRight
+)
+
+// 前导符: 填充制表符跨过的空白
+const (
+	LeaderNone      = ""
+	LeaderDot       = "."
+	LeaderDash      = "-"
+	LeaderUnderline = "_"
+)
+
+// TabStop 制表位
+type TabStop struct {
+	Position float64  // 距内容左边的距离
+	Align    TabAlign // 对齐方式
+	Leader   string   // 前导符, 默认没有
+	Decimal  string   // TabDecimal 对齐的小数点, 默认 "."
+}
+
+// tabSegment 一行中由制表符分隔的一段文字
+type tabSegment struct {
+	text    string
+	x       float64 // 距行首的距离
+	leader  string  // 段之前填充的前导符
+	leaderX float64 // 前导符距行首的距离
+}
+
+// sortTabStops 返回按位置排序的制表位
+func sortTabStops(stops []TabStop) []TabStop {
+	stops = append([]TabStop(nil), stops...)
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].Position < stops[j].Position
+	})
+	return stops
+}
+
+// layoutTabs 按当前字体与制表位 stops 排列一行文字, 返回各段的位置与行的宽度
+func layoutTabs(pdf *core.Report, text string, stops []TabStop) ([]tabSegment, float64) {
+	if !strings.Contains(text, "\t") {
+		return []tabSegment{{text: text}}, pdf.MeasureTextWidth(text)
+	}
+
+	var (
+		parts    = strings.Split(text, "\t")
+		segments = []tabSegment{{text: parts[0]}}
+		end      = pdf.MeasureTextWidth(parts[0]) // 已写入的文字的末尾
+		space    = pdf.MeasureTextWidth(" ")
+		interval = space * 4 // 默认制表位的间隔
+	)
+	for _, part := range parts[1:] {
+		width := pdf.MeasureTextWidth(part)
+
+		// 下一个制表位, 没有时使用默认制表位
+		stop := TabStop{Position: (float64(int(end/interval)) + 1) * interval}
+		for _, s := range stops {
+			if s.Position > end {
+				stop = s
+				break
+			}
+		}
+
+		x := stop.Position
+		switch stop.Align {
+		case TabRight:
+			x -= width
+		case TabCenter:
+			x -= width / 2
+		case TabDecimal:
+			decimal := stop.Decimal
+			if decimal == "" {
+				decimal = "."
+			}
+			if i := strings.Index(part, decimal); i >= 0 {
+				x -= pdf.MeasureTextWidth(part[:i])
+			} else {
+				x -= width
+			}
+		}
+		if x < end {
+			x = end
+		}
+
+		segment := tabSegment{text: part, x: x}
+		if stop.Leader != "" {
+			// 前导符与两侧的文字各留半个空格
+			leader := pdf.MeasureTextWidth(stop.Leader)
+			if n := int((x - end - space) / leader); n > 0 {
+				segment.leader = strings.Repeat(stop.Leader, n)
+				segment.leaderX = x - space/2 - float64(n)*leader
+			}
+		}
+		segments = append(segments, segment)
+		end = x + width
+	}
+	return segments, end
+}
+
+// tabMeasure 返回按制表位 stops 测量一行文字宽度的函数
+func tabMeasure(pdf *core.Report, stops []TabStop) func(string) float64 {
+	return func(text string) float64 {
+		_, width := layoutTabs(pdf, text, stops)
+		return width
+	}
+}
+
+// tabCell 在 (x, y) 写入按制表位 stops 排列的一行文字
+func tabCell(pdf *core.Report, x, y float64, text string, stops []TabStop) {
+	segments, _ := layoutTabs(pdf, text, stops)
+	for _, segment := range segments {
+		if segment.leader != "" {
+			pdf.Cell(x+segment.leaderX, y, segment.leader)
+		}
+		if segment.text != "" {
+			pdf.Cell(x+segment.x, y, segment.text)
+		}
+	}
+}
@@ -81,6 +81,9 @@ type TableCell struct {
 }
 
 func (cell *TableCell) SetElement(e core.Cell) *TableCell {
+	if textCell, ok := e.(*TextCell); ok {
+		textCell.inTable = true
+	}
 	cell.element = e
 	return cell
 }
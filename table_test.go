@@ -2,6 +2,7 @@ package gopdf
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"strings"
 	"testing"
@@ -270,3 +271,177 @@ func TestManyTableReportWithData(t *testing.T) {
 	end := time.Now().Unix()
 	fmt.Println("i", 1, end-start)
 }
+
+func TestTableRotatedHeader(t *testing.T) {
+	const lineHeight = 18.0
+	headers := []string{"Quantity", "Unit price", "Total amount"}
+	var (
+		longest     float64
+		left, top   float64
+		columnWidth float64
+	)
+	_, records := runReport(t, func(report *core.Report) {
+		left, top = report.GetXY()
+		table := NewTable(3, 2, 300, lineHeight, report)
+		table.SetMargin(core.Scope{})
+		f := core.Font{Family: core.FontSans, Size: 10}
+		for col, header := range headers {
+			cell := table.NewCell()
+			columnWidth = table.GetColWidth(0, col)
+			cell.SetElement(NewTextCell(table.GetColWidth(0, col), lineHeight, 0, report).SetFont(f).
+				SetRotation(90).VerticalCentered().SetContent(header))
+			longest = math.Max(longest, report.MeasureTextWidthWithFont(header, f))
+		}
+		for col := range headers {
+			cell := table.NewCell()
+			cell.SetElement(NewTextCell(table.GetColWidth(1, col), lineHeight, 0, report).SetFont(f).SetContent("42"))
+		}
+		table.GenerateAtomicCell()
+	})
+
+	var rotated, body []placedText
+	for _, text := range placedTexts(t, records) {
+		if text.depth > 0 {
+			rotated = append(rotated, text)
+		} else {
+			body = append(body, text)
+		}
+	}
+	if len(rotated) != len(headers) || len(body) != 3 {
+		t.Fatalf("rotated texts %v, body texts %v", rotated, body)
+	}
+	// 标题自下而上书写, 排在各自的列中, 从标题行的底部开始; 标题行的高度为最长的标题的宽度
+	for col, text := range rotated {
+		if text.text != headers[col] || math.Abs(text.dx) > 1e-6 || math.Abs(text.dy+1) > 1e-6 {
+			t.Errorf("header %q written in direction (%.2f, %.2f)", text.text, text.dx, text.dy)
+		}
+		colLeft := left + float64(col)*columnWidth
+		if text.x < colLeft || text.x > colLeft+columnWidth {
+			t.Errorf("header %q at x %.2f, outside its column %.2f..%.2f", text.text, text.x, colLeft, colLeft+columnWidth)
+		}
+		if want := top + longest; math.Abs(text.y-want) > 0.5 {
+			t.Errorf("header %q starts at y %.2f, want %.2f", text.text, text.y, want)
+		}
+	}
+	for _, text := range body {
+		if text.y < top+longest {
+			t.Errorf("body text at baseline %.2f, want below the header row %.2f", text.y, top+longest)
+		}
+	}
+}
+
+func TestTableVerticalCell(t *testing.T) {
+	content := "竖排表头 Vertical 文字"
+	var (
+		sx, sy, height, whole float64
+		lengths               = map[string]float64{}
+	)
+	_, records := runReport(t, func(report *core.Report) {
+		sx, sy = report.GetXY()
+		table := NewTable(1, 1, 80, 14, report)
+		table.SetMargin(core.Scope{})
+		cell := table.NewCell()
+		text := NewTextCell(table.GetColWidth(0, 0), 14, 2, report).
+			SetFont(core.Font{Family: core.FontSans, Size: 12}).SetVertical().SetContent(content)
+		height = text.GetHeight()
+		report.SetFont(core.FontSans, 12)
+		for _, column := range text.contents {
+			lengths[column] = report.MeasureTextHeight(column)
+		}
+		whole = report.MeasureTextHeight(content)
+		cell.SetElement(text)
+		table.GenerateAtomicCell()
+	})
+
+	// 列排在 cell 的宽度内, 自 cell 的顶部向下, 单词不断开; cell 的高度为最长的列
+	var (
+		columns []string
+		longest float64
+	)
+	for _, text := range placedTexts(t, records) {
+		if text.op != "CV" {
+			continue
+		}
+		columns = append(columns, text.text)
+		longest = math.Max(longest, lengths[text.text])
+		if text.x < sx || text.x > sx+80 {
+			t.Errorf("column %q at x %.2f, outside the cell [%.2f, %.2f]", text.text, text.x, sx, sx+80)
+		}
+		if text.y < sy || text.y+lengths[text.text] > sy+height+0.01 {
+			t.Errorf("column %q from y %.2f to %.2f, outside the cell [%.2f, %.2f]",
+				text.text, text.y, text.y+lengths[text.text], sy, sy+height)
+		}
+	}
+	if len(columns) < 2 || len(columns) > 5 || !strings.Contains(strings.Join(columns, "|"), "Vertical") {
+		t.Errorf("got columns %q", columns)
+	}
+	if math.Abs(height-longest) > 0.01 || height >= whole {
+		t.Errorf("cell height %v, longest column %v", height, longest)
+	}
+}
+
+func TestTableOverflowRow(t *testing.T) {
+	text := strings.Repeat("A long product description. ", 5)
+	var (
+		sx      float64
+		widths  []float64
+		heights []float64
+		fits    int
+	)
+	_, records := runReport(t, func(report *core.Report) {
+		sx, _ = report.GetXY()
+		table := NewTable(3, 1, 300, 14, report)
+		table.SetMargin(core.Scope{})
+		overflows := []Overflow{
+			{Mode: OverflowEllipsis, Height: 30},
+			{Mode: OverflowShrink, Height: 30, MinFontSize: 4},
+			{Mode: OverflowClip, Height: 5}, // 放不下一行
+		}
+		for col, overflow := range overflows {
+			cell := table.NewCell()
+			widths = append(widths, table.GetColWidth(0, col))
+			text := NewTextCell(table.GetColWidth(0, col), 14, 0, report).
+				SetFont(core.Font{Family: core.FontSans, Size: 10}).
+				SetOverflow(overflow).SetContent(text)
+			heights = append(heights, text.GetHeight())
+			cell.SetElement(text)
+		}
+		table.GenerateAtomicCell()
+
+		// 固定的高度高于内容时, 可以写入的行数不超过内容的行数
+		fits = NewTextCell(100, 14, 0, report).SetFont(core.Font{Family: core.FontSans, Size: 10}).
+			SetOverflow(Overflow{Mode: OverflowClip, Height: 60}).SetContent("one line").fitLines(30)
+	})
+
+	if heights[0] != 30 || heights[1] != 30 || heights[2] != 5 {
+		t.Errorf("cell heights %v, want the fixed 30, 30 and 5", heights)
+	}
+	if fits != 1 {
+		t.Errorf("%d lines fit in a cell of one line", fits)
+	}
+
+	// 省略号的列写入 2 行, 缩小字号的列写入全部内容, 放不下一行的列不写入
+	columns := make([][]string, len(widths))
+	for _, text := range placedTexts(t, records) {
+		if text.op != "CL" {
+			continue
+		}
+		left := sx
+		for col, width := range widths {
+			if text.x >= left && text.x < left+width {
+				columns[col] = append(columns[col], text.text)
+			}
+			left += width
+		}
+	}
+	if lines := columns[0]; len(lines) != 2 || !strings.HasSuffix(lines[1], "…") {
+		t.Errorf("ellipsis column %q, want 2 lines ending with an ellipsis", lines)
+	}
+	if written := strings.Join(columns[1], " "); len(strings.Fields(written)) != len(strings.Fields(text)) ||
+		strings.Contains(written, "…") {
+		t.Errorf("shrunk column %q, want the whole content", columns[1])
+	}
+	if len(columns[2]) != 0 {
+		t.Errorf("column without room for a line %q", columns[2])
+	}
+}
@@ -0,0 +1,107 @@
+package gopdf
+
+import (
+	"math"
+
+	"github.com/tiechui1994/gopdf/core"
+	"github.com/tiechui1994/gopdf/util"
+)
+
+// 竖排: 段落按高度折成列, 列从右向左排列, 每一列由 core 从上向下写入("CV"). 中日韩文字直立并使用
+// 字体的竖排度量与竖排字形, 拉丁文等横排文字顺时针旋转 90 度. lineHeight 为列宽, lineSpace 为列间距.
+
+// wrapColumns 将各段落按当前字体折成高度不超过 height 的列
+func wrapColumns(pdf *core.Report, blocks []string, height float64) []string {
+	var columns []string
+	for _, block := range blocks {
+		columns = append(columns, pdf.WrapVerticalText(block, height)...)
+	}
+	return columns
+}
+
+// fitColumns 将各段落折成不超过 count 列, 返回列高最小且不在单词内断开的折法及其列高.
+// 放不下时每个段落一列
+func fitColumns(pdf *core.Report, blocks []string, count int) (columns []string, height float64) {
+	total, longest := 0.0, 0.0
+	for _, block := range blocks {
+		h := pdf.MeasureTextHeight(block)
+		total += h
+		longest = math.Max(longest, h)
+	}
+
+	// fits 判断列高为 height 时是否可以不断开单词地折成不超过 count 列
+	fits := func(height float64) bool {
+		n := 0
+		for _, block := range blocks {
+			text := []rune(block)
+			n++ // 空段落也占一列
+			for start := 0; start < len(text); {
+				fit := core.FitLine(text[start:], height, pdf.MeasureTextHeight, false)
+				if fit == 0 {
+					return false
+				}
+				if start += fit; start < len(text) {
+					n++
+				}
+			}
+		}
+		return n <= count
+	}
+
+	// 二分查找最小的列高
+	low, high := total/float64(count), longest
+	for i := 0; i < 20 && high-low > 0.5; i++ {
+		middle := (low + high) / 2
+		if fits(middle) {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+
+	columns = wrapColumns(pdf, blocks, high)
+	for _, column := range columns {
+		height = math.Max(height, pdf.MeasureTextHeight(column))
+	}
+	return columns, height
+}
+
+// columnCount 返回宽度 width 内可以排列的列数, 至少一列
+func columnCount(width, lineHeight, lineSpace float64) int {
+	count := int((width + lineSpace) / (lineHeight + lineSpace))
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// drawColumns 从 right 向左写入各列, 列的顶部为 top. center, bottom 使列的内容在 height 内居中或居下
+func drawColumns(pdf *core.Report, columns []string, right, top, height, lineHeight, lineSpace float64,
+	center, bottom bool, fontColor, highlight string) {
+	if !util.IsEmpty(fontColor) {
+		pdf.TextColor(util.RGB(fontColor))
+	}
+	if highlight != "" {
+		pdf.TextHighlight(util.RGB(highlight))
+	}
+
+	for i, column := range columns {
+		x := right - float64(i)*(lineHeight+lineSpace) - lineHeight/2
+		y := top
+		if center || bottom {
+			remain := height - pdf.MeasureTextHeight(column)
+			if center {
+				remain /= 2
+			}
+			y += math.Max(remain, 0)
+		}
+		pdf.VerticalCell(x, y, column)
+	}
+
+	if !util.IsEmpty(fontColor) {
+		pdf.TextDefaultColor()
+	}
+	if highlight != "" {
+		pdf.TextDefaultHighlight()
+	}
+}